@@ -4,13 +4,16 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"sync/atomic"
 	"time"
 
-	"github.com/asaskevich/govalidator"
 	"github.com/tencent-lke/lke-sdk-go/model"
 	"github.com/tencent-lke/lke-sdk-go/runner"
 	"github.com/tencent-lke/lke-sdk-go/tool"
+	"github.com/tencent-lke/lke-sdk-go/tracing"
 	"github.com/tencent-lke/lke-sdk-go/util"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 var Agentglobalnumber int64
@@ -22,13 +25,21 @@ type AgentAsTool struct {
 	Timeout      time.Duration // 超时配置
 	Agent        model.Agent
 	Tools        []tool.Tool // agent需要调用的tools
-	RequestID    string
-	VisitorBizID string
-	SessionID    string
+	RequestID    string      // 默认的请求 ID，在对话中执行时使用对话的请求 ID
+	VisitorBizID string      // 默认的访客 ID，在对话中执行时使用对话的访客 ID
+	SessionID    string      // 默认的对话 ID，在对话中执行时使用对话的对话 ID
 	index        int64
 	Conf         runner.RunnerConf
 	AgentNum     int64
-	RunnerImpl   *runner.RunnerImp
+	RetryPolicy  *tool.RetryPolicy // 执行失败时的重试策略，为空不重试
+	// SkipValidation 执行前不校验参数
+	SkipValidation bool
+	// ConfFunc 每次执行时获取 runner 配置，为空时使用 Conf
+	ConfFunc func() runner.RunnerConf
+	// ToolsFunc 每次执行时获取 agent 需要调用的 tools，为空时使用 Tools，例如 mcp 工具列表变化后使用最新的工具
+	ToolsFunc func() []tool.Tool
+	// Deprecated: 每次执行都会创建新的 runner，以支持多个对话并发执行，该字段不再赋值
+	RunnerImpl *runner.RunnerImp
 }
 
 // GetName returns the name of the tool
@@ -60,13 +71,22 @@ func (m *AgentAsTool) GetParametersSchema() map[string]interface{} {
 }
 
 // Execute executes the tool with the given parameter
-func (m *AgentAsTool) Execute(ctx context.Context, params map[string]interface{}) (interface{}, error) {
+func (m *AgentAsTool) Execute(ctx context.Context, params map[string]interface{}) (output interface{}, err error) {
+	conf := m.Conf
+	if m.ConfFunc != nil {
+		conf = m.ConfFunc()
+	}
+	ctx, span := tracing.Tracer(ctx, conf.TracerProvider).Start(ctx, tracing.SpanAgentAsTool,
+		trace.WithAttributes(
+			attribute.String(tracing.AttrToolName, m.Name),
+			attribute.String(tracing.AttrAgentName, m.Agent.Name),
+		))
+	defer func() {
+		tracing.End(span, err)
+	}()
 	input := ""
 	if m.Agent.InputSchema != nil {
-		_, err := govalidator.ValidateMap(params, m.Agent.InputSchema)
-		if err != nil {
-			return nil, fmt.Errorf("failed to validate parameters: %v", err)
-		}
+		// params 包括合并的用户自定义参数，没有设置 SkipValidation 时模型给出的参数和 InputSchema 中声明的自定义参数已经在执行前校验
 		paramsBytes, err := json.Marshal(params)
 		if err != nil {
 			return nil, fmt.Errorf("failed to marshal parameters: %v", err)
@@ -82,20 +102,33 @@ func (m *AgentAsTool) Execute(ctx context.Context, params map[string]interface{}
 	agents := []model.Agent{m.Agent}
 	toolsMap := map[string][]tool.Tool{}
 	toolsMap[m.Agent.Name] = m.Tools
+	if m.ToolsFunc != nil {
+		toolsMap[m.Agent.Name] = m.ToolsFunc()
+	}
 	handoffs := []model.Handoff{}
-	m.RunnerImpl = runner.NewRunnerImp(toolsMap, agents, handoffs, m.Conf)
-	sessionID := fmt.Sprintf("%s_%d_%d", m.SessionID, m.AgentNum, m.index)
+	runnerImpl := runner.NewRunnerImp(toolsMap, agents, handoffs, conf)
+	// 优先使用发起调用的对话，保证同一个 client 的不同对话互不影响
+	runSession := util.RunSession{
+		RequestID:    m.RequestID,
+		SessionID:    m.SessionID,
+		VisitorBizID: m.VisitorBizID,
+	}
+	if s, ok := util.GetRunSessionFromContext(ctx); ok {
+		runSession = s
+	}
+	index := atomic.AddInt64(&m.index, 1) - 1
+	sessionID := fmt.Sprintf("%s_%d_%d", runSession.SessionID, m.AgentNum, index)
 	options := &model.Options{StreamingThrottle: 20,
 		CustomVariables: map[string]string{
-			"_user_guid":    m.VisitorBizID,
-			"_user_task_id": m.SessionID,
+			"_user_guid":    runSession.VisitorBizID,
+			"_user_task_id": runSession.SessionID,
 		}}
 	if envSet := util.GetEnvSetFromContext(ctx); envSet != "" {
 		options.EnvSet = envSet
 	}
-	m.index = m.index + 1
 	instruction := input + "\n\n" + m.generateJSONInstructions()
-	result, err := m.RunnerImpl.RunWithContext(ctx, instruction, m.RequestID, sessionID, m.VisitorBizID, options)
+	result, err := runnerImpl.RunWithContext(ctx, instruction, runSession.RequestID, sessionID,
+		runSession.VisitorBizID, options)
 	if err != nil {
 		return nil, err
 	}
@@ -107,23 +140,7 @@ func (m *AgentAsTool) generateJSONInstructions() string {
 	if m.Agent.OutputSchema == nil {
 		return ""
 	}
-	// Convert schema to a readable format for the instruction
-	schemaStr := m.formatSchemaForInstruction(m.Agent.OutputSchema)
-	return fmt.Sprintf("IMPORTANT: You must respond with valid JSON in the following format:\n%s\n\n"+
-		"Your response must be valid JSON that matches this schema exactly. "+
-		"Do not include ```json or ``` in the beginning or end of the response.", schemaStr)
-}
-
-// formatSchemaForInstruction formats the schema for inclusion in instructions.
-func (p *AgentAsTool) formatSchemaForInstruction(schema map[string]interface{}) string {
-	// For now, we'll create a simple JSON representation.
-	// In a more sophisticated implementation, we could parse the schema more intelligently.
-	jsonBytes, err := json.MarshalIndent(schema, "", "  ")
-	if err != nil {
-		// Fallback to a simple string representation.
-		return fmt.Sprintf("%v", schema)
-	}
-	return string(jsonBytes)
+	return tool.OutputInstruction(m.Agent.OutputSchema)
 }
 
 // ResultToString ...
@@ -141,3 +158,28 @@ func (m *AgentAsTool) GetTimeout() time.Duration {
 func (m *AgentAsTool) SetTimeout(t time.Duration) {
 	m.Timeout = t
 }
+
+// GetRetryPolicy 获取重试策略
+func (m *AgentAsTool) GetRetryPolicy() *tool.RetryPolicy {
+	return m.RetryPolicy
+}
+
+// SetRetryPolicy 设置重试策略，为空不重试
+func (m *AgentAsTool) SetRetryPolicy(p *tool.RetryPolicy) {
+	m.RetryPolicy = p
+}
+
+// GetValidateArgs 执行前是否校验参数，默认校验
+func (m *AgentAsTool) GetValidateArgs() bool {
+	return !m.SkipValidation
+}
+
+// SetValidateArgs 设置执行前是否校验参数
+func (m *AgentAsTool) SetValidateArgs(validate bool) {
+	m.SkipValidation = !validate
+}
+
+// GetAgent 获取作为工具的 agent
+func (m *AgentAsTool) GetAgent() model.Agent {
+	return m.Agent
+}
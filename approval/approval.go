@@ -0,0 +1,93 @@
+// Package approval 本地工具调用的审批
+//
+// Policy 为每个工具指定审批模式：自动执行、需要审批或者禁止执行。需要审批的调用交给 Approver 决定，
+// 没有设置 Approver 时执行会暂停，返回 lkeerrors.ErrRunPaused 和包括待审批调用的 runner.Pending，
+// 审批完成后通过 Resume 继续执行。被拒绝的调用不会执行，拒绝原因作为工具的输出提交给模型。
+// 作为工具的 agent 嵌套执行时不会暂停，没有 Approver 时需要审批的调用直接拒绝。
+package approval
+
+import (
+	"context"
+	"fmt"
+)
+
+// Mode 工具的审批模式
+type Mode string
+
+// 审批模式枚举
+const (
+	ModeAuto     Mode = "auto"     // 自动执行
+	ModeRequired Mode = "required" // 需要审批
+	ModeDenied   Mode = "denied"   // 禁止执行
+)
+
+// Policy 审批策略
+type Policy struct {
+	Default Mode // 没有配置的工具的审批模式，为空时自动执行
+	// Tools 工具的审批模式，key 为工具名称，或者 "agent名称/工具名称" 只对指定 agent 的工具生效，后者优先
+	Tools map[string]Mode
+}
+
+// Mode 获取 agent 的工具的审批模式，p 为空时自动执行
+func (p *Policy) Mode(agent, tool string) Mode {
+	if p == nil {
+		return ModeAuto
+	}
+	if m, ok := p.Tools[agent+"/"+tool]; ok {
+		return m
+	}
+	if m, ok := p.Tools[tool]; ok {
+		return m
+	}
+	if p.Default == "" {
+		return ModeAuto
+	}
+	return p.Default
+}
+
+// Request 一次待审批的工具调用
+type Request struct {
+	CallID    string                 `json:"call_id"`
+	ToolName  string                 `json:"tool_name"`
+	AgentName string                 `json:"agent_name"`
+	Arguments map[string]interface{} `json:"arguments"` // 模型给出的参数，不包括用户自定义参数
+}
+
+// Decision 审批结果
+type Decision struct {
+	Approved bool   `json:"approved"`
+	Reason   string `json:"reason,omitempty"` // 拒绝的原因，会提交给模型
+}
+
+// Approve 同意执行
+func Approve() Decision {
+	return Decision{Approved: true}
+}
+
+// Reject 拒绝执行，reason 会提交给模型
+func Reject(reason string) Decision {
+	return Decision{Reason: reason}
+}
+
+// Approver 审批回调，可以阻塞直到审批完成，ctx 取消时应该尽快返回
+// 返回错误时按拒绝处理
+type Approver interface {
+	Approve(ctx context.Context, req Request) (Decision, error)
+}
+
+// ApproverFunc 函数形式的 Approver
+type ApproverFunc func(ctx context.Context, req Request) (Decision, error)
+
+// Approve 实现 Approver
+func (f ApproverFunc) Approve(ctx context.Context, req Request) (Decision, error) {
+	return f(ctx, req)
+}
+
+// RejectedOutput 拒绝执行时提交给模型的工具输出
+func RejectedOutput(tool, reason string) string {
+	if reason == "" {
+		reason = "no reason given"
+	}
+	return fmt.Sprintf("Tool %s call was rejected: %s. Do not call it again with the same arguments, "+
+		"try another approach", tool, reason)
+}
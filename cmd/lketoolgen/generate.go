@@ -0,0 +1,289 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// toolDirective 标记需要生成工具的函数的注释
+const toolDirective = "//lke:tool"
+
+// toolFunc 标记了 //lke:tool 的函数
+type toolFunc struct {
+	name        string // 工具名
+	funcName    string // 函数名
+	description string // 函数注释
+}
+
+// fieldDoc 结构体字段的注释
+type fieldDoc struct {
+	field       string
+	description string
+}
+
+// generator 一个包的解析状态
+type generator struct {
+	fset    *token.FileSet
+	structs map[string]*ast.StructType // 包中的结构体类型
+	docs    map[string][]fieldDoc      // 结构体类型名 -> 字段注释
+	visited map[string]bool
+	errs    []error
+}
+
+// generate 解析 dir 中的包，返回生成的代码，缺少注释时返回所有缺少注释的位置
+func generate(dir, output, funcName string) ([]byte, error) {
+	fset := token.NewFileSet()
+	pkgs, err := parser.ParseDir(fset, dir, func(fi os.FileInfo) bool {
+		return !strings.HasSuffix(fi.Name(), "_test.go") && fi.Name() != output
+	}, parser.ParseComments)
+	if err != nil {
+		return nil, err
+	}
+	if len(pkgs) != 1 {
+		return nil, fmt.Errorf("expect exactly one package in %s, found %d", dir, len(pkgs))
+	}
+	var pkg *ast.Package
+	for _, p := range pkgs {
+		pkg = p
+	}
+	g := &generator{
+		fset:    fset,
+		structs: map[string]*ast.StructType{},
+		docs:    map[string][]fieldDoc{},
+		visited: map[string]bool{},
+	}
+	files := make([]string, 0, len(pkg.Files))
+	for name, f := range pkg.Files {
+		files = append(files, name)
+		for _, decl := range f.Decls {
+			gen, ok := decl.(*ast.GenDecl)
+			if !ok || gen.Tok != token.TYPE {
+				continue
+			}
+			for _, spec := range gen.Specs {
+				ts := spec.(*ast.TypeSpec)
+				if st, ok := ts.Type.(*ast.StructType); ok && ts.TypeParams == nil {
+					g.structs[ts.Name.Name] = st
+				}
+			}
+		}
+	}
+	sort.Strings(files)
+	var funcs []toolFunc
+	for _, name := range files {
+		for _, decl := range pkg.Files[name].Decls {
+			fn, ok := decl.(*ast.FuncDecl)
+			if !ok || fn.Recv != nil || fn.Doc == nil {
+				continue
+			}
+			if tf, ok := g.toolFunc(fn); ok {
+				funcs = append(funcs, tf)
+			}
+		}
+	}
+	if len(g.errs) > 0 {
+		return nil, errors.Join(g.errs...)
+	}
+	if len(funcs) == 0 {
+		return nil, fmt.Errorf("no function annotated with %s in %s", toolDirective, dir)
+	}
+	return g.render(pkg.Name, funcName, funcs)
+}
+
+// toolFunc 解析标记了 //lke:tool 的函数，检查函数和参数字段的注释
+func (g *generator) toolFunc(fn *ast.FuncDecl) (toolFunc, bool) {
+	tf := toolFunc{name: fn.Name.Name, funcName: fn.Name.Name}
+	annotated := false
+	for _, c := range fn.Doc.List {
+		if c.Text != toolDirective && !strings.HasPrefix(c.Text, toolDirective+" ") {
+			continue
+		}
+		annotated = true
+		for _, opt := range strings.Fields(strings.TrimPrefix(c.Text, toolDirective)) {
+			if name, ok := strings.CutPrefix(opt, "name="); ok && name != "" {
+				tf.name = name
+			} else {
+				g.errorf(c.Pos(), "unknown option %q in %s", opt, toolDirective)
+			}
+		}
+	}
+	if !annotated {
+		return tf, false
+	}
+	tf.description = cleanComment(fn.Doc.Text(), fn.Name.Name)
+	if tf.description == "" {
+		g.errorf(fn.Pos(), "function %s has no doc comment for the tool description", fn.Name.Name)
+	}
+	var params []ast.Expr
+	for _, p := range fn.Type.Params.List {
+		n := max(len(p.Names), 1)
+		for i := 0; i < n; i++ {
+			params = append(params, p.Type)
+		}
+	}
+	if len(params) > 0 && isContext(params[0]) {
+		params = params[1:]
+	}
+	switch {
+	case len(params) > 1:
+		g.errorf(fn.Pos(), "function %s: excluding context, a function can have at most one parameter",
+			fn.Name.Name)
+	case len(params) == 1:
+		ident, ok := params[0].(*ast.Ident)
+		if !ok || g.structs[ident.Name] == nil {
+			g.errorf(params[0].Pos(), "function %s: parameter must be a struct type declared in the package",
+				fn.Name.Name)
+			break
+		}
+		g.visitStruct(ident.Name)
+	}
+	return tf, true
+}
+
+// visitStruct 收集结构体字段的注释，递归处理字段中同一个包的结构体类型
+func (g *generator) visitStruct(name string) {
+	if g.visited[name] {
+		return
+	}
+	g.visited[name] = true
+	st := g.structs[name]
+	var docs []fieldDoc
+	for _, field := range st.Fields.List {
+		g.visitType(field.Type)
+		if len(field.Names) == 0 {
+			// 嵌入结构体的字段展开到外层，注释在嵌入的类型上检查
+			continue
+		}
+		var tag reflect.StructTag
+		if field.Tag != nil {
+			s, _ := strconv.Unquote(field.Tag.Value)
+			tag = reflect.StructTag(s)
+		}
+		for _, n := range field.Names {
+			if !n.IsExported() || strings.Split(tag.Get("json"), ",")[0] == "-" {
+				continue
+			}
+			comment := field.Doc.Text()
+			if comment == "" {
+				comment = field.Comment.Text()
+			}
+			desc := cleanComment(comment, n.Name)
+			if desc != "" {
+				docs = append(docs, fieldDoc{field: n.Name, description: desc})
+			} else if tag.Get("doc") == "" {
+				g.errorf(n.Pos(), "field %s.%s has no comment for the parameter description", name, n.Name)
+			}
+		}
+	}
+	if len(docs) > 0 {
+		g.docs[name] = docs
+	}
+}
+
+// visitType 处理字段类型中同一个包的结构体类型
+func (g *generator) visitType(expr ast.Expr) {
+	switch t := expr.(type) {
+	case *ast.Ident:
+		if g.structs[t.Name] != nil {
+			g.visitStruct(t.Name)
+		}
+	case *ast.StarExpr:
+		g.visitType(t.X)
+	case *ast.ArrayType:
+		g.visitType(t.Elt)
+	case *ast.MapType:
+		g.visitType(t.Value)
+	case *ast.StructType:
+		g.errorf(t.Pos(), "anonymous struct types are not supported, declare a named type instead")
+	}
+}
+
+// render 生成代码
+func (g *generator) render(pkgName, funcName string, funcs []toolFunc) ([]byte, error) {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "// Code generated by lketoolgen. DO NOT EDIT.\n\n")
+	fmt.Fprintf(&buf, "package %s\n\n", pkgName)
+	fmt.Fprintf(&buf, "import (\n\t\"fmt\"\n\n\t\"github.com/tencent-lke/lke-sdk-go/tool\"\n)\n\n")
+	if len(g.docs) > 0 {
+		names := make([]string, 0, len(g.docs))
+		for name := range g.docs {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		fmt.Fprintf(&buf, "func init() {\n")
+		for _, name := range names {
+			fmt.Fprintf(&buf, "\ttool.RegisterFieldDescriptions[%s](map[string]string{\n", name)
+			for _, d := range g.docs[name] {
+				fmt.Fprintf(&buf, "\t\t%q: %s,\n", d.field, strconv.Quote(d.description))
+			}
+			fmt.Fprintf(&buf, "\t})\n")
+		}
+		fmt.Fprintf(&buf, "}\n\n")
+	}
+	fmt.Fprintf(&buf, "// %s 按函数注释生成的函数工具\n", funcName)
+	fmt.Fprintf(&buf, "func %s() ([]*tool.FunctionTool, error) {\n", funcName)
+	fmt.Fprintf(&buf, "\tdefs := []struct {\n\t\tname, description string\n\t\tfn interface{}\n\t}{\n")
+	for _, f := range funcs {
+		fmt.Fprintf(&buf, "\t\t{%q, %s, %s},\n", f.name, strconv.Quote(f.description), f.funcName)
+	}
+	fmt.Fprintf(&buf, "\t}\n")
+	fmt.Fprintf(&buf, "\ttools := make([]*tool.FunctionTool, 0, len(defs))\n")
+	fmt.Fprintf(&buf, "\tfor _, def := range defs {\n")
+	fmt.Fprintf(&buf, "\t\tt, err := tool.NewFunctionTool(def.name, def.description, def.fn, nil)\n")
+	fmt.Fprintf(&buf, "\t\tif err != nil {\n\t\t\treturn nil, fmt.Errorf(\"tool %%s: %%w\", def.name, err)\n\t\t}\n")
+	fmt.Fprintf(&buf, "\t\ttools = append(tools, t)\n\t}\n\treturn tools, nil\n}\n")
+	return format.Source(buf.Bytes())
+}
+
+// errorf 记录带位置的错误
+func (g *generator) errorf(pos token.Pos, format string, args ...interface{}) {
+	g.errs = append(g.errs, fmt.Errorf("%s: %s", g.fset.Position(pos), fmt.Sprintf(format, args...)))
+}
+
+// isContext 类型是否为 context.Context
+func isContext(expr ast.Expr) bool {
+	sel, ok := expr.(*ast.SelectorExpr)
+	if !ok {
+		return false
+	}
+	pkg, ok := sel.X.(*ast.Ident)
+	return ok && pkg.Name == "context" && sel.Sel.Name == "Context"
+}
+
+// cleanComment 去掉注释开头的名字，多行合并成一行，中文换行处不加空格
+func cleanComment(text, name string) string {
+	var b strings.Builder
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.Join(strings.Fields(line), " ")
+		if line == "" {
+			continue
+		}
+		if b.Len() > 0 {
+			last, _ := utf8.DecodeLastRuneInString(b.String())
+			first, _ := utf8.DecodeRuneInString(line)
+			if last < utf8.RuneSelf || first < utf8.RuneSelf {
+				b.WriteByte(' ')
+			}
+		}
+		b.WriteString(line)
+	}
+	text = b.String()
+	if rest, ok := strings.CutPrefix(text, name+" "); ok {
+		return rest
+	}
+	if text == name {
+		return ""
+	}
+	return text
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "update golden files")
+
+func TestGenerate(t *testing.T) {
+	dir := filepath.Join("testdata", "weather")
+	src, err := generate(dir, "lke_tools_gen.go", "LkeTools")
+	if err != nil {
+		t.Fatal(err)
+	}
+	golden := filepath.Join(dir, "lke_tools_gen.go")
+	if *update {
+		if err := os.WriteFile(golden, src, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	except, err := os.ReadFile(golden)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(except) != string(src) {
+		t.Fatalf("generated code differs from %s, run `go test ./cmd/lketoolgen -update` if the change is expected\n%s",
+			golden, firstDiff(string(except), string(src)))
+	}
+	// golden 文件需要能够编译，避免生成的代码有语法或者类型错误
+	if testing.Short() {
+		return
+	}
+	goBin, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("go command not found, skip compiling generated code")
+	}
+	if out, err := exec.Command(goBin, "vet", "./"+filepath.ToSlash(dir)).CombinedOutput(); err != nil {
+		t.Fatalf("generated code does not compile: %v\n%s", err, out)
+	}
+}
+
+// firstDiff 第一处不同的行，用于定位生成代码和 golden 文件的差异
+func firstDiff(except, actual string) string {
+	exceptLines, actualLines := strings.Split(except, "\n"), strings.Split(actual, "\n")
+	for i := 0; i < max(len(exceptLines), len(actualLines)); i++ {
+		var e, a string
+		if i < len(exceptLines) {
+			e = exceptLines[i]
+		}
+		if i < len(actualLines) {
+			a = actualLines[i]
+		}
+		if e != a {
+			return fmt.Sprintf("line %d:\n  except: %q\n  actual: %q", i+1, e, a)
+		}
+	}
+	return ""
+}
+
+func TestGenerateMissingComment(t *testing.T) {
+	_, err := generate(filepath.Join("testdata", "missing"), "lke_tools_gen.go", "LkeTools")
+	if err == nil {
+		t.Fatal("expect error for missing comments")
+	}
+	for _, want := range []string{
+		"missing.go:11:1: function Greet has no doc comment",
+		"missing.go:5:2: field Params.Name has no comment",
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error %q does not contain %q", err, want)
+		}
+	}
+	if strings.Contains(err.Error(), "Age") {
+		t.Errorf("unexpected error for commented field: %v", err)
+	}
+}
+
+func TestCleanComment(t *testing.T) {
+	cases := []struct{ text, name, want string }{
+		{"GetWeather 查询天气，\n返回温度\n", "GetWeather", "查询天气，返回温度"},
+		{"Now returns\n the time", "Now", "returns the time"},
+		{"查询天气", "GetWeather", "查询天气"},
+		{"Name\n", "Name", ""},
+		{"Names 名字", "Name", "Names 名字"},
+	}
+	for _, c := range cases {
+		if got := cleanComment(c.text, c.name); got != c.want {
+			t.Errorf("cleanComment(%q, %q) = %q, want %q", c.text, c.name, got, c.want)
+		}
+	}
+}
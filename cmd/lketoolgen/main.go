@@ -0,0 +1,39 @@
+// lketoolgen 按 go 注释生成函数工具的注册代码
+//
+// 在函数的注释中加上 //lke:tool 标记需要生成工具的函数，可以通过 //lke:tool name=get_weather 指定工具名，
+// 默认为函数名。函数的注释作为工具描述，参数结构体（以及嵌套的同一个包中的结构体）字段的注释作为参数描述，
+// 字段上有 doc tag 时优先使用 doc tag。在包中加上
+//
+//	//go:generate go run github.com/tencent-lke/lke-sdk-go/cmd/lketoolgen
+//
+// 执行 go generate 生成 lke_tools_gen.go，其中的 LkeTools 返回所有函数工具，例如
+//
+//	tools, err := LkeTools()
+//	client.AddFunctionTools("Agent-A", tools)
+//
+// 标记的函数或者参数字段没有注释时输出所有缺少注释的位置并以非 0 退出，go generate 失败。
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+func main() {
+	dir := flag.String("dir", ".", "directory of the package to parse")
+	output := flag.String("output", "lke_tools_gen.go", "output file name, relative to dir")
+	funcName := flag.String("func", "LkeTools", "name of the generated function returning the tools")
+	flag.Parse()
+
+	src, err := generate(*dir, *output, *funcName)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "lketoolgen: %v\n", err)
+		os.Exit(1)
+	}
+	if err := os.WriteFile(filepath.Join(*dir, *output), src, 0o644); err != nil {
+		fmt.Fprintf(os.Stderr, "lketoolgen: %v\n", err)
+		os.Exit(1)
+	}
+}
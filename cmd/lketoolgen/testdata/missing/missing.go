@@ -0,0 +1,13 @@
+package missing
+
+// Params 参数
+type Params struct {
+	Name string `json:"name"`
+	// Age 年龄
+	Age int `json:"age"`
+}
+
+//lke:tool
+func Greet(p Params) string {
+	return "hello " + p.Name
+}
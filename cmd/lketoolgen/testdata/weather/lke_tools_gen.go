@@ -0,0 +1,43 @@
+// Code generated by lketoolgen. DO NOT EDIT.
+
+package weather
+
+import (
+	"fmt"
+
+	"github.com/tencent-lke/lke-sdk-go/tool"
+)
+
+func init() {
+	tool.RegisterFieldDescriptions[Location](map[string]string{
+		"City": "城市名，例如北京",
+	})
+	tool.RegisterFieldDescriptions[Query](map[string]string{
+		"Days":  "查询未来几天的天气，最多 7 天",
+		"Units": "温度单位",
+		"Date":  "查询的日期",
+	})
+	tool.RegisterFieldDescriptions[Unit](map[string]string{
+		"Name": "单位名称，摄氏度或华氏度",
+	})
+}
+
+// LkeTools 按函数注释生成的函数工具
+func LkeTools() ([]*tool.FunctionTool, error) {
+	defs := []struct {
+		name, description string
+		fn                interface{}
+	}{
+		{"get_weather", "查询指定城市的天气，返回天气和温度", GetWeather},
+		{"Now", "获取当前时间", Now},
+	}
+	tools := make([]*tool.FunctionTool, 0, len(defs))
+	for _, def := range defs {
+		t, err := tool.NewFunctionTool(def.name, def.description, def.fn, nil)
+		if err != nil {
+			return nil, fmt.Errorf("tool %s: %w", def.name, err)
+		}
+		tools = append(tools, t)
+	}
+	return tools, nil
+}
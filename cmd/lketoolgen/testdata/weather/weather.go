@@ -0,0 +1,48 @@
+package weather
+
+import (
+	"context"
+	"time"
+)
+
+// Location 地点
+type Location struct {
+	// City 城市名，例如北京
+	City    string `json:"city"`
+	Country string `json:"country" doc:"国家，默认为中国"`
+	Extra   string `json:"-"`
+	cache   string
+}
+
+// Query 查询天气的参数
+type Query struct {
+	Location
+	// Days 查询未来几天的天气，
+	// 最多 7 天
+	Days  int        `json:"days"`
+	Units []Unit     `json:"units"` // 温度单位
+	Date  *time.Time `json:"date"`  // 查询的日期
+}
+
+// Unit 温度单位
+type Unit struct {
+	Name string `json:"name"` // Name 单位名称，摄氏度或华氏度
+}
+
+// GetWeather 查询指定城市的天气，
+// 返回天气和温度
+//
+//lke:tool name=get_weather
+func GetWeather(ctx context.Context, q Query) (string, error) {
+	return q.City + " 晴", nil
+}
+
+// Now 获取当前时间
+//
+//lke:tool
+func Now() string {
+	return time.Now().String()
+}
+
+// helper 没有标记，不生成工具
+func helper() {}
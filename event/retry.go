@@ -0,0 +1,21 @@
+package event
+
+// EventRetry 云端接口调用重试事件
+const EventRetry = "retry"
+
+// RetryEvent 云端接口调用重试事件消息体
+type RetryEvent struct {
+	RequestID   string      `json:"request_id"`      // 请求 ID
+	Attempt     int         `json:"attempt"`         // 失败的是第几次尝试，从 1 开始
+	MaxAttempts int         `json:"max_attempts"`    // 最大尝试次数
+	DelayMs     int64       `json:"delay_ms"`        // 下一次尝试前的等待时间, 单位 ms
+	StatusCode  int         `json:"status_code"`     // http 状态码，没有收到响应时为 0
+	Err         error       `json:"-"`               // 本次尝试的错误
+	ErrMsg      string      `json:"error,omitempty"` // 本次尝试的错误信息
+	Extend      EventExtend `json:"extend,omitempty"`
+}
+
+// Name 事件名称
+func (e RetryEvent) Name() string {
+	return EventRetry
+}
@@ -0,0 +1,40 @@
+package event
+
+// EventToolCallStart 本地工具调用开始事件
+const EventToolCallStart = "tool_call_start"
+
+// EventToolCallEnd 本地工具调用结束事件
+const EventToolCallEnd = "tool_call_end"
+
+// ToolCallStartEvent 本地工具调用开始事件消息体
+type ToolCallStartEvent struct {
+	CallID    string                 `json:"call_id"`    // 工具调用 ID
+	ToolName  string                 `json:"tool_name"`  // 工具名
+	AgentName string                 `json:"agent_name"` // 发起调用的 agent
+	Input     map[string]interface{} `json:"input"`      // 工具输入
+	Extend    EventExtend            `json:"extend,omitempty"`
+}
+
+// Name 事件名称
+func (e ToolCallStartEvent) Name() string {
+	return EventToolCallStart
+}
+
+// ToolCallEndEvent 本地工具调用结束事件消息体
+type ToolCallEndEvent struct {
+	CallID    string                 `json:"call_id"`    // 工具调用 ID
+	ToolName  string                 `json:"tool_name"`  // 工具名
+	AgentName string                 `json:"agent_name"` // 发起调用的 agent
+	Input     map[string]interface{} `json:"input"`      // 工具输入
+	// 如果是自定义的函数，output 类型是自定义函数的返回
+	// 如果是 mcp 工具，output 是 *mcp.CallToolResult 类型
+	Output interface{} `json:"output"`
+	Err    error       `json:"-"`               // 工具执行错误
+	ErrMsg string      `json:"error,omitempty"` // 工具执行错误信息
+	Extend EventExtend `json:"extend,omitempty"`
+}
+
+// Name 事件名称
+func (e ToolCallEndEvent) Name() string {
+	return EventToolCallEnd
+}
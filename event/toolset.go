@@ -0,0 +1,17 @@
+package event
+
+// EventToolsetChanged mcp server 的工具列表变化事件
+const EventToolsetChanged = "toolset_changed"
+
+// ToolsetChangedEvent mcp server 的工具列表变化后，agent 的工具同步增加或删除
+type ToolsetChangedEvent struct {
+	AgentName string   `json:"agent_name"`        // 工具所属的 agent
+	Added     []string `json:"added,omitempty"`   // 增加到 agent 的工具
+	Removed   []string `json:"removed,omitempty"` // 从 agent 删除的工具
+	Updated   []string `json:"updated,omitempty"` // 描述或者参数发生变化的工具
+}
+
+// Name 事件名称
+func (e ToolsetChangedEvent) Name() string {
+	return EventToolsetChanged
+}
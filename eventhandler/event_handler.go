@@ -14,6 +14,8 @@ type ToolCallContext struct {
 	Output interface{}
 	Err    error
 	Extend map[string]string
+	// Attempts 工具的执行次数，包括按重试策略的重试，只在 AfterToolCallHook 中有效
+	Attempts int
 }
 
 // EventHandler 事件处理的接口，用户可以用默认的实现，也可以自定义
@@ -41,6 +43,20 @@ type EventHandler interface {
 	AfterToolCallHook(tollCallCtx ToolCallContext)
 }
 
+// RetryHandler 可选的重试事件处理接口，EventHandler 同时实现该接口时，
+// 云端接口调用每次失败重试前都会回调 OnRetry
+type RetryHandler interface {
+	// OnRetry 重试事件处理
+	OnRetry(retry *event.RetryEvent)
+}
+
+// ToolsetChangedHandler 可选的工具列表变化事件处理接口，EventHandler 同时实现该接口时，
+// AddMcpTools 增加的 mcp server 的工具列表变化，同步增加或删除 agent 的工具后回调 OnToolsetChanged
+type ToolsetChangedHandler interface {
+	// OnToolsetChanged 工具列表变化事件处理
+	OnToolsetChanged(e *event.ToolsetChangedEvent)
+}
+
 // DefaultEventHandler 默认事件处理
 type DefaultEventHandler struct {
 }
@@ -65,3 +81,9 @@ func (DefaultEventHandler) BeforeToolCallHook(tollCallCtx ToolCallContext) {}
 
 // AfterToolCallHook 工具调用后的钩子
 func (DefaultEventHandler) AfterToolCallHook(tollCallCtx ToolCallContext) {}
+
+// OnRetry 重试事件处理
+func (DefaultEventHandler) OnRetry(retry *event.RetryEvent) {}
+
+// OnToolsetChanged 工具列表变化事件处理
+func (DefaultEventHandler) OnToolsetChanged(e *event.ToolsetChangedEvent) {}
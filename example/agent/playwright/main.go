@@ -89,7 +89,7 @@ func (e *MyEventHandler) OnReply(reply *event.ReplyEvent) {
 	e.replying = true
 	e.lastThought = ""
 	if reply.IsFinal {
-		fmt.Println("\n")
+		fmt.Print("\n\n")
 		e.replying = false
 	}
 }
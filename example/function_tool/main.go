@@ -77,7 +77,7 @@ func (MyEventHandler) OnReply(reply *event.ReplyEvent) {
 
 func main() {
 	sessionID := uuid.New().String()
-	client := lkesdk.NewLkeClient(botAppKey, &MyEventHandler{})
+	client := lkesdk.NewLkeClient(botAppKey, visitorBizID, sessionID, &MyEventHandler{})
 	client.SetMock(true)
 	// 方式1, 自定义函数，除去 context，入参是一个 struct，并且 struct 中每个字段都有 tag,
 	// json tag 会转换参数名，doc tag 转换成字段描述
@@ -143,7 +143,7 @@ func main() {
 		options := &model.Options{
 			StreamingThrottle: 5,
 		}
-		finalReply, err := client.Run(query, sessionID, visitorBizID, options)
+		finalReply, err := client.Run(query, options)
 		if err != nil {
 			log.Fatalf("run error: %v", err)
 		}
@@ -42,10 +42,12 @@ func (MyEventHandler) OnThought(thought *event.AgentThoughtEvent) {
 }
 
 func main() {
-	sessionID := uuid.New().String()
-	client := lkesdk.NewLkeClient(botAppKey, &MyEventHandler{})
+	client := lkesdk.NewLkeClient(botAppKey, "", "", &MyEventHandler{})
 	// client.SetMock(true)
 
+	// 一个 client 可以服务多个用户和对话，每个对话单独创建 session
+	session := client.NewSession(visitorBizID, uuid.New().String())
+
 	for {
 		reader := bufio.NewReader(os.Stdin)
 
@@ -61,7 +63,7 @@ func main() {
 		options := &model.Options{
 			StreamingThrottle: 5,
 		}
-		finalReply, err := client.Run(query, sessionID, visitorBizID, options)
+		finalReply, err := session.Run(query, options)
 		if err != nil {
 			log.Fatalf("run error: %v", err)
 		}
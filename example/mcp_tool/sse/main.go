@@ -11,10 +11,10 @@ import (
 	"strings"
 
 	"github.com/google/uuid"
-	mcpclient "github.com/mark3labs/mcp-go/client"
 	"github.com/mark3labs/mcp-go/mcp"
 	"github.com/mark3labs/mcp-go/server"
 	lkesdk "github.com/tencent-lke/lke-sdk-go"
+	"github.com/tencent-lke/lke-sdk-go/mcpserversse"
 	"github.com/tencent-lke/lke-sdk-go/model"
 )
 
@@ -24,7 +24,7 @@ const (
 	botAppKey = "custom-app-key"
 )
 
-func buildSeeMcpClient() mcpclient.MCPClient {
+func buildSseMcpServer() *mcpserversse.McpServerClient {
 	// 启动一个 test sse server
 	mcpServer := server.NewMCPServer(
 		"test-server",
@@ -56,28 +56,22 @@ func buildSeeMcpClient() mcpclient.MCPClient {
 	}
 	// ch := make(chan int, 1)
 	// <-ch
-	c, err := mcpclient.NewSSEMCPClient(testServer.URL + "/sse")
+	c, err := mcpserversse.NewMcpServer(mcpserversse.SSETransport(testServer.URL+"/sse"), mcp.InitializeRequest{})
 	if err != nil {
 		log.Fatalf("Failed to connect sse server: %v", err)
 	}
-	if err := c.Start(context.Background()); err != nil {
-		log.Fatalf("Failed to start client: %v", err)
-	}
 	return c
 }
 
 func main() {
 	sessionID := uuid.New().String()
-	client := lkesdk.NewLkeClient(botAppKey, nil)
+	client := lkesdk.NewLkeClient(botAppKey, visitorBizID, sessionID, nil)
 	// client.SetMock(true) // mock run
 
 	// 增加 sse 插件
-	c := buildSeeMcpClient()
+	c := buildSseMcpServer()
 	defer c.Close()
-	addTools, err := client.AddMcpTools("Agent-A", c, mcp.Implementation{
-		Name:    "test",
-		Version: "1,0,0",
-	}, nil)
+	addTools, err := client.AddMcpTools("Agent-A", c, nil)
 	if err != nil {
 		log.Fatalf("Failed to AddMcpTools, error: %v", err)
 	}
@@ -103,7 +97,7 @@ func main() {
 		options := &model.Options{
 			StreamingThrottle: 5,
 		}
-		finalReply, err := client.Run(query, sessionID, visitorBizID, options)
+		finalReply, err := client.Run(query, options)
 		if err != nil {
 			log.Fatalf("run error: %v", err)
 		}
@@ -11,9 +11,9 @@ import (
 	"strings"
 
 	"github.com/google/uuid"
-	mcpclient "github.com/mark3labs/mcp-go/client"
 	"github.com/mark3labs/mcp-go/mcp"
 	lkesdk "github.com/tencent-lke/lke-sdk-go"
+	"github.com/tencent-lke/lke-sdk-go/mcpserversse"
 	"github.com/tencent-lke/lke-sdk-go/model"
 )
 
@@ -23,15 +23,13 @@ const (
 	botAppKey = "custom-app-key"
 )
 
-func buildCustomStdioMcpClient() mcpclient.MCPClient {
+func buildCustomStdioMcpServer() *mcpserversse.McpServerClient {
 	_, f, _, _ := runtime.Caller(0)
-	serverPath := path.Join(path.Dir(f), "custom_server", "server.go")
-	c, err := mcpclient.NewStdioMCPClient(
-		"go",
-		[]string{}, // Empty ENV
-		"run",
-		serverPath,
-	)
+	c, err := mcpserversse.NewMcpServer(mcpserversse.StdioTransport(mcpserversse.StdioCommand{
+		Command: "go",
+		Args:    []string{"run", "."},
+		Dir:     path.Join(path.Dir(f), "custom_server"),
+	}), mcp.InitializeRequest{})
 	if err != nil {
 		log.Fatalf("Failed to create client: %v", err)
 	}
@@ -40,16 +38,13 @@ func buildCustomStdioMcpClient() mcpclient.MCPClient {
 
 func main() {
 	sessionID := uuid.New().String()
-	client := lkesdk.NewLkeClient(botAppKey, nil)
+	client := lkesdk.NewLkeClient(botAppKey, visitorBizID, sessionID, nil)
 	// client.SetMock(true) // mock run
 
 	// 增加自定义 mcp 插件
-	c := buildCustomStdioMcpClient()
+	c := buildCustomStdioMcpServer()
 	defer c.Close()
-	addTools, err := client.AddMcpTools("Agent-A", c, mcp.Implementation{
-		Name:    "test",
-		Version: "1,0,0",
-	}, nil) // add all tools in mcp client
+	addTools, err := client.AddMcpTools("Agent-A", c, nil) // add all tools in mcp client
 	if err != nil {
 		log.Fatalf("Failed to AddMcpTools, error: %v", err)
 	}
@@ -75,7 +70,7 @@ func main() {
 		options := &model.Options{
 			StreamingThrottle: 5,
 		}
-		finalReply, err := client.Run(query, sessionID, visitorBizID, options)
+		finalReply, err := client.Run(query, options)
 		if err != nil {
 			log.Fatalf("run error: %v", err)
 		}
@@ -9,9 +9,9 @@ import (
 	"strings"
 
 	"github.com/google/uuid"
-	mcpclient "github.com/mark3labs/mcp-go/client"
 	"github.com/mark3labs/mcp-go/mcp"
 	lkesdk "github.com/tencent-lke/lke-sdk-go"
+	"github.com/tencent-lke/lke-sdk-go/mcpserversse"
 	"github.com/tencent-lke/lke-sdk-go/model"
 )
 
@@ -21,15 +21,12 @@ const (
 	botAppKey = "custom-app-key"
 )
 
-func buildFileSystemStdioMcpClient() mcpclient.MCPClient {
+func buildFileSystemStdioMcpServer() *mcpserversse.McpServerClient {
 	// 需要先安装 nodejs
-	c, err := mcpclient.NewStdioMCPClient(
-		"npx",
-		[]string{}, // Empty ENV
-		"-y",
-		"@modelcontextprotocol/server-filesystem",
-		"/tmp",
-	)
+	c, err := mcpserversse.NewMcpServer(mcpserversse.StdioTransport(mcpserversse.StdioCommand{
+		Command: "npx",
+		Args:    []string{"-y", "@modelcontextprotocol/server-filesystem", "/tmp"},
+	}), mcp.InitializeRequest{})
 	if err != nil {
 		log.Fatalf("Failed to create client: %v", err)
 	}
@@ -38,16 +35,14 @@ func buildFileSystemStdioMcpClient() mcpclient.MCPClient {
 
 func main() {
 	sessionID := uuid.New().String()
-	client := lkesdk.NewLkeClient(botAppKey, nil)
+	client := lkesdk.NewLkeClient(botAppKey, visitorBizID, sessionID, nil)
 	// client.SetMock(true) // mock run
 
 	// 增加 npx mcp 插件
-	c := buildFileSystemStdioMcpClient()
+	c := buildFileSystemStdioMcpServer()
+	defer c.Close()
 	// only add write_file, move_file tool
-	addTools, err := client.AddMcpTools("Agent-A", c, mcp.Implementation{
-		Name:    "test",
-		Version: "1,0,0",
-	}, []string{"write_file", "move_file"})
+	addTools, err := client.AddMcpTools("Agent-A", c, []string{"write_file", "move_file"})
 	if err != nil {
 		log.Fatalf("Failed to AddMcpTools, error: %v", err)
 	}
@@ -73,7 +68,7 @@ func main() {
 		options := &model.Options{
 			StreamingThrottle: 5,
 		}
-		finalReply, err := client.Run(query, sessionID, visitorBizID, options)
+		finalReply, err := client.Run(query, options)
 		if err != nil {
 			log.Fatalf("run error: %v", err)
 		}
@@ -83,7 +83,7 @@ func ToJsonString(valueI interface{}) string {
 
 func main() {
 	sessionID := uuid.New().String()
-	client := lkesdk.NewLkeClient(botAppKey, &MyEventHandler{
+	client := lkesdk.NewLkeClient(botAppKey, visitorBizID, sessionID, &MyEventHandler{
 		runNodeMap: make(map[string]*event.RunNodeInfo),
 	})
 	// client.SetMock(true)
@@ -93,7 +93,7 @@ func main() {
 			"ExcelFile": "custom-excel-file-url",
 		},
 	}
-	finalReply, err := client.Run(query, sessionID, visitorBizID, options)
+	finalReply, err := client.Run(query, options)
 	if err != nil {
 		log.Fatalf("run error: %v", err)
 	}
@@ -0,0 +1,525 @@
+// Package jsonschema 按 JSON Schema 校验 json 值
+//
+// 支持常用的校验关键字：type、enum、const、required、properties、patternProperties、additionalProperties、
+// propertyNames、minProperties、maxProperties、items、prefixItems、additionalItems、minItems、maxItems、uniqueItems、
+// minimum、maximum、exclusiveMinimum、exclusiveMaximum、multipleOf、minLength、maxLength、pattern、format、
+// allOf、anyOf、oneOf、not，以及文档内的 $ref（#/$defs/...、#/definitions/...）。
+// 不认识的关键字和 format 不做校验。
+package jsonschema
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// maxRefDepth $ref 的最大嵌套层数，防止循环引用
+const maxRefDepth = 64
+
+// FieldError 一个字段的校验错误
+type FieldError struct {
+	Path    string // 字段路径，根节点为 $，例如 $.items[0].name
+	Message string // 错误信息
+}
+
+// String 格式化成 "路径: 错误信息"
+func (e FieldError) String() string {
+	return e.Path + ": " + e.Message
+}
+
+// ValidationError 校验失败的错误，包括所有字段的错误
+type ValidationError struct {
+	Errors []FieldError
+}
+
+// Error 实现 error 接口
+func (e *ValidationError) Error() string {
+	msgs := make([]string, 0, len(e.Errors))
+	for _, fe := range e.Errors {
+		msgs = append(msgs, fe.String())
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// Validate 按 schema 校验 value，校验失败时返回 *ValidationError
+// value 一般是 json.Unmarshal 的结果，其他 go 类型的值会先转换成 json 再校验，schema 为空时不校验
+func Validate(schema map[string]interface{}, value interface{}) error {
+	if len(schema) == 0 {
+		return nil
+	}
+	root, err := normalize(schema)
+	if err != nil {
+		return fmt.Errorf("invalid schema: %w", err)
+	}
+	if value, err = normalize(value); err != nil {
+		return fmt.Errorf("invalid value: %w", err)
+	}
+	v := &validator{root: root}
+	if errs := v.validate(root, value, "$", 0); len(errs) > 0 {
+		return &ValidationError{Errors: errs}
+	}
+	return nil
+}
+
+// normalize 把值转换成 json.Unmarshal 的结果类型
+func normalize(v interface{}) (interface{}, error) {
+	if isJSONValue(v) {
+		return v, nil
+	}
+	bs, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var out interface{}
+	if err := json.Unmarshal(bs, &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// isJSONValue 是否已经是 json.Unmarshal 的结果类型
+func isJSONValue(v interface{}) bool {
+	switch x := v.(type) {
+	case nil, bool, float64, string:
+		return true
+	case []interface{}:
+		for _, item := range x {
+			if !isJSONValue(item) {
+				return false
+			}
+		}
+		return true
+	case map[string]interface{}:
+		for _, item := range x {
+			if !isJSONValue(item) {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// validator 一次校验的状态
+type validator struct {
+	root interface{}
+}
+
+// validate 按 schema 校验 value，返回所有错误
+func (v *validator) validate(schema interface{}, value interface{}, path string, depth int) []FieldError {
+	s, ok := schema.(map[string]interface{})
+	if !ok {
+		if b, ok := schema.(bool); ok && !b {
+			return []FieldError{{Path: path, Message: "no value is allowed"}}
+		}
+		// true 或者无法识别的 schema 不做校验
+		return nil
+	}
+	if nullable, _ := s["nullable"].(bool); nullable && value == nil {
+		return nil
+	}
+	var errs []FieldError
+	if ref, ok := s["$ref"].(string); ok && depth < maxRefDepth {
+		if target, ok := v.resolve(ref); ok {
+			errs = append(errs, v.validate(target, value, path, depth+1)...)
+		}
+	}
+	if t, ok := s["type"]; ok {
+		if !matchType(t, value) {
+			// 类型不对时其他关键字的错误没有意义
+			return append(errs, FieldError{Path: path,
+				Message: fmt.Sprintf("expected %s, got %s", typeNames(t), typeOf(value))})
+		}
+	}
+	if enum, ok := s["enum"].([]interface{}); ok && !contains(enum, value) {
+		errs = append(errs, FieldError{Path: path,
+			Message: fmt.Sprintf("must be one of %s, got %s", toJSON(enum), toJSON(value))})
+	}
+	if c, ok := s["const"]; ok && !reflect.DeepEqual(c, value) {
+		errs = append(errs, FieldError{Path: path, Message: fmt.Sprintf("must be %s", toJSON(c))})
+	}
+	switch x := value.(type) {
+	case map[string]interface{}:
+		errs = append(errs, v.validateObject(s, x, path, depth)...)
+	case []interface{}:
+		errs = append(errs, v.validateArray(s, x, path, depth)...)
+	case string:
+		errs = append(errs, validateString(s, x, path)...)
+	case float64:
+		errs = append(errs, validateNumber(s, x, path)...)
+	}
+	errs = append(errs, v.validateComposition(s, value, path, depth)...)
+	return errs
+}
+
+// validateObject 校验对象的关键字
+func (v *validator) validateObject(s map[string]interface{}, obj map[string]interface{},
+	path string, depth int) []FieldError {
+	var errs []FieldError
+	if required, ok := s["required"].([]interface{}); ok {
+		for _, r := range required {
+			name, _ := r.(string)
+			if _, ok := obj[name]; !ok && name != "" {
+				errs = append(errs, FieldError{Path: childPath(path, name),
+					Message: "required property is missing"})
+			}
+		}
+	}
+	if n, ok := s["minProperties"].(float64); ok && float64(len(obj)) < n {
+		errs = append(errs, FieldError{Path: path,
+			Message: fmt.Sprintf("must have at least %s properties, got %d", formatNumber(n), len(obj))})
+	}
+	if n, ok := s["maxProperties"].(float64); ok && float64(len(obj)) > n {
+		errs = append(errs, FieldError{Path: path,
+			Message: fmt.Sprintf("must have at most %s properties, got %d", formatNumber(n), len(obj))})
+	}
+	props, _ := s["properties"].(map[string]interface{})
+	patterns, _ := s["patternProperties"].(map[string]interface{})
+	additional, hasAdditional := s["additionalProperties"]
+	propertyNames, hasPropertyNames := s["propertyNames"]
+	for _, name := range sortedKeys(obj) {
+		value := obj[name]
+		p := childPath(path, name)
+		if hasPropertyNames {
+			for _, fe := range v.validate(propertyNames, name, p, depth) {
+				errs = append(errs, FieldError{Path: p, Message: "invalid property name: " + fe.Message})
+			}
+		}
+		matched := false
+		if ps, ok := props[name]; ok {
+			matched = true
+			errs = append(errs, v.validate(ps, value, p, depth)...)
+		}
+		for pattern, ps := range patterns {
+			if re := compile(pattern); re != nil && re.MatchString(name) {
+				matched = true
+				errs = append(errs, v.validate(ps, value, p, depth)...)
+			}
+		}
+		if matched || !hasAdditional {
+			continue
+		}
+		if b, ok := additional.(bool); ok && !b {
+			msg := "unknown property"
+			if len(props) > 0 {
+				msg += ", allowed properties: " + strings.Join(sortedKeys(props), ", ")
+			}
+			errs = append(errs, FieldError{Path: p, Message: msg})
+			continue
+		}
+		errs = append(errs, v.validate(additional, value, p, depth)...)
+	}
+	return errs
+}
+
+// validateArray 校验数组的关键字
+func (v *validator) validateArray(s map[string]interface{}, arr []interface{}, path string, depth int) []FieldError {
+	var errs []FieldError
+	if n, ok := s["minItems"].(float64); ok && float64(len(arr)) < n {
+		errs = append(errs, FieldError{Path: path,
+			Message: fmt.Sprintf("must have at least %s items, got %d", formatNumber(n), len(arr))})
+	}
+	if n, ok := s["maxItems"].(float64); ok && float64(len(arr)) > n {
+		errs = append(errs, FieldError{Path: path,
+			Message: fmt.Sprintf("must have at most %s items, got %d", formatNumber(n), len(arr))})
+	}
+	if unique, _ := s["uniqueItems"].(bool); unique {
+	outer:
+		for i := range arr {
+			for j := i + 1; j < len(arr); j++ {
+				if reflect.DeepEqual(arr[i], arr[j]) {
+					errs = append(errs, FieldError{Path: path,
+						Message: fmt.Sprintf("items must be unique, items %d and %d are equal", i, j)})
+					break outer
+				}
+			}
+		}
+	}
+	// prefixItems 或者数组形式的 items 按位置校验，剩余的元素按 items 或 additionalItems 校验
+	prefix, ok := s["prefixItems"].([]interface{})
+	rest, hasRest := s["items"]
+	if !ok {
+		if tuple, isTuple := rest.([]interface{}); isTuple {
+			prefix = tuple
+			rest, hasRest = s["additionalItems"]
+		}
+	}
+	for i, item := range arr {
+		p := path + "[" + strconv.Itoa(i) + "]"
+		switch {
+		case i < len(prefix):
+			errs = append(errs, v.validate(prefix[i], item, p, depth)...)
+		case hasRest:
+			if b, ok := rest.(bool); ok && !b {
+				errs = append(errs, FieldError{Path: p,
+					Message: fmt.Sprintf("must have at most %d items, got %d", len(prefix), len(arr))})
+				return errs
+			}
+			errs = append(errs, v.validate(rest, item, p, depth)...)
+		}
+	}
+	return errs
+}
+
+// validateString 校验字符串的关键字
+func validateString(s map[string]interface{}, str string, path string) []FieldError {
+	var errs []FieldError
+	length := utf8.RuneCountInString(str)
+	if n, ok := s["minLength"].(float64); ok && float64(length) < n {
+		errs = append(errs, FieldError{Path: path,
+			Message: fmt.Sprintf("length must be at least %s, got %d", formatNumber(n), length)})
+	}
+	if n, ok := s["maxLength"].(float64); ok && float64(length) > n {
+		errs = append(errs, FieldError{Path: path,
+			Message: fmt.Sprintf("length must be at most %s, got %d", formatNumber(n), length)})
+	}
+	if pattern, ok := s["pattern"].(string); ok {
+		if re := compile(pattern); re != nil && !re.MatchString(str) {
+			errs = append(errs, FieldError{Path: path, Message: fmt.Sprintf("must match pattern %q", pattern)})
+		}
+	}
+	if format, ok := s["format"].(string); ok {
+		if check, ok := formats[format]; ok && !check(str) {
+			errs = append(errs, FieldError{Path: path, Message: fmt.Sprintf("must be a valid %s", format)})
+		}
+	}
+	return errs
+}
+
+// validateNumber 校验数字的关键字，兼容 draft 4 中 bool 类型的 exclusiveMinimum/exclusiveMaximum
+func validateNumber(s map[string]interface{}, n float64, path string) []FieldError {
+	var errs []FieldError
+	exclusiveMin, _ := s["exclusiveMinimum"].(bool)
+	exclusiveMax, _ := s["exclusiveMaximum"].(bool)
+	if lo, ok := s["minimum"].(float64); ok {
+		if exclusiveMin && n <= lo {
+			errs = append(errs, numberError(path, ">", lo, n))
+		} else if n < lo {
+			errs = append(errs, numberError(path, ">=", lo, n))
+		}
+	}
+	if hi, ok := s["maximum"].(float64); ok {
+		if exclusiveMax && n >= hi {
+			errs = append(errs, numberError(path, "<", hi, n))
+		} else if n > hi {
+			errs = append(errs, numberError(path, "<=", hi, n))
+		}
+	}
+	if lo, ok := s["exclusiveMinimum"].(float64); ok && n <= lo {
+		errs = append(errs, numberError(path, ">", lo, n))
+	}
+	if hi, ok := s["exclusiveMaximum"].(float64); ok && n >= hi {
+		errs = append(errs, numberError(path, "<", hi, n))
+	}
+	if m, ok := s["multipleOf"].(float64); ok && m > 0 {
+		if q := n / m; math.Abs(q-math.Round(q)) > 1e-9 {
+			errs = append(errs, FieldError{Path: path,
+				Message: fmt.Sprintf("must be a multiple of %s, got %s", formatNumber(m), formatNumber(n))})
+		}
+	}
+	return errs
+}
+
+// numberError 数字范围的错误
+func numberError(path, op string, limit, n float64) FieldError {
+	return FieldError{Path: path,
+		Message: fmt.Sprintf("must be %s %s, got %s", op, formatNumber(limit), formatNumber(n))}
+}
+
+// validateComposition 校验 allOf、anyOf、oneOf、not
+func (v *validator) validateComposition(s map[string]interface{}, value interface{},
+	path string, depth int) []FieldError {
+	var errs []FieldError
+	if all, ok := s["allOf"].([]interface{}); ok {
+		for _, sub := range all {
+			errs = append(errs, v.validate(sub, value, path, depth)...)
+		}
+	}
+	if anyOf, ok := s["anyOf"].([]interface{}); ok {
+		if matched, closest := v.match(anyOf, value, path, depth); matched == 0 {
+			errs = append(errs, FieldError{Path: path, Message: "must match at least one schema in anyOf"})
+			errs = append(errs, closest...)
+		}
+	}
+	if oneOf, ok := s["oneOf"].([]interface{}); ok {
+		matched, closest := v.match(oneOf, value, path, depth)
+		switch {
+		case matched == 0:
+			errs = append(errs, FieldError{Path: path, Message: "must match exactly one schema in oneOf"})
+			errs = append(errs, closest...)
+		case matched > 1:
+			errs = append(errs, FieldError{Path: path,
+				Message: fmt.Sprintf("must match exactly one schema in oneOf, matched %d", matched)})
+		}
+	}
+	if not, ok := s["not"]; ok && len(v.validate(not, value, path, depth)) == 0 {
+		errs = append(errs, FieldError{Path: path, Message: "must not match the schema in not"})
+	}
+	return errs
+}
+
+// match 返回 value 匹配的 schema 个数，都不匹配时同时返回错误最少的 schema 的错误
+func (v *validator) match(schemas []interface{}, value interface{}, path string,
+	depth int) (matched int, closest []FieldError) {
+	for _, sub := range schemas {
+		errs := v.validate(sub, value, path, depth)
+		if len(errs) == 0 {
+			matched++
+		} else if closest == nil || len(errs) < len(closest) {
+			closest = errs
+		}
+	}
+	return matched, closest
+}
+
+// resolve 解析文档内的 $ref，只支持 # 开头的 json pointer
+func (v *validator) resolve(ref string) (interface{}, bool) {
+	if !strings.HasPrefix(ref, "#") {
+		return nil, false
+	}
+	cur := v.root
+	for _, token := range strings.Split(strings.TrimPrefix(ref, "#"), "/")[1:] {
+		token = strings.ReplaceAll(strings.ReplaceAll(token, "~1", "/"), "~0", "~")
+		switch x := cur.(type) {
+		case map[string]interface{}:
+			next, ok := x[token]
+			if !ok {
+				return nil, false
+			}
+			cur = next
+		case []interface{}:
+			i, err := strconv.Atoi(token)
+			if err != nil || i < 0 || i >= len(x) {
+				return nil, false
+			}
+			cur = x[i]
+		default:
+			return nil, false
+		}
+	}
+	return cur, true
+}
+
+// matchType value 是否匹配 type 关键字，type 可以是字符串或者字符串数组
+func matchType(t interface{}, value interface{}) bool {
+	switch x := t.(type) {
+	case string:
+		return isType(x, value)
+	case []interface{}:
+		for _, item := range x {
+			if name, ok := item.(string); ok && isType(name, value) {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+// isType value 是否为 json 类型 name，integer 包括没有小数部分的数字
+func isType(name string, value interface{}) bool {
+	actual := typeOf(value)
+	switch name {
+	case actual:
+		return true
+	case "number":
+		return actual == "integer"
+	}
+	return false
+}
+
+// typeOf value 的 json 类型
+func typeOf(value interface{}) string {
+	switch x := value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case float64:
+		if x == math.Trunc(x) && !math.IsInf(x, 0) {
+			return "integer"
+		}
+		return "number"
+	case string:
+		return "string"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	}
+	return fmt.Sprintf("%T", value)
+}
+
+// typeNames type 关键字的可读形式
+func typeNames(t interface{}) string {
+	if names, ok := t.([]interface{}); ok {
+		strs := make([]string, 0, len(names))
+		for _, n := range names {
+			strs = append(strs, fmt.Sprint(n))
+		}
+		return strings.Join(strs, " or ")
+	}
+	return fmt.Sprint(t)
+}
+
+// contains enum 是否包括 value
+func contains(enum []interface{}, value interface{}) bool {
+	for _, e := range enum {
+		if reflect.DeepEqual(e, value) {
+			return true
+		}
+	}
+	return false
+}
+
+// childPath 对象属性的路径
+func childPath(path, name string) string {
+	return path + "." + name
+}
+
+// sortedKeys 按字母序返回 map 的 key，保证错误的顺序稳定
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// formatNumber 格式化数字，整数不输出小数部分
+func formatNumber(n float64) string {
+	return strconv.FormatFloat(n, 'f', -1, 64)
+}
+
+// toJSON 转换成 json 字符串，用于错误信息
+func toJSON(v interface{}) string {
+	bs, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(bs)
+}
+
+// regexpCache 编译过的正则表达式缓存，schema 中的 pattern 一般是固定的
+var regexpCache sync.Map
+
+// compile 编译正则表达式，无效的正则表达式返回空，不做校验
+func compile(pattern string) *regexp.Regexp {
+	if re, ok := regexpCache.Load(pattern); ok {
+		return re.(*regexp.Regexp)
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil
+	}
+	regexpCache.Store(pattern, re)
+	return re
+}
@@ -0,0 +1,163 @@
+package jsonschema_test
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/tencent-lke/lke-sdk-go/jsonschema"
+)
+
+const orderSchema = `{
+	"type": "object",
+	"properties": {
+		"id": {"type": "integer", "minimum": 1},
+		"status": {"type": "string", "enum": ["paid", "shipped"]},
+		"email": {"type": "string", "format": "email"},
+		"code": {"type": "string", "pattern": "^[A-Z]{3}$", "maxLength": 3},
+		"price": {"type": "number", "exclusiveMinimum": 0, "multipleOf": 0.01},
+		"tags": {"type": "array", "items": {"type": "string"}, "minItems": 1, "uniqueItems": true},
+		"items": {"type": "array", "items": {"$ref": "#/$defs/item"}},
+		"note": {"type": ["string", "null"]},
+		"payment": {"oneOf": [
+			{"type": "object", "properties": {"card": {"type": "string"}}, "required": ["card"]},
+			{"type": "object", "properties": {"wallet": {"type": "string"}}, "required": ["wallet"]}
+		]}
+	},
+	"required": ["id", "status"],
+	"additionalProperties": false,
+	"$defs": {
+		"item": {
+			"type": "object",
+			"properties": {
+				"sku": {"type": "string", "minLength": 1},
+				"count": {"type": "integer", "maximum": 10},
+				"children": {"type": "array", "items": {"$ref": "#/$defs/item"}}
+			},
+			"required": ["sku"]
+		}
+	}
+}`
+
+func TestValidate(t *testing.T) {
+	schema := map[string]interface{}{}
+	if err := json.Unmarshal([]byte(orderSchema), &schema); err != nil {
+		t.Fatal(err)
+	}
+	cases := []struct {
+		name   string
+		args   string
+		except []jsonschema.FieldError
+	}{
+		{
+			name: "valid",
+			args: `{"id": 1, "status": "paid", "email": "a@b.com", "code": "ABC", "price": 9.99,
+				"tags": ["a", "b"], "note": null, "payment": {"card": "1234"},
+				"items": [{"sku": "x", "count": 2, "children": [{"sku": "y"}]}]}`,
+		},
+		{
+			name: "type and required",
+			args: `{"id": "1", "note": 1}`,
+			except: []jsonschema.FieldError{
+				{Path: "$.status", Message: "required property is missing"},
+				{Path: "$.id", Message: "expected integer, got string"},
+				{Path: "$.note", Message: "expected string or null, got integer"},
+			},
+		},
+		{
+			name: "constraints",
+			args: `{"id": 0, "status": "lost", "email": "bad", "code": "abcd", "price": 0,
+				"tags": ["a", "a"], "extra": true}`,
+			except: []jsonschema.FieldError{
+				{Path: "$.status", Message: "must be one of [\"paid\",\"shipped\"], got \"lost\""},
+				{Path: "$.code", Message: "length must be at most 3, got 4"},
+				{Path: "$.code", Message: "must match pattern \"^[A-Z]{3}$\""},
+				{Path: "$.email", Message: "must be a valid email"},
+				{Path: "$.extra", Message: "unknown property, allowed properties: code, email, id, items, " +
+					"note, payment, price, status, tags"},
+				{Path: "$.id", Message: "must be >= 1, got 0"},
+				{Path: "$.price", Message: "must be > 0, got 0"},
+				{Path: "$.tags", Message: "items must be unique, items 0 and 1 are equal"},
+			},
+		},
+		{
+			name: "nested ref",
+			args: `{"id": 1, "status": "paid", "items": [{"sku": "x", "children": [{"count": 11.5}]}]}`,
+			except: []jsonschema.FieldError{
+				{Path: "$.items[0].children[0].sku", Message: "required property is missing"},
+				{Path: "$.items[0].children[0].count", Message: "expected integer, got number"},
+			},
+		},
+		{
+			name: "one of",
+			args: `{"id": 1, "status": "paid", "payment": {"card": "1", "wallet": "2"}}`,
+			except: []jsonschema.FieldError{
+				{Path: "$.payment", Message: "must match exactly one schema in oneOf, matched 2"},
+			},
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			args := map[string]interface{}{}
+			if err := json.Unmarshal([]byte(c.args), &args); err != nil {
+				t.Fatal(err)
+			}
+			err := jsonschema.Validate(schema, args)
+			if c.except == nil {
+				if err != nil {
+					t.Fatalf("except valid, actual: %v", err)
+				}
+				return
+			}
+			var verr *jsonschema.ValidationError
+			if !errors.As(err, &verr) {
+				t.Fatalf("except validation error, actual: %v", err)
+			}
+			if !sameErrors(c.except, verr.Errors) {
+				t.Fatalf("except errors: %v\nactual errors: %v", c.except, verr.Errors)
+			}
+		})
+	}
+}
+
+// sameErrors 不考虑顺序比较错误
+func sameErrors(a, b []jsonschema.FieldError) bool {
+	count := map[jsonschema.FieldError]int{}
+	for _, e := range a {
+		count[e]++
+	}
+	for _, e := range b {
+		count[e]--
+	}
+	for _, n := range count {
+		if n != 0 {
+			return false
+		}
+	}
+	return len(a) == len(b)
+}
+
+func TestValidateGoValues(t *testing.T) {
+	// 代码生成的 schema 和参数可以是任意 go 类型
+	schema := map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"ids": map[string]interface{}{"type": "array", "items": map[string]interface{}{"type": "integer"}},
+		},
+		"required": []string{"ids"},
+	}
+	if err := jsonschema.Validate(schema, map[string]interface{}{"ids": []int{1, 2}}); err != nil {
+		t.Fatal(err)
+	}
+	err := jsonschema.Validate(schema, map[string]interface{}{})
+	except := &jsonschema.ValidationError{Errors: []jsonschema.FieldError{
+		{Path: "$.ids", Message: "required property is missing"},
+	}}
+	if !reflect.DeepEqual(err, except) {
+		t.Fatalf("except %v, actual %v", except, err)
+	}
+	if err := jsonschema.Validate(nil, "anything"); err != nil {
+		t.Fatal(err)
+	}
+}
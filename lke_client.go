@@ -2,17 +2,23 @@ package lkesdk
 
 import (
 	"context"
+	"iter"
 	"net/http"
 	"time"
 
-	"github.com/google/uuid"
 	"github.com/tencent-lke/lke-sdk-go/agentastool"
+	"github.com/tencent-lke/lke-sdk-go/approval"
 	"github.com/tencent-lke/lke-sdk-go/event"
 	"github.com/tencent-lke/lke-sdk-go/eventhandler"
 	"github.com/tencent-lke/lke-sdk-go/mcpserversse"
+	"github.com/tencent-lke/lke-sdk-go/metrics"
 	"github.com/tencent-lke/lke-sdk-go/model"
 	"github.com/tencent-lke/lke-sdk-go/runlog"
+	"github.com/tencent-lke/lke-sdk-go/runner"
 	"github.com/tencent-lke/lke-sdk-go/tool"
+	"github.com/tencent-lke/lke-sdk-go/usage"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // LkeClient represents a client for interacting with the LKE service
@@ -20,8 +26,12 @@ type LkeClient interface {
 	// AddFunctionTools 增加函数 tools
 	AddFunctionTools(agentName string, tools []*tool.FunctionTool)
 
-	// AddMcpTools 增加 mcptools
-	AddMcpTools(agentName string, mcpServerSse *mcpserversse.McpServerSse,
+	// AddTools 增加任意实现了 tool.Tool 的工具，例如 tool.NewTypedTool 创建的类型安全的工具
+	AddTools(agentName string, tools []tool.Tool)
+
+	// AddMcpTools 增加 mcptools，mcpServer 可以是 mcpserversse.NewMcpServer 通过 SSE、streamable HTTP、
+	// stdio 命令或者同一个进程中的 mcp-go server 创建的连接，也可以是 mcpserversse.McpServerSse
+	AddMcpTools(agentName string, mcpServer mcpserversse.McpServer,
 		selectedToolNames []string) (addTools []*tool.McpTool, err error)
 
 	AddAgentAsTool(agentName string, agentastoolName string,
@@ -33,7 +43,11 @@ type LkeClient interface {
 	// 其中 sourceAgentName, targetAgentNames 可以是应用对应的云上 agent，也可以是本地创建的 agent
 	AddHandoffs(sourceAgentName string, targetAgentNames []string)
 
-	// Run 执行 agent，query 用户的输入，sesionID 对话唯一标识，visitorBizID 用户的唯一标识
+	// NewSession 创建一个对话，visitorBizID 用户的唯一标识，sessionID 对话唯一标识
+	// 对话共用 client 上的 agent、工具和 handoff 配置，可以被多个协程并发使用
+	NewSession(visitorBizID, sessionID string) Session
+
+	// Run 在默认对话中执行 agent，query 用户的输入
 	// options 可选参数，可以为空。finalReply 最终的回复。
 	Run(query string,
 		options *model.Options) (finalReply *event.ReplyEvent, err error)
@@ -43,7 +57,20 @@ type LkeClient interface {
 	RunWithContext(ctx context.Context, query string,
 		options *model.Options) (finalReply *event.ReplyEvent, err error)
 
-	// Close 关闭所有 client 上的任务
+	// RunWithResult 执行 agent，同时返回最终回复和包括每轮请求、工具调用、token 用量的完整记录
+	// 执行失败时返回已经完成部分的记录
+	RunWithResult(ctx context.Context, query string,
+		options *model.Options) (*event.ReplyEvent, *runner.RunResult, error)
+
+	// Start 异步执行 agent，返回本次执行的句柄，可以单独取消或等待
+	Start(ctx context.Context, query string, options *model.Options) (RunHandle, error)
+
+	// RunStream 流式执行 agent，query 用户的输入，options 可选参数，可以为空
+	// 通过 for ev, err := range 依次获取回复、思考、引用、token 统计和工具调用事件
+	RunStream(ctx context.Context, query string,
+		options *model.Options) iter.Seq2[event.Event, error]
+
+	// Close 取消所有 client 上正在执行的任务，不等待结束，可以在本地工具中调用
 	Close()
 
 	// Open 已经 Close 的 client
@@ -86,18 +113,76 @@ type LkeClient interface {
 
 	// SetRunLogger 设置 sdk 执行日志 logger
 	SetRunLogger(logger runlog.RunLogger)
+
+	// SetLogger 设置分级的结构化日志 logger，为空不输出日志，runlog.NewSlog 可以适配 *slog.Logger
+	// 日志带有 run_id、session_id、agent、turn、tool 等字段
+	SetLogger(logger runlog.Logger)
+
+	// SetLogRedactKeys 设置日志中需要脱敏的字段，例如 CustomVariables 中的 key，bot_app_key 默认脱敏
+	SetLogRedactKeys(keys ...string)
+
+	// Validate 校验 agent、handoff 和工具配置，返回发现的问题列表
+	// 配置变更后第一次执行前会自动校验，存在 error 级别的问题时执行直接返回错误
+	Validate() runner.ValidationResult
+
+	// SetStrictValidation 设置严格校验，开启后 warning 级别的问题也会阻止执行
+	SetStrictValidation(strict bool)
+
+	// SetBudget 设置 token 预算，为空不限制，model.Options 中的 Budget 可以覆盖单次执行的预算
+	// 超过预算时执行返回 lkeerrors.ErrBudgetExceeded 和已经完成部分的执行记录
+	SetBudget(budget *model.Budget)
+
+	// SetPriceTable 设置计算费用的价格表，RunResult.UsageSummary 按价格表计算费用
+	SetPriceTable(prices usage.PriceTable)
+
+	// SetTracerProvider 设置 OpenTelemetry 的 TracerProvider，为空时使用全局的
+	// 执行、每轮云端调用、本地工具、mcp 工具和 agent 工具都会创建 span
+	SetTracerProvider(tp trace.TracerProvider)
+
+	// SetPropagator 设置向云端请求头注入 trace 上下文的 propagator，为空时使用全局的
+	SetPropagator(p propagation.TextMapPropagator)
+
+	// SetMetricsRecorder 设置指标记录，为空时不记录，Prometheus 的实现见 metrics/prommetrics
+	SetMetricsRecorder(recorder metrics.Recorder)
+
+	// Use 注册所有工具的中间件，中间件可以修改输入、替换输出或者直接返回，函数工具、mcp 工具和 agent 工具都生效
+	// 中间件的顺序为全局、agent、工具，同一范围内先注册的在外层
+	Use(middlewares ...tool.Middleware)
+
+	// UseForAgent 注册 agent 的所有工具的中间件
+	UseForAgent(agentName string, middlewares ...tool.Middleware)
+
+	// UseForTool 注册指定工具的中间件
+	UseForTool(toolName string, middlewares ...tool.Middleware)
+
+	// SetApprovalPolicy 设置工具调用的审批策略，每个工具可以自动执行、需要审批或者禁止执行，为空时都自动执行
+	// 被拒绝的调用不会执行，拒绝原因作为工具的输出提交给模型
+	SetApprovalPolicy(policy *approval.Policy)
+
+	// SetApprover 设置审批回调，需要审批的调用交给 approver 决定
+	// 为空时执行会暂停，返回 lkeerrors.ErrRunPaused，RunResult.Pending 中包括待审批的调用，审批后通过 Resume 继续
+	SetApprover(approver approval.Approver)
+
+	// Resume 从暂停的执行继续，pending 可以来自其他进程，对话为 pending 所属的对话
+	Resume(ctx context.Context, pending *runner.Pending,
+		input runner.ResumeInput) (*event.ReplyEvent, *runner.RunResult, error)
+
+	// SetRetryPolicy 设置调用云端接口的重试策略，为空不重试
+	// 重试复用同一个请求，已经执行过的本地工具不会重复执行
+	SetRetryPolicy(policy *runner.RetryPolicy)
 }
 
 // NewLkeClient creates a new LKE client with the provided parameters,
 // botAppKey 知识引擎应用 id,
 // eventHandler 自定义事件处理
-// visitorBizID 访客唯一标识
+// userID 默认对话的访客唯一标识，taskID 默认对话的唯一标识，client 上的 Run 系列方法使用默认对话
+// 服务多个用户或多个对话时，使用 NewSession 创建对话，agent、工具等配置只需要在 client 上注册一次
 func NewLkeClient(botAppKey string, userID string, taskID string, eventHandler eventhandler.EventHandler) LkeClient {
 	handler := eventHandler
 	if handler == nil {
 		handler = &eventhandler.DefaultEventHandler{}
 	}
-	return &lkeClient{
+	c := &lkeClient{
 		botAppKey:    botAppKey,
 		endpoint:     DefaultEndpoint,
 		eventHandler: handler,
@@ -105,8 +190,10 @@ func NewLkeClient(botAppKey string, userID string, taskID string, eventHandler e
 		mock:         false,
 		httpClient:   http.DefaultClient,
 		maxToolTurns: 10,
-		requestID:    uuid.New().String(),
-		sessionID:    taskID,
-		visitorBizID: userID,
+		logger:       runlog.Nop{},
+		middlewares:  &tool.Middlewares{},
+		runs:         map[string]*runHandle{},
 	}
+	c.defaultSession = c.NewSession(userID, taskID).(*session)
+	return c
 }
@@ -0,0 +1,258 @@
+package lkesdk_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/tencent-lke/lke-sdk-go/approval"
+	"github.com/tencent-lke/lke-sdk-go/event"
+	"github.com/tencent-lke/lke-sdk-go/lkeerrors"
+	"github.com/tencent-lke/lke-sdk-go/lketest"
+	"github.com/tencent-lke/lke-sdk-go/model"
+	"github.com/tencent-lke/lke-sdk-go/runner"
+	"github.com/tencent-lke/lke-sdk-go/tool"
+)
+
+func TestRunApproval(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		mode     approval.Mode
+		approver approval.Approver
+		calls    int32
+		output   string
+	}{
+		{name: "approved", mode: approval.ModeRequired, calls: 1, output: "3",
+			approver: approval.ApproverFunc(func(ctx context.Context, req approval.Request) (approval.Decision, error) {
+				return approval.Approve(), nil
+			})},
+		{name: "rejected", mode: approval.ModeRequired, output: "too expensive",
+			approver: approval.ApproverFunc(func(ctx context.Context, req approval.Request) (approval.Decision, error) {
+				if req.ToolName != "add" || req.AgentName != "Agent-A" || req.Arguments["a"] != float64(1) {
+					t.Errorf("unexpected approval request: %+v", req)
+				}
+				return approval.Reject("too expensive"), nil
+			})},
+		{name: "denied", mode: approval.ModeDenied, output: "denied by policy"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			srv := lketest.NewServer(
+				lketest.NewTurn(lketest.Interrupt("Agent-A", lketest.ToolCall("call-1", "add", `{"a":1,"b":2}`))),
+				lketest.NewTurn(lketest.Reply("done")),
+			)
+			defer srv.Close()
+			var calls int32
+			client := newTestClient(t, srv, &calls)
+			client.SetApprovalPolicy(&approval.Policy{Tools: map[string]approval.Mode{"Agent-A/add": tc.mode}})
+			client.SetApprover(tc.approver)
+			_, result, err := client.RunWithResult(context.Background(), "1+2", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if atomic.LoadInt32(&calls) != tc.calls {
+				t.Fatalf("except %d tool calls, actual: %d", tc.calls, calls)
+			}
+			output := srv.LastRequest().ToolOuputs[0].Output
+			if !strings.Contains(output, tc.output) {
+				t.Fatalf("except tool output contains %q, actual: %s", tc.output, output)
+			}
+			record := result.Turns[0].ToolCalls[0]
+			if (tc.calls == 0) != (record.Rejected != "") {
+				t.Fatalf("unexpected tool call record: %+v", record)
+			}
+		})
+	}
+}
+
+func TestRunApprovalAgentAsTool(t *testing.T) {
+	srv := lketest.NewServer(
+		lketest.NewTurn(lketest.Interrupt("Agent-A", lketest.ToolCall("call-1", "ask_b", `{"query":"1+2"}`))),
+		// agent 工具的嵌套执行要求调用需要审批的工具
+		lketest.NewTurn(lketest.Interrupt("Agent-B", lketest.ToolCall("call-2", "add", `{"a":1,"b":2}`))),
+		lketest.NewTurn(lketest.Reply("rejected")),
+		lketest.NewTurn(lketest.Reply("done")),
+	)
+	defer srv.Close()
+	var calls, nestedCalls int32
+	client := newTestClient(t, srv, &calls)
+	client.AddAgents([]model.Agent{
+		model.NewAgent("Agent-B", "agent b", "agent b", model.DefaultModel, nil, nil),
+	})
+	add, err := tool.NewFunctionTool("add", "add two numbers", func(p addParams) int {
+		atomic.AddInt32(&nestedCalls, 1)
+		return p.A + p.B
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.AddFunctionTools("Agent-B", []*tool.FunctionTool{add})
+	client.SetApprovalPolicy(&approval.Policy{Tools: map[string]approval.Mode{"Agent-B/add": approval.ModeRequired}})
+	if _, err := client.AddAgentAsTool("Agent-A", "Agent-B", "ask_b", "ask agent b"); err != nil {
+		t.Fatal(err)
+	}
+	// 没有 Approver 时嵌套执行不暂停，需要审批的调用直接拒绝
+	reply, result, err := client.RunWithResult(context.Background(), "1+2", nil)
+	if err != nil || reply.Content != "done" || result.Pending != nil {
+		t.Fatalf("except run finished without pause, actual: %v", err)
+	}
+	requests := srv.Requests()
+	if nestedCalls != 0 || len(requests) != 4 || !strings.Contains(requests[2].ToolOuputs[0].Output, "rejected") {
+		t.Fatalf("except nested call rejected, actual calls: %d, requests: %d", nestedCalls, len(requests))
+	}
+	if output := result.Turns[0].ToolCalls[0].Output; !strings.Contains(output, `"content":"rejected"`) {
+		t.Fatalf("except nested reply as tool output, actual: %s", output)
+	}
+}
+
+func TestRunPauseAndResume(t *testing.T) {
+	srv := lketest.NewServer(
+		lketest.NewTurn(lketest.TokenStat(event.TokenStatEvent{TokenCount: 100}),
+			lketest.Interrupt("Agent-A", lketest.ToolCall("call-1", "add", `{"a":1,"b":2}`))),
+		lketest.NewTurn(lketest.TokenStat(event.TokenStatEvent{TokenCount: 50}), lketest.Reply("3")),
+	)
+	defer srv.Close()
+	var calls int32
+	client := newTestClient(t, srv, &calls)
+	client.SetApprovalPolicy(&approval.Policy{Default: approval.ModeRequired})
+	customVars := map[string]string{"token": "secret-token"}
+	reply, result, err := client.RunWithResult(context.Background(), "1+2",
+		&model.Options{CustomVariables: customVars})
+	if !errors.Is(err, lkeerrors.ErrRunPaused) || reply != nil {
+		t.Fatalf("except paused, actual: %v", err)
+	}
+	if calls != 0 || len(srv.Requests()) != 1 {
+		t.Fatalf("except no tool calls before approval, actual calls: %d", calls)
+	}
+	if result.Pending == nil || len(result.Pending.Approvals) != 1 ||
+		result.Pending.Approvals[0].CallID != "call-1" || result.Pending.Approvals[0].Arguments["b"] != float64(2) {
+		t.Fatalf("unexpected pending: %+v", result.Pending)
+	}
+	bs, err := json.Marshal(result.Pending)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(bs), "test-app-key") || strings.Contains(string(bs), "secret-token") {
+		t.Fatalf("except no bot_app_key and custom variables in pending: %s", bs)
+	}
+	pending := &runner.Pending{}
+	if err := json.Unmarshal(bs, pending); err != nil {
+		t.Fatal(err)
+	}
+
+	// 没有审批结果时再次暂停，暂停前的用量保留在新的 Pending 中
+	_, result, err = client.Resume(context.Background(), pending, runner.ResumeInput{})
+	if !errors.Is(err, lkeerrors.ErrRunPaused) || result.Tokens() != 100 {
+		t.Fatalf("except paused again with 100 tokens, actual: %v, %d", err, result.Tokens())
+	}
+	pending = result.Pending
+	reply, result, err = client.Resume(context.Background(), pending, runner.ResumeInput{
+		Decisions:       map[string]approval.Decision{"call-1": approval.Approve()},
+		CustomVariables: customVars,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reply.Content != "3" || calls != 1 {
+		t.Fatalf("unexpected reply: %v, calls: %d", reply.Content, calls)
+	}
+	if len(result.Turns) != 2 || result.Turns[0].Index != 0 || result.Turns[1].Index != 1 {
+		t.Fatalf("unexpected turns: %+v", result.Turns)
+	}
+	if result.Tokens() != 150 {
+		t.Fatalf("except tokens before pause counted, actual: %d", result.Tokens())
+	}
+	req := srv.LastRequest()
+	if req.BotAppKey != "test-app-key" || req.ToolOuputs[0].Output != "3" || req.CustomVariables["token"] != "secret-token" {
+		t.Fatalf("unexpected resumed request: %+v", req)
+	}
+}
+
+func TestRunSuspendOnInterrupt(t *testing.T) {
+	srv := lketest.NewServer(
+		lketest.NewTurn(lketest.Interrupt("Agent-A", lketest.ToolCall("call-1", "add", `{"a":40,"b":2}`))),
+		lketest.NewTurn(lketest.Interrupt("Agent-A", lketest.ToolCall("call-2", "add", `{"a":1,"b":2}`))),
+		lketest.NewTurn(lketest.Reply("done")),
+	)
+	defer srv.Close()
+	var calls int32
+	client := newTestClient(t, srv, &calls)
+	_, result, err := client.RunWithResult(context.Background(), "1+2", &model.Options{SuspendOnInterrupt: true})
+	if !errors.Is(err, lkeerrors.ErrRunPaused) {
+		t.Fatalf("except paused, actual: %v", err)
+	}
+	pending := result.Pending
+	if pending == nil || pending.Turn != 0 || pending.CurrentAgent != "Agent-A" || !pending.Suspend ||
+		len(pending.ToolCalls()) != 1 || len(pending.Approvals) != 0 {
+		t.Fatalf("unexpected pending: %+v", pending)
+	}
+	bs, err := json.Marshal(pending)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 在另一个 client 上继续执行，工具输出由外部提供
+	other := newTestClient(t, srv, &calls)
+	restored := &runner.Pending{}
+	if err := json.Unmarshal(bs, restored); err != nil {
+		t.Fatal(err)
+	}
+	_, result, err = other.Resume(context.Background(), restored, runner.ResumeInput{
+		Outputs: map[string]string{"call-1": "42"},
+	})
+	if !errors.Is(err, lkeerrors.ErrRunPaused) || result.Pending.Turn != 1 {
+		t.Fatalf("except paused at turn 1, actual: %v", err)
+	}
+	if calls != 0 || srv.LastRequest().ToolOuputs[0].Output != "42" || !result.Turns[0].ToolCalls[0].External {
+		t.Fatalf("except external output submitted, actual calls: %d", calls)
+	}
+
+	// 没有提供输出的调用在本地执行
+	reply, _, err := other.Resume(context.Background(), result.Pending, runner.ResumeInput{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reply.Content != "done" || calls != 1 || srv.LastRequest().ToolOuputs[0].Output != "3" {
+		t.Fatalf("unexpected reply: %v, calls: %d", reply.Content, calls)
+	}
+}
+
+func TestResumeBudget(t *testing.T) {
+	stat := lketest.TokenStat(event.TokenStatEvent{
+		Procedures: []event.Procedure{{Name: event.ProcedureLLM, Count: 100}},
+	})
+	interrupt := lketest.Interrupt("Agent-A", lketest.ToolCall("call-1", "add", `{"a":1,"b":2}`))
+	srv := lketest.NewServer(lketest.NewTurn(stat, interrupt), lketest.NewTurn(stat, lketest.Reply("done")))
+	defer srv.Close()
+	var calls int32
+	client := newTestClient(t, srv, &calls)
+	client.SetBudget(&model.Budget{MaxTotalTokens: 1000})
+	_, result, err := client.RunWithResult(context.Background(), "1+2", &model.Options{SuspendOnInterrupt: true})
+	if !errors.Is(err, lkeerrors.ErrRunPaused) {
+		t.Fatalf("except paused, actual: %v", err)
+	}
+	// client 上的预算不保存到 Pending，继续执行时使用 client 当前的预算
+	if result.Pending.Budget != nil {
+		t.Fatalf("except no budget in pending, actual: %+v", result.Pending.Budget)
+	}
+	client.SetBudget(&model.Budget{MaxTotalTokens: 150})
+	if _, _, err := client.Resume(context.Background(), result.Pending, runner.ResumeInput{}); !errors.Is(err,
+		lkeerrors.ErrBudgetExceeded) {
+		t.Fatalf("except current client budget exceeded, actual: %v", err)
+	}
+
+	// model.Options 中的预算保存到 Pending，覆盖 client 的预算
+	srv.Enqueue(lketest.NewTurn(stat, interrupt))
+	srv.Enqueue(lketest.NewTurn(stat, lketest.Reply("done")))
+	options := &model.Options{SuspendOnInterrupt: true, Budget: &model.Budget{MaxTotalTokens: 1000}}
+	_, result, err = client.RunWithResult(context.Background(), "1+2", options)
+	if !errors.Is(err, lkeerrors.ErrRunPaused) || result.Pending.Budget != options.Budget {
+		t.Fatalf("except paused with options budget, actual: %v", err)
+	}
+	reply, _, err := client.Resume(context.Background(), result.Pending, runner.ResumeInput{})
+	if err != nil || reply.Content != "done" {
+		t.Fatalf("except done within options budget, actual: %v", err)
+	}
+}
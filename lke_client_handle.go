@@ -0,0 +1,184 @@
+package lkesdk
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+
+	"github.com/google/uuid"
+	"github.com/tencent-lke/lke-sdk-go/event"
+	"github.com/tencent-lke/lke-sdk-go/eventhandler"
+	"github.com/tencent-lke/lke-sdk-go/lkeerrors"
+	"github.com/tencent-lke/lke-sdk-go/model"
+	"github.com/tencent-lke/lke-sdk-go/runlog"
+	"github.com/tencent-lke/lke-sdk-go/runner"
+)
+
+// RunStatus 单次执行的状态
+type RunStatus string
+
+// 执行状态枚举
+const (
+	RunStatusRunning   RunStatus = "running"   // 执行中
+	RunStatusSucceeded RunStatus = "succeeded" // 执行成功
+	RunStatusFailed    RunStatus = "failed"    // 执行失败
+	RunStatusCanceled  RunStatus = "canceled"  // 已取消
+	RunStatusPaused    RunStatus = "paused"    // 已暂停，等待 Resume
+)
+
+// RunHandle 单次执行的句柄，同一个 client 上的并发执行可以分别取消
+type RunHandle interface {
+	// ID 本次执行的唯一标识
+	ID() string
+
+	// Cancel 取消本次执行，正在进行的 http 请求和本地工具调用都会收到 context 取消
+	Cancel()
+
+	// Wait 等待执行结束，返回最终回复
+	Wait() (finalReply *event.ReplyEvent, err error)
+
+	// Status 当前执行状态
+	Status() RunStatus
+
+	// Result 执行结束后返回完整的执行记录，执行中或者没有开始请求云端时返回空
+	Result() *runner.RunResult
+}
+
+// runHandle RunHandle 的实现
+type runHandle struct {
+	id     string
+	cancel context.CancelFunc
+	done   chan struct{}
+
+	mu     sync.Mutex
+	status RunStatus
+	reply  *event.ReplyEvent
+	result *runner.RunResult
+	err    error
+}
+
+// ID 本次执行的唯一标识
+func (h *runHandle) ID() string {
+	return h.id
+}
+
+// Cancel 取消本次执行
+func (h *runHandle) Cancel() {
+	h.cancel()
+}
+
+// Wait 等待执行结束，返回最终回复
+func (h *runHandle) Wait() (*event.ReplyEvent, error) {
+	<-h.done
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.reply, h.err
+}
+
+// Status 当前执行状态
+func (h *runHandle) Status() RunStatus {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.status
+}
+
+// Result 执行结束后返回完整的执行记录
+func (h *runHandle) Result() *runner.RunResult {
+	select {
+	case <-h.done:
+	default:
+		return nil
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.result
+}
+
+func (h *runHandle) finish(ctx context.Context, result *runner.RunResult, err error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if result != nil {
+		h.reply = result.FinalReply
+	}
+	h.result = result
+	h.err = err
+	switch {
+	case err == nil:
+		h.status = RunStatusSucceeded
+	case errors.Is(err, lkeerrors.ErrRunPaused):
+		h.status = RunStatusPaused
+	case ctx.Err() != nil:
+		h.status = RunStatusCanceled
+	default:
+		h.status = RunStatusFailed
+	}
+	close(h.done)
+}
+
+// Start 异步执行 agent，返回本次执行的句柄
+func (c *lkeClient) Start(ctx context.Context, query string, options *model.Options) (RunHandle, error) {
+	return c.defaultSession.Start(ctx, query, options)
+}
+
+// start 在指定对话中使用指定的事件处理器异步执行 agent
+func (c *lkeClient) start(ctx context.Context, s *session, query string,
+	options *model.Options, handler eventhandler.EventHandler) (*runHandle, error) {
+	return c.startFunc(ctx, func(runCtx context.Context) (*runner.RunResult, error) {
+		return c.runWithHandler(runCtx, s, query, options, handler)
+	})
+}
+
+// startFunc 异步执行 run，执行期间可以通过句柄取消，Close 时会被取消
+func (c *lkeClient) startFunc(ctx context.Context,
+	run func(runCtx context.Context) (*runner.RunResult, error)) (*runHandle, error) {
+	runCtx, cancel := context.WithCancel(ctx)
+	h := &runHandle{
+		id:     uuid.New().String(),
+		cancel: cancel,
+		done:   make(chan struct{}),
+		status: RunStatusRunning,
+	}
+	c.runsMu.Lock()
+	if c.closed {
+		c.runsMu.Unlock()
+		cancel()
+		return nil, lkeerrors.ErrClientClosed
+	}
+	c.runs[h.id] = h
+	c.runsMu.Unlock()
+
+	go func() {
+		defer cancel()
+		result, err := run(runCtx)
+		c.runsMu.Lock()
+		delete(c.runs, h.id)
+		c.runsMu.Unlock()
+		h.finish(runCtx, result, err)
+	}()
+	return h, nil
+}
+
+// Close 取消 client 上所有正在执行的任务，不等待它们结束，需要等待时使用 RunHandle.Wait
+// 本地工具中也可以调用 Close，Close 之后新的执行会直接返回错误，直到调用 Open
+func (c *lkeClient) Close() {
+	runlog.Log(context.Background(), c.getLogger(), nil, slog.LevelWarn, "client closed by user")
+	c.runsMu.Lock()
+	c.closed = true
+	handles := make([]*runHandle, 0, len(c.runs))
+	for _, h := range c.runs {
+		handles = append(handles, h)
+	}
+	c.runsMu.Unlock()
+	for _, h := range handles {
+		h.Cancel()
+	}
+}
+
+// Open 重新打开已经 Close 的 client
+func (c *lkeClient) Open() {
+	runlog.Log(context.Background(), c.getLogger(), nil, slog.LevelInfo, "client open by user")
+	c.runsMu.Lock()
+	c.closed = false
+	c.runsMu.Unlock()
+}
@@ -0,0 +1,79 @@
+package lkesdk_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	lkesdk "github.com/tencent-lke/lke-sdk-go"
+	"github.com/tencent-lke/lke-sdk-go/lkeerrors"
+	"github.com/tencent-lke/lke-sdk-go/lketest"
+	"github.com/tencent-lke/lke-sdk-go/tool"
+)
+
+func TestRunHandleCancel(t *testing.T) {
+	srv := lketest.NewServer(
+		lketest.NewTurn(lketest.Interrupt("Agent-A", lketest.ToolCall("call-1", "sleep", `{}`))),
+	)
+	defer srv.Close()
+	var calls int32
+	client := newTestClient(t, srv, &calls)
+	started := make(chan struct{})
+	sleep, err := tool.NewFunctionTool("sleep", "sleep until canceled",
+		func(ctx context.Context, p struct{}) string {
+			close(started)
+			<-ctx.Done()
+			return "canceled"
+		}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.AddFunctionTools("Agent-A", []*tool.FunctionTool{sleep})
+	h, err := client.Start(context.Background(), "sleep", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	<-started
+	h.Cancel()
+	if _, err := h.Wait(); !errors.Is(err, context.Canceled) {
+		t.Fatalf("except context.Canceled, actual: %v", err)
+	}
+	if h.Status() != lkesdk.RunStatusCanceled {
+		t.Fatalf("except status canceled, actual: %s", h.Status())
+	}
+}
+
+func TestCloseInTool(t *testing.T) {
+	srv := lketest.NewServer(
+		lketest.NewTurn(lketest.Interrupt("Agent-A", lketest.ToolCall("call-1", "close", `{}`))),
+	)
+	defer srv.Close()
+	var calls int32
+	client := newTestClient(t, srv, &calls)
+	closeTool, err := tool.NewFunctionTool("close", "close the client",
+		func(ctx context.Context, p struct{}) string {
+			client.Close()
+			return "closed"
+		}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.AddFunctionTools("Agent-A", []*tool.FunctionTool{closeTool})
+	done := make(chan error, 1)
+	go func() {
+		_, err := client.Run("close", nil)
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.Canceled) {
+			t.Fatalf("except context.Canceled, actual: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Close called in a tool blocks the run")
+	}
+	if _, err := client.Run("hi", nil); !errors.Is(err, lkeerrors.ErrClientClosed) {
+		t.Fatalf("except ErrClientClosed after Close, actual: %v", err)
+	}
+}
@@ -2,20 +2,30 @@ package lkesdk
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"log/slog"
 	"net/http"
+	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/google/uuid"
 	"github.com/openai/openai-go"
 	"github.com/tencent-lke/lke-sdk-go/agentastool"
+	"github.com/tencent-lke/lke-sdk-go/approval"
 	"github.com/tencent-lke/lke-sdk-go/event"
 	"github.com/tencent-lke/lke-sdk-go/eventhandler"
 	"github.com/tencent-lke/lke-sdk-go/mcpserversse"
+	"github.com/tencent-lke/lke-sdk-go/metrics"
 	"github.com/tencent-lke/lke-sdk-go/model"
 	"github.com/tencent-lke/lke-sdk-go/runlog"
 	"github.com/tencent-lke/lke-sdk-go/runner"
 	"github.com/tencent-lke/lke-sdk-go/tool"
+	"github.com/tencent-lke/lke-sdk-go/usage"
 	"github.com/tencent-lke/lke-sdk-go/util"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
 )
 
 const (
@@ -36,59 +46,92 @@ type lkeClient struct {
 	handoffs        []model.Handoff
 	enableSystemOpt bool
 	startAgent      string
-	logger          runlog.RunLogger
+	logger          runlog.Logger
+	redactKeys      []string
 	toolRunTimeout  time.Duration
 	maxToolTurns    uint // 单次对话本地工具调用最大次数
+	retryPolicy     *runner.RetryPolicy
+	budget          *model.Budget
+	priceTable      usage.PriceTable
+	tracerProvider  trace.TracerProvider
+	propagator      propagation.TextMapPropagator
+	metrics         metrics.Recorder
+	approvalPolicy  *approval.Policy
+	approver        approval.Approver
+	middlewares     *tool.Middlewares
+	strictValidate  bool
+	validated       atomic.Bool // 当前配置是否已经校验通过，配置变更后重置
 	// closed          atomic.Bool
-	requestID    string
-	sessionID    string
-	visitorBizID string
-	runnerImpl   *runner.RunnerImp
-	agentTools   []*agentastool.AgentAsTool
+	defaultSession *session // NewLkeClient 时指定的默认对话
+
+	mu     sync.RWMutex // 保护上面的配置，运行时读取配置快照，运行期间可以安全修改配置
+	runsMu sync.Mutex
+	runs   map[string]*runHandle // runID -> 正在执行的任务
+	closed bool
 }
 
 // GetBotAppKey 获取 BotAppKey
 func (c *lkeClient) GetBotAppKey() string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
 	return c.botAppKey
 }
 
 // SetBotAppKey sets the bot application key
 func (c *lkeClient) SetBotAppKey(botAppKey string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
 	c.botAppKey = botAppKey
 }
 
 // GetEndpoint returns the endpoint URL
 func (c *lkeClient) GetEndpoint() string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
 	return c.endpoint
 }
 
 // SetEndpoint sets the endpoint URL
 func (c *lkeClient) SetEndpoint(endpoint string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
 	c.endpoint = endpoint
 }
 
 // SetEventHandler 设置时间处理函数
 func (c *lkeClient) SetEventHandler(eventHandler eventhandler.EventHandler) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
 	c.eventHandler = eventHandler
 }
 
 // SetMock 设置 Mock
 func (c *lkeClient) SetMock(mock bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
 	c.mock = mock
 }
 
 // SetEnableSystemOpt 配置 agent 运行时的系统优化开关
 func (c *lkeClient) SetEnableSystemOpt(enable bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.validated.Store(false)
 	c.enableSystemOpt = enable
 }
 
 // SetStartAgent 设置开始执行的入口 agent
 func (c *lkeClient) SetStartAgent(agentName string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.validated.Store(false)
 	c.startAgent = agentName
 }
 
 // SetHttpClient 设置自定义 http client
 func (c *lkeClient) SetHttpClient(cli *http.Client) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
 	if cli != nil {
 		c.httpClient = cli
 	}
@@ -97,22 +140,159 @@ func (c *lkeClient) SetHttpClient(cli *http.Client) {
 // SetMaxToolTurns TODO
 // SetHttpClient 设置单轮对话，本地工具调用的最大轮数，不设置默认为 10
 func (c *lkeClient) SetMaxToolTurns(maxToolTurns uint) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
 	c.maxToolTurns = maxToolTurns
 }
 
 // SetToolRunTimeout TODO
 // SetHttpClient 设置本地工具调用的超时时间
 func (c *lkeClient) SetToolRunTimeout(toolRunTimeout time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
 	c.toolRunTimeout = toolRunTimeout
 }
 
 // SetRunLogger 设置 sdk 执行日志 logger
 func (c *lkeClient) SetRunLogger(logger runlog.RunLogger) {
+	c.SetLogger(runlog.FromRunLogger(logger))
+}
+
+// SetLogger 设置分级的结构化日志 logger，为空不输出日志
+func (c *lkeClient) SetLogger(logger runlog.Logger) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if logger == nil {
+		logger = runlog.Nop{}
+	}
 	c.logger = logger
 }
 
+// SetLogRedactKeys 设置日志中需要脱敏的字段，例如 CustomVariables 中的 key，bot_app_key 默认脱敏
+func (c *lkeClient) SetLogRedactKeys(keys ...string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.redactKeys = append([]string{}, keys...)
+}
+
+// SetRetryPolicy 设置调用云端接口的重试策略，为空不重试
+func (c *lkeClient) SetRetryPolicy(policy *runner.RetryPolicy) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.retryPolicy = policy
+}
+
+// SetBudget 设置 token 预算，为空不限制
+func (c *lkeClient) SetBudget(budget *model.Budget) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.budget = budget
+}
+
+// SetPriceTable 设置计算费用的价格表
+func (c *lkeClient) SetPriceTable(prices usage.PriceTable) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.priceTable = prices
+}
+
+// SetTracerProvider 设置 OpenTelemetry 的 TracerProvider
+func (c *lkeClient) SetTracerProvider(tp trace.TracerProvider) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.tracerProvider = tp
+}
+
+// SetPropagator 设置向云端请求头注入 trace 上下文的 propagator
+func (c *lkeClient) SetPropagator(p propagation.TextMapPropagator) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.propagator = p
+}
+
+// SetMetricsRecorder 设置指标记录
+func (c *lkeClient) SetMetricsRecorder(recorder metrics.Recorder) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.metrics = recorder
+}
+
+// SetApprovalPolicy 设置工具调用的审批策略，为空时都自动执行
+func (c *lkeClient) SetApprovalPolicy(policy *approval.Policy) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.approvalPolicy = policy
+}
+
+// Use 注册所有工具的中间件，先注册的在外层
+func (c *lkeClient) Use(middlewares ...tool.Middleware) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.middlewares.Use(middlewares...)
+}
+
+// UseForAgent 注册 agent 的所有工具的中间件
+func (c *lkeClient) UseForAgent(agentName string, middlewares ...tool.Middleware) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.middlewares.UseForAgent(agentName, middlewares...)
+}
+
+// UseForTool 注册指定工具的中间件
+func (c *lkeClient) UseForTool(toolName string, middlewares ...tool.Middleware) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.middlewares.UseForTool(toolName, middlewares...)
+}
+
+// SetApprover 设置审批回调，为空时需要审批的调用会暂停执行
+func (c *lkeClient) SetApprover(approver approval.Approver) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.approver = approver
+}
+
+// SetStrictValidation 设置严格校验，开启后 warning 级别的问题也会阻止执行
+func (c *lkeClient) SetStrictValidation(strict bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.validated.Store(false)
+	c.strictValidate = strict
+}
+
+// Validate 校验 agent、handoff 和工具配置，返回发现的问题列表
+func (c *lkeClient) Validate() runner.ValidationResult {
+	return c.newRunner(c.getEventHandler(), nil).Validate()
+}
+
+// validate 配置变更后第一次执行前校验配置
+func (c *lkeClient) validate(ctx context.Context, r *runner.RunnerImp, logger runlog.Logger) error {
+	if c.validated.Load() {
+		return nil
+	}
+	c.mu.RLock()
+	strict := c.strictValidate
+	c.mu.RUnlock()
+	result := r.Validate()
+	for _, issue := range result {
+		level := slog.LevelWarn
+		if issue.Severity == runner.SeverityError {
+			level = slog.LevelError
+		}
+		runlog.Log(ctx, logger, nil, level, "validate config", slog.String("issue", issue.String()))
+	}
+	if err := result.Err(strict); err != nil {
+		return err
+	}
+	c.validated.Store(true)
+	return nil
+}
+
 // AddFunctionTools 增加函数 tools
 func (c *lkeClient) AddFunctionTools(agentName string, tools []*tool.FunctionTool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.validated.Store(false)
 	if len(tools) == 0 {
 		return
 	}
@@ -130,10 +310,22 @@ func (c *lkeClient) AddFunctionTools(agentName string, tools []*tool.FunctionToo
 	}
 }
 
+// AddTools 增加任意实现了 tool.Tool 的工具
+func (c *lkeClient) AddTools(agentName string, tools []tool.Tool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.validated.Store(false)
+	for _, t := range tools {
+		if t != nil {
+			c.toolsMap[agentName] = append(c.toolsMap[agentName], t)
+		}
+	}
+}
+
 // AddMcpTools 增加 mcptools
-func (c *lkeClient) AddMcpTools(agentName string, mcpServerSse *mcpserversse.McpServerSse, selectedToolNames []string) (
+func (c *lkeClient) AddMcpTools(agentName string, mcpServer mcpserversse.McpServer, selectedToolNames []string) (
 	addTools []*tool.McpTool, err error) {
-	cache, err := tool.NewMcpClientCache(mcpServerSse)
+	cache, err := tool.NewMcpClientCache(mcpServer)
 	if err != nil {
 		return nil, fmt.Errorf("failed to list tools: %v", err)
 	}
@@ -141,37 +333,90 @@ func (c *lkeClient) AddMcpTools(agentName string, mcpServerSse *mcpserversse.Mcp
 	for _, t := range selectedToolNames {
 		selectMap[t] = struct{}{}
 	}
-	toolMcps, ok := c.toolsMap[agentName]
-	if !ok {
-		toolMcps = []tool.Tool{}
-		c.toolsMap[agentName] = toolMcps
+	selected := func(name string) bool {
+		_, ok := selectMap[name]
+		return len(selectMap) == 0 || ok
 	}
-	for _, toolName := range cache.OrderedName {
-		t, ok := cache.Data[toolName]
-		if !ok {
+	// 先注册回调再增加工具，避免错过两者之间的变化，重复的工具在 addMcpTool 中过滤
+	cache.OnChange(func(change tool.McpToolsChange) {
+		c.syncMcpTools(agentName, cache, change, selected)
+	})
+	cache.OnError(func(err error) {
+		runlog.Log(context.Background(), c.getLogger(), nil, slog.LevelWarn, "mcp tools refresh failed",
+			slog.String(runlog.KeyAgent, agentName), slog.String("error", err.Error()))
+	})
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.validated.Store(false)
+	for _, t := range cache.Tools() {
+		if !selected(t.Name) {
 			continue
 		}
-		add := true
-		if len(selectedToolNames) > 0 {
-			if _, ok := selectMap[t.Name]; !ok {
-				add = false
-			}
-		}
-		if add {
-			newtool := &tool.McpTool{
-				Name:  t.Name,
-				Cache: cache,
-			}
-			toolMcps = append(toolMcps, newtool)
-			c.toolsMap[agentName] = toolMcps
+		if newtool := c.addMcpTool(agentName, cache, t.Name); newtool != nil {
 			addTools = append(addTools, newtool)
 		}
 	}
 	return addTools, err
 }
 
+// addMcpTool 增加 mcp 工具，agent 已经有同一个 mcp server 的同名工具时返回 nil，需要持有 c.mu
+func (c *lkeClient) addMcpTool(agentName string, cache *tool.McpClientCache, name string) *tool.McpTool {
+	for _, t := range c.toolsMap[agentName] {
+		if m, ok := t.(*tool.McpTool); ok && m.Cache == cache && m.Name == name {
+			return nil
+		}
+	}
+	newtool := &tool.McpTool{
+		Name:  name,
+		Cache: cache,
+	}
+	c.toolsMap[agentName] = append(c.toolsMap[agentName], newtool)
+	return newtool
+}
+
+// syncMcpTools mcp server 的工具列表变化后同步增加或删除 agent 的工具，并回调 OnToolsetChanged
+func (c *lkeClient) syncMcpTools(agentName string, cache *tool.McpClientCache, change tool.McpToolsChange,
+	selected func(name string) bool) {
+	e := &event.ToolsetChangedEvent{AgentName: agentName}
+	c.mu.Lock()
+	removed := map[string]struct{}{}
+	for _, name := range change.Removed {
+		removed[name] = struct{}{}
+	}
+	tools := c.toolsMap[agentName][:0:0]
+	for _, t := range c.toolsMap[agentName] {
+		if m, ok := t.(*tool.McpTool); ok && m.Cache == cache {
+			if _, ok := removed[m.Name]; ok {
+				e.Removed = append(e.Removed, m.Name)
+				continue
+			}
+		}
+		tools = append(tools, t)
+	}
+	c.toolsMap[agentName] = tools
+	for _, t := range change.Added {
+		if selected(t.Name) && c.addMcpTool(agentName, cache, t.Name) != nil {
+			e.Added = append(e.Added, t.Name)
+		}
+	}
+	for _, t := range change.Updated {
+		if selected(t.Name) {
+			e.Updated = append(e.Updated, t.Name)
+		}
+	}
+	c.validated.Store(false)
+	handler, _ := c.eventHandler.(eventhandler.ToolsetChangedHandler)
+	c.mu.Unlock()
+	if handler != nil && (len(e.Added) > 0 || len(e.Removed) > 0 || len(e.Updated) > 0) {
+		handler.OnToolsetChanged(e)
+	}
+}
+
 func (c *lkeClient) AddAgentAsTool(agentName string, agentastoolName string,
 	toolName string, toolDescription string) (addtool *agentastool.AgentAsTool, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.validated.Store(false)
 	var agent model.Agent
 	ishaveAgent := false
 	for _, a := range c.agents {
@@ -190,23 +435,23 @@ func (c *lkeClient) AddAgentAsTool(agentName string, agentastoolName string,
 		Description:  agent.Instructions,
 		Agent:        agent,
 		Timeout:      c.toolRunTimeout,
-		RequestID:    c.requestID,
-		SessionID:    c.sessionID,
-		VisitorBizID: c.visitorBizID,
-		Conf: runner.RunnerConf{
-			EnableSystemOpt:     c.enableSystemOpt,
-			StartAgent:          agentastoolName,
-			Logger:              c.logger,
-			EventHandler:        c.eventHandler,
-			Endpoint:            c.endpoint,
-			MaxToolTurns:        c.maxToolTurns,
-			HttpClient:          http.DefaultClient,
-			BotAppKey:           c.botAppKey,
-			LocalToolRunTimeout: c.toolRunTimeout,
+		SessionID:    c.defaultSession.sessionID,
+		VisitorBizID: c.defaultSession.visitorBizID,
+		Conf:         c.agentToolConf(agentastoolName),
+		// 每次调用时使用 client 当前的配置，注册之后设置的中间件、http client 等同样生效
+		ConfFunc: func() runner.RunnerConf {
+			c.mu.RLock()
+			defer c.mu.RUnlock()
+			return c.agentToolConf(agentastoolName)
 		},
-		AgentNum: int64(agentastool.Agentglobalnumber),
+		// 每次调用时使用 agent 当前的工具，mcp 工具列表变化后同样生效
+		ToolsFunc: func() []tool.Tool {
+			c.mu.RLock()
+			defer c.mu.RUnlock()
+			return append([]tool.Tool{}, c.toolsMap[agentastoolName]...)
+		},
+		AgentNum: atomic.AddInt64(&agentastool.Agentglobalnumber, 1) - 1,
 	}
-	agentastool.Agentglobalnumber++
 	if toolDescription != "" {
 		agentAsTool.Description = toolDescription
 	}
@@ -224,7 +469,6 @@ func (c *lkeClient) AddAgentAsTool(agentName string, agentastoolName string,
 	// 		c.toolsMap[agentName] = toolFuncs
 	// 	}
 	// }
-	c.agentTools = append(c.agentTools, agentAsTool)
 	return agentAsTool, nil
 }
 
@@ -233,12 +477,18 @@ func (c *lkeClient) AddAgentAsTool(agentName string, agentastoolName string,
 
 // AddAgents 添加一批 agent
 func (c *lkeClient) AddAgents(agents []model.Agent) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.validated.Store(false)
 	c.agents = append(c.agents, agents...)
 }
 
 // AddHandoffs 添加 handoffs
 // 其中 sourceAgentName, targetAgentNames 可以是应用对应的云上 agent，也可以是本地创建的 agent
 func (c *lkeClient) AddHandoffs(sourceAgentName string, targetAgentNames []string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.validated.Store(false)
 	for _, target := range targetAgentNames {
 		c.handoffs = append(c.handoffs, model.Handoff{
 			SourceAgentName: sourceAgentName,
@@ -518,30 +768,95 @@ func (c *lkeClient) AddHandoffs(sourceAgentName string, targetAgentNames []strin
 func (c *lkeClient) RunWithContext(ctx context.Context,
 	query string,
 	options *model.Options) (finalReply *event.ReplyEvent, err error) {
-	if c.mock {
-		return c.mockRun()
-	}
-	runconf := runner.RunnerConf{
+	return c.defaultSession.RunWithContext(ctx, query, options)
+}
+
+// getLogger 获取当前的 logger
+func (c *lkeClient) getLogger() runlog.Logger {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.logger
+}
+
+// getEventHandler 获取当前的事件处理器
+func (c *lkeClient) getEventHandler() eventhandler.EventHandler {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.eventHandler
+}
+
+// runnerConf 当前配置的快照，调用方需要持有 c.mu
+func (c *lkeClient) runnerConf() runner.RunnerConf {
+	return runner.RunnerConf{
 		EnableSystemOpt:     c.enableSystemOpt,
-		StartAgent:          c.startAgent,
-		Logger:              c.logger,
-		EventHandler:        c.eventHandler,
+		StructuredLogger:    c.logger,
+		RedactKeys:          c.redactKeys,
 		MaxToolTurns:        c.maxToolTurns,
 		HttpClient:          c.httpClient,
 		Endpoint:            c.endpoint,
 		BotAppKey:           c.botAppKey,
 		LocalToolRunTimeout: c.toolRunTimeout,
+		RetryPolicy:         c.retryPolicy,
+		TracerProvider:      c.tracerProvider,
+		Propagator:          c.propagator,
+		Metrics:             c.metrics,
+		ApprovalPolicy:      c.approvalPolicy,
+		Approver:            c.approver,
+		Middlewares:         c.middlewares.Clone(),
 	}
-	if options != nil && options.EnvSet != "" {
-		ctx = util.WithEnvSet(ctx, options.EnvSet)
+}
+
+// agentToolConf 作为工具的 agent 嵌套执行的配置，预算和价格表使用上层执行的，调用方需要持有 c.mu
+func (c *lkeClient) agentToolConf(agentName string) runner.RunnerConf {
+	conf := c.runnerConf()
+	conf.StartAgent = agentName
+	conf.EventHandler = c.eventHandler
+	return conf
+}
+
+// newRunner 基于当前配置的快照创建 runner，运行期间修改 client 配置不影响本次执行，作为工具的 agent 在调用时读取配置
+// s 为执行所属的对话，用于对话级别的 token 预算，可以为空
+func (c *lkeClient) newRunner(handler eventhandler.EventHandler, s *session) *runner.RunnerImp {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	runconf := c.runnerConf()
+	runconf.StartAgent = c.startAgent
+	runconf.EventHandler = handler
+	runconf.Budget = c.budget
+	runconf.PriceTable = c.priceTable
+	if s != nil {
+		runconf.SessionUsage = s.usage
+	}
+	toolsMap := make(map[string][]tool.Tool, len(c.toolsMap))
+	for agentName, tools := range c.toolsMap {
+		toolsMap[agentName] = append([]tool.Tool{}, tools...)
 	}
-	c.logger.Info(fmt.Sprintf("RunWithContext: %v", query))
-	c.runnerImpl = runner.NewRunnerImp(c.toolsMap,
-		c.agents,
-		c.handoffs,
+	return runner.NewRunnerImp(toolsMap,
+		append([]model.Agent{}, c.agents...),
+		append([]model.Handoff{}, c.handoffs...),
 		runconf,
 	)
-	return c.runnerImpl.RunWithContext(ctx, query, c.requestID, c.sessionID, c.visitorBizID, options)
+}
+
+// runWithHandler 在指定对话中使用指定的事件处理器执行 agent，每次执行生成新的 requestID
+func (c *lkeClient) runWithHandler(ctx context.Context, s *session, query string,
+	options *model.Options, handler eventhandler.EventHandler) (*runner.RunResult, error) {
+	c.mu.RLock()
+	mock := c.mock
+	logger := c.logger
+	c.mu.RUnlock()
+	if mock {
+		reply, err := c.mockRun(ctx, handler)
+		return &runner.RunResult{Query: query, FinalReply: reply}, err
+	}
+	if options != nil && options.EnvSet != "" {
+		ctx = util.WithEnvSet(ctx, options.EnvSet)
+	}
+	runnerImpl := c.newRunner(handler, s)
+	if err := c.validate(ctx, runnerImpl, logger); err != nil {
+		return nil, err
+	}
+	return runnerImpl.RunWithResult(ctx, query, uuid.New().String(), s.sessionID, s.visitorBizID, options)
 	// req := c.buildReq(query, sesionID, visitorBizID, options)
 	// for i := 0; i <= int(c.maxToolTurns); i++ {
 	// 	if c.closed.Load() {
@@ -574,6 +889,35 @@ func (c *lkeClient) RunWithContext(ctx context.Context,
 	// return nil, fmt.Errorf("reached maximum tool call turns")
 }
 
+// resumeWithHandler 在指定对话中使用指定的事件处理器从暂停的执行继续
+func (c *lkeClient) resumeWithHandler(ctx context.Context, s *session, pending *runner.Pending,
+	input runner.ResumeInput, handler eventhandler.EventHandler) (*runner.RunResult, error) {
+	if pending != nil && pending.EnvSet != "" {
+		ctx = util.WithEnvSet(ctx, pending.EnvSet)
+	}
+	runnerImpl := c.newRunner(handler, s)
+	if err := c.validate(ctx, runnerImpl, c.getLogger()); err != nil {
+		return nil, err
+	}
+	return runnerImpl.Resume(ctx, pending, input)
+}
+
+// Resume 从暂停的执行继续，pending 属于默认对话时累计默认对话的 token 用量
+func (c *lkeClient) Resume(ctx context.Context, pending *runner.Pending,
+	input runner.ResumeInput) (*event.ReplyEvent, *runner.RunResult, error) {
+	s := c.defaultSession
+	if pending != nil && (pending.SessionID != s.sessionID || pending.VisitorBizID != s.visitorBizID) {
+		s = c.NewSession(pending.VisitorBizID, pending.SessionID).(*session)
+	}
+	return s.Resume(ctx, pending, input)
+}
+
+// RunWithResult 执行 agent，同时返回最终回复和包括每轮请求、工具调用、token 用量的完整记录
+func (c *lkeClient) RunWithResult(ctx context.Context, query string,
+	options *model.Options) (*event.ReplyEvent, *runner.RunResult, error) {
+	return c.defaultSession.RunWithResult(ctx, query, options)
+}
+
 // Run 执行 agent，query 用户的输入，sesionID 对话唯一标识，options 可选参数，可以为空
 // visitorBizID 用户的唯一标识
 func (c *lkeClient) Run(query string,
@@ -581,27 +925,40 @@ func (c *lkeClient) Run(query string,
 	return c.RunWithContext(context.Background(), query, options)
 }
 
-func (c *lkeClient) mockRun() (finalReply *event.ReplyEvent, err error) {
+// mockRun 使用随机生成的参数执行第一个 agent 的本地工具，不请求云端
+func (c *lkeClient) mockRun(ctx context.Context,
+	handler eventhandler.EventHandler) (finalReply *event.ReplyEvent, err error) {
 	reply := &event.ReplyEvent{
 		IsFinal: true,
 		Content: "mock text",
 	}
+	c.mu.RLock()
 	c.mockToolCall(reply)
+	c.mu.RUnlock()
 	outputs := []string{}
 	if reply.InterruptInfo != nil {
 		outputs = make([]string, len(reply.InterruptInfo.ToolCalls))
 	}
-	// c.runTools(context.Background(), nil, reply, &outputs)
-	if c.mock {
-		for i, out := range outputs {
-			fmt.Printf("run tool %s, input: %s, output: %s\n", reply.InterruptInfo.ToolCalls[i].Function.Name,
-				reply.InterruptInfo.ToolCalls[i].Function.Arguments, out)
-		}
+	c.newRunner(handler, nil).RunTools(ctx, nil, reply, &outputs)
+	c.mu.RLock()
+	logger, redactor := c.logger, runlog.NewRedactor(c.redactKeys...)
+	startAgent := c.startAgent
+	c.mu.RUnlock()
+	for i, out := range outputs {
+		call := reply.InterruptInfo.ToolCalls[i]
+		runlog.Log(ctx, logger, redactor, slog.LevelInfo, "mock tool call",
+			slog.String(runlog.KeyTool, call.Function.Name),
+			slog.String("input", redactor.JSON(json.RawMessage(call.Function.Arguments))),
+			slog.String("output", redactor.Text(out)),
+		)
 	}
 	finalReply = &event.ReplyEvent{
 		IsFinal: true,
 		Content: "mock text",
 	}
+	finalReply.Extend.Extend = map[string]string{"agentname": startAgent}
+	// 和云端执行一样通过事件处理器下发最终回复，RunStream 在 mock 模式下也能收到回复事件
+	handler.OnReply(finalReply)
 	return finalReply, err
 }
 
@@ -630,26 +987,3 @@ func (c *lkeClient) mockToolCall(reply *event.ReplyEvent) {
 		return
 	}
 }
-
-// Close 关闭所有 client 上的任务
-func (c *lkeClient) Close() {
-	c.logger.Error("client closed by user")
-	c.runnerImpl.IsClosed.Store(true)
-	for _, r := range c.agentTools {
-		if r.RunnerImpl != nil {
-			r.RunnerImpl.IsClosed.Store(true)
-		}
-	}
-}
-
-// Open Open 已经 Close 的 client
-func (c *lkeClient) Open() {
-	c.logger.Error("client open by user")
-	c.runnerImpl.IsClosed.Store(false)
-	for _, r := range c.agentTools {
-		if r.RunnerImpl != nil {
-			r.RunnerImpl.IsClosed.Store(false)
-		}
-	}
-
-}
@@ -0,0 +1,193 @@
+package lkesdk_test
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/tencent-lke/lke-sdk-go/event"
+	"github.com/tencent-lke/lke-sdk-go/eventhandler"
+	"github.com/tencent-lke/lke-sdk-go/lketest"
+	"github.com/tencent-lke/lke-sdk-go/mcpserversse"
+	"github.com/tencent-lke/lke-sdk-go/model"
+)
+
+func TestRunMcpTool(t *testing.T) {
+	srv := lketest.NewServer(
+		lketest.NewTurn(lketest.Interrupt("Agent-A",
+			lketest.ToolCall("call-1", "echo", `{"text":"hi"}`),
+		)),
+		lketest.NewTurn(lketest.Reply("done")),
+	)
+	defer srv.Close()
+	var calls int32
+	client := newTestClient(t, srv, &calls)
+	mcpServer := server.NewMCPServer("test-server", "1.0.0")
+	mcpServer.AddTool(mcp.NewTool("echo", mcp.WithDescription("echo the text"),
+		mcp.WithString("text", mcp.Required())),
+		func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+			args, _ := request.Params.Arguments.(map[string]interface{})
+			return mcp.NewToolResultText(fmt.Sprint(args["text"])), nil
+		})
+	mcpServer.AddTool(mcp.NewTool("unused"),
+		func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+			return mcp.NewToolResultText(""), nil
+		})
+	conn, err := mcpserversse.NewMcpServer(mcpserversse.InProcessTransport(mcpServer), mcp.InitializeRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	added, err := client.AddMcpTools("Agent-A", conn, []string{"echo"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(added) != 1 || added[0].GetDescription() != "echo the text" {
+		t.Fatalf("unexpected tools: %+v", added)
+	}
+	if _, err := client.Run("echo hi", nil); err != nil {
+		t.Fatal(err)
+	}
+	if output := srv.LastRequest().ToolOuputs[0].Output; output != "hi" {
+		t.Fatalf("except output hi, actual: %s", output)
+	}
+}
+
+type toolsetHandler struct {
+	eventhandler.DefaultEventHandler
+	events chan *event.ToolsetChangedEvent
+}
+
+func (h *toolsetHandler) OnToolsetChanged(e *event.ToolsetChangedEvent) {
+	h.events <- e
+}
+
+// agentToolNames 请求中 agent 的工具名
+func agentToolNames(req *model.ChatRequest, agentName string) []string {
+	var names []string
+	for _, at := range req.AgentConfig.AgentTools {
+		if at.AgentName != agentName {
+			continue
+		}
+		for _, t := range at.Tools {
+			names = append(names, t.Function.Name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+func TestMcpToolsetChanged(t *testing.T) {
+	srv := lketest.NewServer(
+		lketest.NewTurn(lketest.Reply("done")),
+		lketest.NewTurn(lketest.Reply("done")),
+	)
+	defer srv.Close()
+	var calls int32
+	client := newTestClient(t, srv, &calls)
+	handler := &toolsetHandler{events: make(chan *event.ToolsetChangedEvent, 10)}
+	client.SetEventHandler(handler)
+	mcpServer := server.NewMCPServer("test-server", "1.0.0", server.WithToolCapabilities(true))
+	echo := func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		return mcp.NewToolResultText("ok"), nil
+	}
+	mcpServer.AddTool(mcp.NewTool("echo", mcp.WithDescription("echo")), echo)
+	sseServer := server.NewTestServer(mcpServer)
+	defer sseServer.Close()
+	conn, err := mcpserversse.NewMcpServer(mcpserversse.SSETransport(sseServer.URL+"/sse"), mcp.InitializeRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if _, err := client.AddMcpTools("Agent-A", conn, []string{"echo", "time"}); err != nil {
+		t.Fatal(err)
+	}
+	wait := func() *event.ToolsetChangedEvent {
+		select {
+		case e := <-handler.events:
+			return e
+		case <-time.After(5 * time.Second):
+			t.Fatal("wait toolset changed event timeout")
+		}
+		return nil
+	}
+
+	// 没有选择的工具不会增加
+	mcpServer.AddTool(mcp.NewTool("time", mcp.WithDescription("current time")), echo)
+	mcpServer.AddTool(mcp.NewTool("other", mcp.WithDescription("other")), echo)
+	e := wait()
+	if e.AgentName != "Agent-A" || len(e.Added) != 1 || e.Added[0] != "time" {
+		t.Fatalf("unexpected event: %+v", e)
+	}
+	if _, err := client.Run("hi", nil); err != nil {
+		t.Fatal(err)
+	}
+	if names := agentToolNames(srv.LastRequest(), "Agent-A"); strings.Join(names, ",") != "add,echo,time" {
+		t.Fatalf("unexpected tools: %v", names)
+	}
+
+	mcpServer.DeleteTools("echo")
+	e = wait()
+	if len(e.Removed) != 1 || e.Removed[0] != "echo" || len(e.Added) != 0 {
+		t.Fatalf("unexpected event: %+v", e)
+	}
+	if _, err := client.Run("hi", nil); err != nil {
+		t.Fatal(err)
+	}
+	if names := agentToolNames(srv.LastRequest(), "Agent-A"); strings.Join(names, ",") != "add,time" {
+		t.Fatalf("unexpected tools: %v", names)
+	}
+}
+
+func TestMcpToolsetChangedAgentAsTool(t *testing.T) {
+	srv := lketest.NewServer(
+		lketest.NewTurn(lketest.Interrupt("Agent-A", lketest.ToolCall("call-1", "ask_b", `{"query":"hi"}`))),
+		lketest.NewTurn(lketest.Reply("from b")),
+		lketest.NewTurn(lketest.Reply("done")),
+	)
+	defer srv.Close()
+	var calls int32
+	client := newTestClient(t, srv, &calls)
+	handler := &toolsetHandler{events: make(chan *event.ToolsetChangedEvent, 10)}
+	client.SetEventHandler(handler)
+	client.AddAgents([]model.Agent{
+		model.NewAgent("Agent-B", "agent b", "agent b", model.DefaultModel, nil, nil),
+	})
+	mcpServer := server.NewMCPServer("test-server", "1.0.0", server.WithToolCapabilities(true))
+	echo := func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		return mcp.NewToolResultText("ok"), nil
+	}
+	mcpServer.AddTool(mcp.NewTool("echo", mcp.WithDescription("echo")), echo)
+	sseServer := server.NewTestServer(mcpServer)
+	defer sseServer.Close()
+	conn, err := mcpserversse.NewMcpServer(mcpserversse.SSETransport(sseServer.URL+"/sse"), mcp.InitializeRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if _, err := client.AddMcpTools("Agent-B", conn, nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.AddAgentAsTool("Agent-A", "Agent-B", "ask_b", "ask agent b"); err != nil {
+		t.Fatal(err)
+	}
+
+	// 注册 agent 工具之后 mcp server 增加的工具，嵌套执行时同样可以使用
+	mcpServer.AddTool(mcp.NewTool("time", mcp.WithDescription("current time")), echo)
+	select {
+	case <-handler.events:
+	case <-time.After(5 * time.Second):
+		t.Fatal("wait toolset changed event timeout")
+	}
+	if _, err := client.Run("hi", nil); err != nil {
+		t.Fatal(err)
+	}
+	if names := agentToolNames(srv.Requests()[1], "Agent-B"); strings.Join(names, ",") != "echo,time" {
+		t.Fatalf("unexpected nested tools: %v", names)
+	}
+}
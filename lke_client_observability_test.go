@@ -0,0 +1,203 @@
+package lkesdk_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	lkesdk "github.com/tencent-lke/lke-sdk-go"
+	"github.com/tencent-lke/lke-sdk-go/event"
+	"github.com/tencent-lke/lke-sdk-go/lketest"
+	"github.com/tencent-lke/lke-sdk-go/metrics/prommetrics"
+	"github.com/tencent-lke/lke-sdk-go/model"
+	"github.com/tencent-lke/lke-sdk-go/runlog"
+	"github.com/tencent-lke/lke-sdk-go/tool"
+	"github.com/tencent-lke/lke-sdk-go/tracing"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestRunTracing(t *testing.T) {
+	srv := lketest.NewServer(
+		lketest.NewTurn(lketest.Interrupt("Agent-A", lketest.ToolCall("call-1", "add", `{"a":1,"b":2}`))),
+		lketest.NewTurn(lketest.ReplyEvent(event.ReplyEvent{
+			Content:     "3",
+			IsFinal:     true,
+			RequestID:   "server-request",
+			TraceId:     "server-trace",
+			ReplyMethod: event.ReplyMethodModel,
+		})),
+	)
+	defer srv.Close()
+	var calls int32
+	client := newTestClient(t, srv, &calls)
+	recorder := tracetest.NewSpanRecorder()
+	client.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	client.SetPropagator(propagation.TraceContext{})
+	if _, err := client.Run("1+2", nil); err != nil {
+		t.Fatal(err)
+	}
+
+	spans := map[string][]sdktrace.ReadOnlySpan{}
+	for _, span := range recorder.Ended() {
+		spans[span.Name()] = append(spans[span.Name()], span)
+	}
+	if len(spans[tracing.SpanRun]) != 1 || len(spans[tracing.SpanTurn]) != 2 || len(spans[tracing.SpanTool]) != 1 {
+		t.Fatalf("unexpected spans: %v", spans)
+	}
+	run := spans[tracing.SpanRun][0]
+	attrs := func(span sdktrace.ReadOnlySpan) map[string]string {
+		m := map[string]string{}
+		for _, kv := range span.Attributes() {
+			m[string(kv.Key)] = kv.Value.Emit()
+		}
+		return m
+	}
+	toolAttrs := attrs(spans[tracing.SpanTool][0])
+	if toolAttrs[tracing.AttrToolName] != "add" || toolAttrs[tracing.AttrAgentName] != "Agent-A" ||
+		toolAttrs[tracing.AttrToolCallID] != "call-1" {
+		t.Fatalf("unexpected tool span attributes: %v", toolAttrs)
+	}
+	turnAttrs := attrs(spans[tracing.SpanTurn][1])
+	if turnAttrs[tracing.AttrServerTraceID] != "server-trace" ||
+		turnAttrs[tracing.AttrServerRequestID] != "server-request" {
+		t.Fatalf("unexpected turn span attributes: %v", turnAttrs)
+	}
+	for _, span := range recorder.Ended() {
+		if span.SpanContext().TraceID() != run.SpanContext().TraceID() {
+			t.Fatalf("except all spans in one trace, actual: %s", span.Name())
+		}
+	}
+	traceID := run.SpanContext().TraceID().String()
+	for _, header := range srv.Headers() {
+		if !strings.Contains(header.Get("traceparent"), traceID) {
+			t.Fatalf("except traceparent with trace id %s, actual: %s", traceID, header.Get("traceparent"))
+		}
+	}
+}
+
+func TestRunMetrics(t *testing.T) {
+	srv := lketest.NewServer(
+		lketest.NewTurn(lketest.Interrupt("Agent-A", lketest.ToolCall("call-1", "add", `{"a":1,"b":2}`))),
+		lketest.NewTurn(lketest.Reply("3")),
+	)
+	defer srv.Close()
+	var calls int32
+	client := newTestClient(t, srv, &calls)
+	reg := prometheus.NewRegistry()
+	recorder, err := prommetrics.New(reg, prommetrics.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.SetMetricsRecorder(recorder)
+	if _, err := client.Run("1+2", nil); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `
+# HELP lke_runs_finished_total Number of agent runs finished by outcome and error class.
+# TYPE lke_runs_finished_total counter
+lke_runs_finished_total{agent="Agent-A",error_class="",outcome="succeeded"} 1
+# HELP lke_runs_started_total Number of agent runs started.
+# TYPE lke_runs_started_total counter
+lke_runs_started_total{agent="Agent-A"} 1
+`
+	if err := testutil.GatherAndCompare(reg, strings.NewReader(expected),
+		"lke_runs_started_total", "lke_runs_finished_total"); err != nil {
+		t.Fatal(err)
+	}
+	if n := testutil.CollectAndCount(reg, "lke_tool_duration_seconds"); n != 1 {
+		t.Fatalf("except 1 tool duration series, actual: %d", n)
+	}
+	if n := testutil.CollectAndCount(reg, "lke_sse_events_total"); n == 0 {
+		t.Fatal("except sse event metrics")
+	}
+	if n := testutil.CollectAndCount(reg, "lke_first_reply_seconds"); n != 1 {
+		t.Fatalf("except 1 first reply series, actual: %d", n)
+	}
+	if _, err := prommetrics.New(reg, prommetrics.Options{}); err == nil {
+		t.Fatal("except duplicate registration error")
+	}
+}
+
+func TestRunLogging(t *testing.T) {
+	srv := lketest.NewServer(
+		lketest.NewTurn(lketest.Interrupt("Agent-A", lketest.ToolCall("call-1", "add", `{"a":1,"b":2}`))),
+		lketest.NewTurn(lketest.Reply("3")),
+	)
+	defer srv.Close()
+	var calls int32
+	client := newTestClient(t, srv, &calls)
+	buf := &bytes.Buffer{}
+	client.SetLogger(runlog.NewSlog(slog.New(slog.NewJSONHandler(buf, &slog.HandlerOptions{Level: slog.LevelDebug}))))
+	client.SetLogRedactKeys("token")
+	_, err := client.Run("1+2", &model.Options{CustomVariables: map[string]string{"token": "secret-token"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(buf.String(), "test-app-key") || strings.Contains(buf.String(), "secret-token") {
+		t.Fatalf("except secrets redacted, actual: %s", buf.String())
+	}
+	toolLogged := false
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		record := map[string]interface{}{}
+		if err := json.Unmarshal([]byte(line), &record); err != nil {
+			t.Fatal(err)
+		}
+		if record[runlog.KeyRunID] == nil || record[runlog.KeySessionID] != "session" {
+			t.Fatalf("except run fields, actual: %s", line)
+		}
+		if record["msg"] == "tool call" {
+			toolLogged = record[runlog.KeyTool] == "add" && record[runlog.KeyTurn] == float64(0)
+		}
+	}
+	if !toolLogged {
+		t.Fatalf("except tool call log, actual: %s", buf.String())
+	}
+}
+
+func TestMockRunLogRedaction(t *testing.T) {
+	client := lkesdk.NewLkeClient("test-app-key", "visitor", "session", nil)
+	client.AddAgents([]model.Agent{
+		model.NewAgent("Agent-A", "agent a", "agent a", model.DefaultModel, nil, nil),
+	})
+	client.SetStartAgent("Agent-A")
+	login, err := tool.NewFunctionTool("login", "login", func(p struct{}) map[string]string {
+		return map[string]string{"token": "secret-token", "user": "visitor"}
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.AddFunctionTools("Agent-A", []*tool.FunctionTool{login})
+	buf := &bytes.Buffer{}
+	client.SetLogger(runlog.NewSlog(slog.New(slog.NewJSONHandler(buf, nil))))
+	client.SetLogRedactKeys("token")
+	client.SetMock(true)
+	if _, err := client.Run("hi", nil); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), "mock tool call") || strings.Contains(buf.String(), "secret-token") {
+		t.Fatalf("except mock tool output redacted, actual: %s", buf.String())
+	}
+}
+
+func TestRunWithoutLogger(t *testing.T) {
+	srv := lketest.NewServer(lketest.NewTurn(lketest.Reply("ok")))
+	defer srv.Close()
+	client := lkesdk.NewLkeClient("test-app-key", "visitor", "session", nil)
+	client.SetEndpoint(srv.URL)
+	client.AddAgents([]model.Agent{
+		model.NewAgent("Agent-A", "agent a", "agent a", model.DefaultModel, nil, nil),
+	})
+	client.SetStartAgent("Agent-A")
+	client.SetRunLogger(nil)
+	if _, err := client.Run("hi", nil); err != nil {
+		t.Fatal(err)
+	}
+	client.Close()
+}
@@ -0,0 +1,145 @@
+package lkesdk
+
+import (
+	"context"
+	"iter"
+
+	"github.com/tencent-lke/lke-sdk-go/event"
+	"github.com/tencent-lke/lke-sdk-go/eventhandler"
+	"github.com/tencent-lke/lke-sdk-go/model"
+)
+
+// streamBufferSize 流式事件的缓冲区大小
+const streamBufferSize = 64
+
+// streamEventHandler 把回调事件转发到 channel，同时保留用户自定义的事件处理
+type streamEventHandler struct {
+	next   eventhandler.EventHandler
+	ctx    context.Context
+	events chan event.Event
+}
+
+func (h *streamEventHandler) emit(ev event.Event) {
+	select {
+	case h.events <- ev:
+	case <-h.ctx.Done():
+	}
+}
+
+// OnError 错误处理，错误通过迭代器的 error 返回，这里只转发给用户的处理器
+func (h *streamEventHandler) OnError(err *event.ErrorEvent) {
+	h.next.OnError(err)
+}
+
+// OnReply 回复处理
+func (h *streamEventHandler) OnReply(reply *event.ReplyEvent) {
+	h.next.OnReply(reply)
+	h.emit(reply)
+}
+
+// OnThought 思考过程处理
+func (h *streamEventHandler) OnThought(thought *event.AgentThoughtEvent) {
+	h.next.OnThought(thought)
+	h.emit(thought)
+}
+
+// OnReference 引用事件处理
+func (h *streamEventHandler) OnReference(refer *event.ReferenceEvent) {
+	h.next.OnReference(refer)
+	h.emit(refer)
+}
+
+// OnTokenStat token 统计事件
+func (h *streamEventHandler) OnTokenStat(stat *event.TokenStatEvent) {
+	h.next.OnTokenStat(stat)
+	h.emit(stat)
+}
+
+// BeforeToolCallHook 工具调用前的钩子
+func (h *streamEventHandler) BeforeToolCallHook(toolCallCtx eventhandler.ToolCallContext) {
+	h.next.BeforeToolCallHook(toolCallCtx)
+	h.emit(&event.ToolCallStartEvent{
+		CallID:    toolCallCtx.CallId,
+		ToolName:  toolCallCtx.CallToolName,
+		AgentName: toolCallCtx.Extend["agentname"],
+		Input:     toolCallCtx.Input,
+	})
+}
+
+// AfterToolCallHook 工具调用后的钩子
+func (h *streamEventHandler) AfterToolCallHook(toolCallCtx eventhandler.ToolCallContext) {
+	h.next.AfterToolCallHook(toolCallCtx)
+	ev := &event.ToolCallEndEvent{
+		CallID:    toolCallCtx.CallId,
+		ToolName:  toolCallCtx.CallToolName,
+		AgentName: toolCallCtx.Extend["agentname"],
+		Input:     toolCallCtx.Input,
+		Output:    toolCallCtx.Output,
+		Err:       toolCallCtx.Err,
+	}
+	if toolCallCtx.Err != nil {
+		ev.ErrMsg = toolCallCtx.Err.Error()
+	}
+	h.emit(ev)
+}
+
+// OnRetry 重试事件处理
+func (h *streamEventHandler) OnRetry(retry *event.RetryEvent) {
+	if next, ok := h.next.(eventhandler.RetryHandler); ok {
+		next.OnRetry(retry)
+	}
+	h.emit(retry)
+}
+
+// RunStream 流式执行 agent，按顺序返回执行过程中的事件
+// 事件类型包括 *event.ReplyEvent, *event.AgentThoughtEvent, *event.ReferenceEvent,
+// *event.TokenStatEvent, *event.ToolCallStartEvent, *event.ToolCallEndEvent, *event.RetryEvent
+// 执行失败时最后返回一次 error，提前结束迭代会取消本次执行，client Close 时同样会被取消
+func (c *lkeClient) RunStream(ctx context.Context, query string,
+	options *model.Options) iter.Seq2[event.Event, error] {
+	return c.defaultSession.RunStream(ctx, query, options)
+}
+
+// runStream 在指定对话中流式执行 agent
+func (c *lkeClient) runStream(ctx context.Context, s *session, query string,
+	options *model.Options) iter.Seq2[event.Event, error] {
+	return func(yield func(event.Event, error) bool) {
+		runCtx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		handler := &streamEventHandler{
+			next:   c.getEventHandler(),
+			ctx:    runCtx,
+			events: make(chan event.Event, streamBufferSize),
+		}
+		h, err := c.start(runCtx, s, query, options, handler)
+		if err != nil {
+			yield(nil, err)
+			return
+		}
+		for {
+			select {
+			case ev := <-handler.events:
+				if !yield(ev, nil) {
+					return
+				}
+			case <-h.done:
+				// 执行结束，先把缓冲区中剩余的事件返回
+				for {
+					select {
+					case ev := <-handler.events:
+						if !yield(ev, nil) {
+							return
+						}
+						continue
+					default:
+					}
+					break
+				}
+				if _, runErr := h.Wait(); runErr != nil {
+					yield(nil, runErr)
+				}
+				return
+			}
+		}
+	}
+}
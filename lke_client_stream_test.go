@@ -0,0 +1,72 @@
+package lkesdk_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/tencent-lke/lke-sdk-go/event"
+	"github.com/tencent-lke/lke-sdk-go/lketest"
+)
+
+func TestRunStreamEvents(t *testing.T) {
+	srv := lketest.NewServer(
+		lketest.NewTurn(
+			lketest.Thought(event.AgentThoughtEvent{RecordID: "r1"}),
+			lketest.Interrupt("Agent-A", lketest.ToolCall("call-1", "add", `{"a":1,"b":2}`)),
+		),
+		lketest.NewTurn(
+			lketest.Reference(event.ReferenceEvent{RecordID: "r2"}),
+			lketest.TokenStat(event.TokenStatEvent{RecordID: "r2", UsedCount: 10}),
+			lketest.Reply("3"),
+		),
+	)
+	defer srv.Close()
+	var calls int32
+	client := newTestClient(t, srv, &calls)
+	names := []string{}
+	for ev, err := range client.RunStream(context.Background(), "1+2", nil) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		names = append(names, ev.Name())
+	}
+	except := []string{
+		event.EventThought, event.EventToolCallStart, event.EventToolCallEnd,
+		event.EventReference, event.EventTokenStat, event.EventReply,
+	}
+	if len(names) != len(except) {
+		t.Fatalf("except events %v, actual: %v", except, names)
+	}
+	for i := range except {
+		if names[i] != except[i] {
+			t.Fatalf("except events %v, actual: %v", except, names)
+		}
+	}
+}
+
+func TestMockRunStream(t *testing.T) {
+	srv := lketest.NewServer()
+	defer srv.Close()
+	var calls int32
+	client := newTestClient(t, srv, &calls)
+	client.SetMock(true)
+	names := []string{}
+	var reply *event.ReplyEvent
+	for ev, err := range client.RunStream(context.Background(), "hi", nil) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		names = append(names, ev.Name())
+		if r, ok := ev.(*event.ReplyEvent); ok {
+			reply = r
+		}
+	}
+	except := []string{event.EventToolCallStart, event.EventToolCallEnd, event.EventReply}
+	if fmt.Sprint(names) != fmt.Sprint(except) {
+		t.Fatalf("except events %v, actual: %v", except, names)
+	}
+	if reply.Content != "mock text" || !reply.IsFinal || len(srv.Requests()) != 0 {
+		t.Fatalf("unexpected mock reply: %+v, requests: %d", reply, len(srv.Requests()))
+	}
+}
@@ -0,0 +1,540 @@
+package lkesdk_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	lkesdk "github.com/tencent-lke/lke-sdk-go"
+	"github.com/tencent-lke/lke-sdk-go/approval"
+	"github.com/tencent-lke/lke-sdk-go/event"
+	"github.com/tencent-lke/lke-sdk-go/lkeerrors"
+	"github.com/tencent-lke/lke-sdk-go/lketest"
+	"github.com/tencent-lke/lke-sdk-go/model"
+	"github.com/tencent-lke/lke-sdk-go/runner"
+	"github.com/tencent-lke/lke-sdk-go/tool"
+	"github.com/tencent-lke/lke-sdk-go/usage"
+)
+
+type testLogger struct {
+	t *testing.T
+}
+
+func (l testLogger) Info(message string) {
+	l.t.Log(message)
+}
+
+func (l testLogger) Error(message string) {
+	l.t.Log(message)
+}
+
+type addParams struct {
+	A int `json:"a" doc:"number a"`
+	B int `json:"b" doc:"number b"`
+}
+
+func newTestClient(t *testing.T, srv *lketest.Server, calls *int32) lkesdk.LkeClient {
+	client := lkesdk.NewLkeClient("test-app-key", "visitor", "session", nil)
+	client.SetRunLogger(testLogger{t: t})
+	client.SetEndpoint(srv.URL)
+	client.AddAgents([]model.Agent{
+		model.NewAgent("Agent-A", "agent a", "agent a", model.DefaultModel, nil, nil),
+	})
+	client.SetStartAgent("Agent-A")
+	add, err := tool.NewFunctionTool("add", "add two numbers", func(p addParams) int {
+		atomic.AddInt32(calls, 1)
+		return p.A + p.B
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.AddFunctionTools("Agent-A", []*tool.FunctionTool{add})
+	return client
+}
+
+func TestRunToolLoop(t *testing.T) {
+	srv := lketest.NewServer(
+		lketest.NewTurn(lketest.Interrupt("Agent-A",
+			lketest.ToolCall("call-1", "add", `{"a":1,"b":2}`),
+			lketest.ToolCall("call-2", "add", `{"a":3,"b":4}`),
+		)),
+		lketest.NewTurn(lketest.Reply("done")),
+	)
+	defer srv.Close()
+	var calls int32
+	client := newTestClient(t, srv, &calls)
+	reply, err := client.Run("1+2, 3+4", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reply.Content != "done" {
+		t.Fatalf("except reply done, actual: %s", reply.Content)
+	}
+	if calls != 2 {
+		t.Fatalf("except 2 tool calls, actual: %d", calls)
+	}
+	reqs := srv.Requests()
+	if len(reqs) != 2 {
+		t.Fatalf("except 2 requests, actual: %d", len(reqs))
+	}
+	if reqs[0].Content != "1+2, 3+4" || len(reqs[0].ToolOuputs) != 0 {
+		t.Fatalf("unexpected first request: %+v", reqs[0])
+	}
+	except := []model.ToolOuput{{ToolName: "add", Output: "3"}, {ToolName: "add", Output: "7"}}
+	if len(reqs[1].ToolOuputs) != len(except) {
+		t.Fatalf("except tool outputs %v, actual: %v", except, reqs[1].ToolOuputs)
+	}
+	for i := range except {
+		if reqs[1].ToolOuputs[i] != except[i] {
+			t.Fatalf("except tool outputs %v, actual: %v", except, reqs[1].ToolOuputs)
+		}
+	}
+	if reqs[1].RequestID != reqs[0].RequestID {
+		t.Fatalf("except the same request id in one run, actual: %s, %s", reqs[0].RequestID, reqs[1].RequestID)
+	}
+}
+
+func TestRunWithResult(t *testing.T) {
+	stat := func(input, output uint32) event.TokenStatEvent {
+		return event.TokenStatEvent{
+			TokenCount: input + output,
+			Procedures: []event.Procedure{{
+				Name: event.ProcedureLLM,
+				TokenUsageDetails: []*event.TokenUsage{{
+					ModelName:    "function-call-pro",
+					InputTokens:  input,
+					OutputTokens: output,
+					TotalTokens:  input + output,
+				}},
+			}},
+		}
+	}
+	srv := lketest.NewServer(
+		lketest.NewTurn(
+			lketest.TokenStat(stat(100, 10)),
+			lketest.Interrupt("Agent-A", lketest.ToolCall("call-1", "add", `{"a":1,"b":2}`),
+				lketest.ToolCall("call-2", "sub", `{}`)),
+		),
+		lketest.NewTurn(lketest.TokenStat(stat(200, 20)), lketest.Reply("3")),
+	)
+	defer srv.Close()
+	var calls int32
+	client := newTestClient(t, srv, &calls)
+	reply, result, err := client.RunWithResult(context.Background(), "1+2", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reply.Content != "3" || result.FinalReply != reply || result.Query != "1+2" {
+		t.Fatalf("unexpected result: %+v", result)
+	}
+	if len(result.Turns) != 2 {
+		t.Fatalf("except 2 turns, actual: %d", len(result.Turns))
+	}
+	first := result.Turns[0]
+	if first.Request.BotAppKey != "" || first.InterruptInfo == nil || first.Attempts != 1 ||
+		len(first.ToolCalls) != 2 || len(first.Replies) != 1 {
+		t.Fatalf("unexpected first turn: %+v", first)
+	}
+	add, sub := first.ToolCalls[0], first.ToolCalls[1]
+	if add.CallID != "call-1" || add.AgentName != "Agent-A" || add.Output != "3" || add.Error != "" ||
+		add.Input["a"] != float64(1) {
+		t.Fatalf("unexpected add tool call: %+v", add)
+	}
+	if sub.ToolName != "sub" || sub.Output == "" {
+		t.Fatalf("unexpected sub tool call: %+v", sub)
+	}
+	if len(result.Turns[1].Request.ToolOuputs) != 2 {
+		t.Fatalf("except tool outputs in second request, actual: %+v", result.Turns[1].Request)
+	}
+	except := usage.Usage{InputTokens: 300, OutputTokens: 30, TotalTokens: 330}
+	if result.UsageSummary.Total.Usage != except || result.Tokens() != 330 {
+		t.Fatalf("except usage %+v, actual: %+v", except, result.UsageSummary.Total)
+	}
+	bs, err := json.Marshal(result)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded := runner.RunResult{}
+	if err := json.Unmarshal(bs, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if len(decoded.Turns) != 2 || decoded.UsageSummary.Total.Usage != except || decoded.Turns[0].ToolCalls[0].Output != "3" {
+		t.Fatalf("unexpected decoded result: %s", bs)
+	}
+}
+
+func TestRunResultWithoutCustomVariables(t *testing.T) {
+	srv := lketest.NewServer(lketest.NewTurn(lketest.Reply("hi")))
+	defer srv.Close()
+	var calls int32
+	client := newTestClient(t, srv, &calls)
+	_, result, err := client.RunWithResult(context.Background(), "hi",
+		&model.Options{CustomVariables: map[string]string{"token": "secret-token"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if vars := srv.Requests()[0].CustomVariables; vars["token"] != "secret-token" {
+		t.Fatalf("except custom variables sent, actual: %v", vars)
+	}
+	bs, err := json.Marshal(result)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(bs), "secret-token") {
+		t.Fatalf("except custom variables not in result, actual: %s", bs)
+	}
+}
+
+func TestMockRunTools(t *testing.T) {
+	srv := lketest.NewServer()
+	defer srv.Close()
+	var calls int32
+	client := newTestClient(t, srv, &calls)
+	client.SetMock(true)
+	reply, err := client.Run("hi", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reply.Content != "mock text" || calls != 1 || len(srv.Requests()) != 0 {
+		t.Fatalf("except mock tools run without request, actual calls: %d, requests: %d",
+			calls, len(srv.Requests()))
+	}
+}
+
+func TestMockRunApprovalRequired(t *testing.T) {
+	srv := lketest.NewServer()
+	defer srv.Close()
+	var calls int32
+	client := newTestClient(t, srv, &calls)
+	client.SetMock(true)
+	// mock 模式不能暂停，没有 Approver 时需要审批的调用直接拒绝
+	client.SetApprovalPolicy(&approval.Policy{Tools: map[string]approval.Mode{"add": approval.ModeRequired}})
+	reply, err := client.Run("hi", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reply.Content != "mock text" || calls != 0 {
+		t.Fatalf("except call rejected in mock mode, actual calls: %d", calls)
+	}
+}
+
+func TestRunUsageSummary(t *testing.T) {
+	stat := func(model string, tokens uint32) lketest.Event {
+		return lketest.TokenStat(event.TokenStatEvent{
+			Procedures: []event.Procedure{{
+				Name:              event.ProcedureLLM,
+				TokenUsageDetails: []*event.TokenUsage{{ModelName: model, TotalTokens: tokens}},
+			}},
+		})
+	}
+	srv := lketest.NewServer(
+		lketest.NewTurn(stat("pro", 100),
+			lketest.Interrupt("Agent-A", lketest.ToolCall("call-1", "ask_b", `{"query":"hi"}`))),
+		// agent 工具的嵌套执行
+		lketest.NewTurn(stat("r1", 200), lketest.Reply("from b")),
+		lketest.NewTurn(stat("pro", 50), lketest.Reply("done")),
+	)
+	defer srv.Close()
+	var calls int32
+	client := newTestClient(t, srv, &calls)
+	client.AddAgents([]model.Agent{
+		model.NewAgent("Agent-B", "agent b", "agent b", model.DefaultModel, nil, nil),
+	})
+	if _, err := client.AddAgentAsTool("Agent-A", "Agent-B", "ask_b", "ask agent b"); err != nil {
+		t.Fatal(err)
+	}
+	client.SetPriceTable(usage.PriceTable{"pro": {TotalPer1K: 1}, "r1": {TotalPer1K: 2}})
+	_, result, err := client.RunWithResult(context.Background(), "hi", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := result.UsageSummary
+	if s == nil || s.Total.TotalTokens != 350 {
+		t.Fatalf("except 350 tokens with sub run, actual: %+v", s)
+	}
+	if s.ByAgent["Agent-A"].TotalTokens != 150 || s.ByAgent["Agent-B"].TotalTokens != 200 {
+		t.Fatalf("unexpected usage by agent: %+v", s.ByAgent)
+	}
+	if math.Abs(s.ByModel["r1"].Cost-0.4) > 1e-9 || math.Abs(s.Total.Cost-0.55) > 1e-9 {
+		t.Fatalf("unexpected cost: %+v", s)
+	}
+}
+
+func TestRunBudgetAgentAsTool(t *testing.T) {
+	stat := func(tokens uint32) lketest.Event {
+		return lketest.TokenStat(event.TokenStatEvent{
+			Procedures: []event.Procedure{{Name: event.ProcedureLLM, Count: tokens}},
+		})
+	}
+	srv := lketest.NewServer(
+		lketest.NewTurn(stat(100),
+			lketest.Interrupt("Agent-A", lketest.ToolCall("call-1", "ask_b", `{"query":"hi"}`))),
+		// agent 工具的嵌套执行超过上层执行的预算
+		lketest.NewTurn(stat(300), lketest.Reply("from b")),
+		lketest.NewTurn(stat(50), lketest.Reply("done")),
+	)
+	defer srv.Close()
+	var calls int32
+	client := newTestClient(t, srv, &calls)
+	client.AddAgents([]model.Agent{
+		model.NewAgent("Agent-B", "agent b", "agent b", model.DefaultModel, nil, nil),
+	})
+	if _, err := client.AddAgentAsTool("Agent-A", "Agent-B", "ask_b", "ask agent b"); err != nil {
+		t.Fatal(err)
+	}
+	client.SetBudget(&model.Budget{MaxTotalTokens: 250})
+	_, result, err := client.RunWithResult(context.Background(), "hi", nil)
+	if !errors.Is(err, lkeerrors.ErrBudgetExceeded) {
+		t.Fatalf("except ErrBudgetExceeded, actual: %v", err)
+	}
+	call := result.Turns[0].ToolCalls[0]
+	if !strings.Contains(call.Error, lkeerrors.ErrBudgetExceeded.Error()) || len(srv.Requests()) != 2 ||
+		result.Tokens() != 400 {
+		t.Fatalf("except nested run counted against the budget, actual: %+v, requests: %d, tokens: %d",
+			call, len(srv.Requests()), result.Tokens())
+	}
+}
+
+func TestRunToolMiddleware(t *testing.T) {
+	srv := lketest.NewServer(
+		lketest.NewTurn(lketest.Interrupt("Agent-A",
+			lketest.ToolCall("call-1", "add", `{"a":1,"b":2}`),
+			lketest.ToolCall("call-2", "add", `{"a":3,"b":4}`),
+		)),
+		lketest.NewTurn(lketest.Reply("done")),
+	)
+	defer srv.Close()
+	var calls int32
+	client := newTestClient(t, srv, &calls)
+	var infos int32
+	client.Use(func(next tool.ToolFunc) tool.ToolFunc {
+		return func(ctx context.Context, input map[string]interface{}) (interface{}, error) {
+			if info, ok := tool.CallInfoFromContext(ctx); ok && info.AgentName == "Agent-A" && info.ToolName == "add" {
+				atomic.AddInt32(&infos, 1)
+			}
+			return next(ctx, input)
+		}
+	})
+	client.UseForTool("add", func(next tool.ToolFunc) tool.ToolFunc {
+		return func(ctx context.Context, input map[string]interface{}) (interface{}, error) {
+			// 命中缓存时不执行工具
+			if input["a"] == float64(1) {
+				return "cached", nil
+			}
+			out, err := next(ctx, input)
+			return fmt.Sprintf("result: %v", out), err
+		}
+	})
+	if _, err := client.Run("1+2", nil); err != nil {
+		t.Fatal(err)
+	}
+	outputs := srv.LastRequest().ToolOuputs
+	if calls != 1 || infos != 2 || outputs[0].Output != "cached" || outputs[1].Output != "result: 7" {
+		t.Fatalf("unexpected outputs: %+v, calls: %d", outputs, calls)
+	}
+}
+
+func TestRunToolMiddlewareAgentAsTool(t *testing.T) {
+	srv := lketest.NewServer(
+		lketest.NewTurn(lketest.Interrupt("Agent-A", lketest.ToolCall("call-1", "ask_b", `{"query":"1+2"}`))),
+		lketest.NewTurn(lketest.Interrupt("Agent-B", lketest.ToolCall("call-2", "sub", `{"a":3,"b":2}`))),
+		lketest.NewTurn(lketest.Reply("1")),
+		lketest.NewTurn(lketest.Reply("done")),
+	)
+	defer srv.Close()
+	var calls int32
+	client := newTestClient(t, srv, &calls)
+	client.AddAgents([]model.Agent{
+		model.NewAgent("Agent-B", "agent b", "agent b", model.DefaultModel, nil, nil),
+	})
+	sub, err := tool.NewFunctionTool("sub", "subtract two numbers", func(p addParams) int {
+		return p.A - p.B
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.AddFunctionTools("Agent-B", []*tool.FunctionTool{sub})
+	if _, err := client.AddAgentAsTool("Agent-A", "Agent-B", "ask_b", "ask agent b"); err != nil {
+		t.Fatal(err)
+	}
+	// 注册 agent 工具之后添加的中间件对嵌套执行同样生效
+	var infos int32
+	client.UseForAgent("Agent-B", func(next tool.ToolFunc) tool.ToolFunc {
+		return func(ctx context.Context, input map[string]interface{}) (interface{}, error) {
+			if info, ok := tool.CallInfoFromContext(ctx); ok && info.ToolName == "sub" {
+				atomic.AddInt32(&infos, 1)
+			}
+			return next(ctx, input)
+		}
+	})
+	if _, err := client.Run("1+2", nil); err != nil {
+		t.Fatal(err)
+	}
+	if infos != 1 || srv.Requests()[2].ToolOuputs[0].Output != "1" {
+		t.Fatalf("except middleware applied to nested tool call, actual: %d", infos)
+	}
+}
+
+func TestRunToolRetry(t *testing.T) {
+	srv := lketest.NewServer(
+		lketest.NewTurn(lketest.Interrupt("Agent-A",
+			lketest.ToolCall("call-1", "flaky", `{"a":1,"b":2}`),
+		)),
+		lketest.NewTurn(lketest.Reply("done")),
+	)
+	defer srv.Close()
+	var calls, flakyCalls int32
+	client := newTestClient(t, srv, &calls)
+	flaky, err := tool.NewFunctionTool("flaky", "add two numbers", func(p addParams) (int, error) {
+		if atomic.AddInt32(&flakyCalls, 1) < 3 {
+			return 0, errors.New("temporarily unavailable")
+		}
+		return p.A + p.B, nil
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	flaky.SetRetryPolicy(&tool.RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond})
+	client.AddFunctionTools("Agent-A", []*tool.FunctionTool{flaky})
+	_, result, err := client.RunWithResult(context.Background(), "1+2", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	record := result.Turns[0].ToolCalls[0]
+	outputs := srv.LastRequest().ToolOuputs
+	if flakyCalls != 3 || record.Attempts != 3 || record.Error != "" || outputs[0].Output != "3" {
+		t.Fatalf("unexpected record: %+v, outputs: %+v", record, outputs)
+	}
+}
+
+func TestRunToolRetryPanic(t *testing.T) {
+	srv := lketest.NewServer(
+		lketest.NewTurn(lketest.Interrupt("Agent-A", lketest.ToolCall("call-1", "broken", `{"a":1,"b":2}`))),
+		lketest.NewTurn(lketest.Reply("done")),
+	)
+	defer srv.Close()
+	var calls, brokenCalls int32
+	client := newTestClient(t, srv, &calls)
+	broken, err := tool.NewFunctionTool("broken", "add two numbers", func(p addParams) int {
+		atomic.AddInt32(&brokenCalls, 1)
+		panic("broken")
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// panic 不重试
+	broken.SetRetryPolicy(&tool.RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond})
+	client.AddFunctionTools("Agent-A", []*tool.FunctionTool{broken})
+	_, result, err := client.RunWithResult(context.Background(), "1+2", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	record := result.Turns[0].ToolCalls[0]
+	if brokenCalls != 1 || !strings.Contains(record.Error, lkeerrors.ErrToolPanic.Error()) {
+		t.Fatalf("except panic not retried, actual calls: %d, record: %+v", brokenCalls, record)
+	}
+}
+
+func TestRunToolArgsValidation(t *testing.T) {
+	srv := lketest.NewServer(
+		lketest.NewTurn(lketest.Interrupt("Agent-A",
+			lketest.ToolCall("call-1", "add", `{"a":"1","b":2}`),
+			lketest.ToolCall("call-2", "add", `{"a":1}`),
+		)),
+		lketest.NewTurn(lketest.Interrupt("Agent-A",
+			lketest.ToolCall("call-3", "add", `{"a":1,"b":2}`),
+		)),
+		lketest.NewTurn(lketest.Reply("done")),
+	)
+	defer srv.Close()
+	var calls int32
+	client := newTestClient(t, srv, &calls)
+	_, result, err := client.RunWithResult(context.Background(), "1+2", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	outputs := srv.Requests()[1].ToolOuputs
+	if calls != 1 || !strings.Contains(outputs[0].Output, "$.a: expected integer, got string") ||
+		!strings.Contains(outputs[1].Output, "$.b: required property is missing") {
+		t.Fatalf("unexpected outputs: %+v, calls: %d", outputs, calls)
+	}
+	if record := result.Turns[0].ToolCalls[0]; !strings.Contains(record.Error, lkeerrors.ErrInvalidToolArgs.Error()) {
+		t.Fatalf("unexpected record: %+v", record)
+	}
+	if output := srv.LastRequest().ToolOuputs[0].Output; output != "3" {
+		t.Fatalf("except output 3, actual: %s", output)
+	}
+}
+
+func TestRunToolArgsValidationCustomVariables(t *testing.T) {
+	srv := lketest.NewServer(
+		lketest.NewTurn(lketest.Interrupt("Agent-A", lketest.ToolCall("call-1", "whoami", `{"query":"hi"}`))),
+		lketest.NewTurn(lketest.Reply("done")),
+	)
+	defer srv.Close()
+	var calls int32
+	client := newTestClient(t, srv, &calls)
+	// 必填的 _user_guid 来自用户自定义参数，模型不需要给出
+	whoami, err := tool.NewFunctionTool("whoami", "who am i", func(p struct {
+		Query    string `json:"query"`
+		UserGUID string `json:"_user_guid"`
+	}) string {
+		return p.UserGUID
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.AddFunctionTools("Agent-A", []*tool.FunctionTool{whoami})
+	_, err = client.Run("hi", &model.Options{CustomVariables: map[string]string{"_user_guid": "user-1"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if output := srv.LastRequest().ToolOuputs[0].Output; output != "user-1" {
+		t.Fatalf("except custom variable validated and passed to the tool, actual: %s", output)
+	}
+}
+
+func TestRunToolArgsValidationTypedCustomVariables(t *testing.T) {
+	srv := lketest.NewServer(
+		lketest.NewTurn(lketest.Interrupt("Agent-A", lketest.ToolCall("call-1", "page", `{"query":"hi"}`))),
+		lketest.NewTurn(lketest.Reply("done")),
+		lketest.NewTurn(lketest.Interrupt("Agent-A", lketest.ToolCall("call-2", "page", `{"query":"hi"}`))),
+		lketest.NewTurn(lketest.Reply("done")),
+	)
+	defer srv.Close()
+	var calls int32
+	client := newTestClient(t, srv, &calls)
+	// 自定义参数是字符串，按 schema 声明的类型转换后校验
+	page, err := tool.NewFunctionTool("page", "page", func(p struct {
+		Query string `json:"query"`
+		Limit int    `json:"_limit"`
+		Debug bool   `json:"_debug"`
+	}) string {
+		return fmt.Sprintf("%d,%v", p.Limit, p.Debug)
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.AddFunctionTools("Agent-A", []*tool.FunctionTool{page})
+	vars := map[string]string{"_limit": "10", "_debug": "true"}
+	if _, err := client.Run("hi", &model.Options{CustomVariables: vars}); err != nil {
+		t.Fatal(err)
+	}
+	if output := srv.LastRequest().ToolOuputs[0].Output; output != "10,true" {
+		t.Fatalf("except typed custom variables validated and passed to the tool, actual: %s", output)
+	}
+
+	vars = map[string]string{"_limit": "ten", "_debug": "true"}
+	if _, err := client.Run("hi", &model.Options{CustomVariables: vars}); err != nil {
+		t.Fatal(err)
+	}
+	if output := srv.LastRequest().ToolOuputs[0].Output; !strings.Contains(output, "$._limit: expected integer, got string") {
+		t.Fatalf("except invalid custom variable rejected, actual: %s", output)
+	}
+}
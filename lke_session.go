@@ -0,0 +1,130 @@
+package lkesdk
+
+import (
+	"context"
+	"iter"
+
+	"github.com/tencent-lke/lke-sdk-go/event"
+	"github.com/tencent-lke/lke-sdk-go/model"
+	"github.com/tencent-lke/lke-sdk-go/runner"
+	"github.com/tencent-lke/lke-sdk-go/usage"
+)
+
+// Session 一个用户的一个对话，agent、工具、handoff 等配置共用所属 client 的配置
+// Session 是轻量对象，可以被多个协程并发使用，每次执行都会生成新的 requestID
+type Session interface {
+	// GetSessionID 获取对话唯一标识
+	GetSessionID() string
+
+	// GetVisitorBizID 获取访客唯一标识
+	GetVisitorBizID() string
+
+	// UsedTokens 对话中累计消耗的 token 数，包括进行中的执行已经结束的轮次和嵌套的 agent 工具，用于对话级别的预算
+	UsedTokens() uint64
+
+	// Run 执行 agent，query 用户的输入，options 可选参数，可以为空
+	Run(query string, options *model.Options) (finalReply *event.ReplyEvent, err error)
+
+	// RunWithContext 执行 agent with context，query 用户的输入，options 可选参数，可以为空
+	RunWithContext(ctx context.Context, query string,
+		options *model.Options) (finalReply *event.ReplyEvent, err error)
+
+	// RunWithResult 执行 agent，同时返回最终回复和包括每轮请求、工具调用、token 用量的完整记录
+	// 执行失败时返回已经完成部分的记录
+	RunWithResult(ctx context.Context, query string,
+		options *model.Options) (*event.ReplyEvent, *runner.RunResult, error)
+
+	// Resume 从暂停的执行继续，input 中提供暂停时待处理的工具调用的结果
+	Resume(ctx context.Context, pending *runner.Pending,
+		input runner.ResumeInput) (*event.ReplyEvent, *runner.RunResult, error)
+
+	// Start 异步执行 agent，返回本次执行的句柄
+	Start(ctx context.Context, query string, options *model.Options) (RunHandle, error)
+
+	// RunStream 流式执行 agent，按顺序返回执行过程中的事件
+	RunStream(ctx context.Context, query string,
+		options *model.Options) iter.Seq2[event.Event, error]
+}
+
+// session Session 的实现
+type session struct {
+	client       *lkeClient
+	sessionID    string
+	visitorBizID string
+	usage        *usage.Collector // 对话中各次执行的用量
+}
+
+// NewSession 创建一个对话，visitorBizID 用户的唯一标识，sessionID 对话唯一标识
+func (c *lkeClient) NewSession(visitorBizID, sessionID string) Session {
+	return &session{
+		client:       c,
+		sessionID:    sessionID,
+		visitorBizID: visitorBizID,
+		usage:        usage.NewCollector(),
+	}
+}
+
+// GetSessionID 获取对话唯一标识
+func (s *session) GetSessionID() string {
+	return s.sessionID
+}
+
+// GetVisitorBizID 获取访客唯一标识
+func (s *session) GetVisitorBizID() string {
+	return s.visitorBizID
+}
+
+// UsedTokens 对话中累计消耗的 token 数
+func (s *session) UsedTokens() uint64 {
+	return s.usage.Total().TotalTokens
+}
+
+// Run 执行 agent，query 用户的输入，options 可选参数，可以为空
+func (s *session) Run(query string, options *model.Options) (*event.ReplyEvent, error) {
+	return s.RunWithContext(context.Background(), query, options)
+}
+
+// RunWithContext 执行 agent with context，query 用户的输入，options 可选参数，可以为空
+func (s *session) RunWithContext(ctx context.Context, query string,
+	options *model.Options) (*event.ReplyEvent, error) {
+	h, err := s.client.start(ctx, s, query, options, s.client.getEventHandler())
+	if err != nil {
+		return nil, err
+	}
+	return h.Wait()
+}
+
+// RunWithResult 执行 agent，同时返回最终回复和完整的执行记录
+func (s *session) RunWithResult(ctx context.Context, query string,
+	options *model.Options) (*event.ReplyEvent, *runner.RunResult, error) {
+	h, err := s.client.start(ctx, s, query, options, s.client.getEventHandler())
+	if err != nil {
+		return nil, nil, err
+	}
+	reply, err := h.Wait()
+	return reply, h.Result(), err
+}
+
+// Resume 从暂停的执行继续
+func (s *session) Resume(ctx context.Context, pending *runner.Pending,
+	input runner.ResumeInput) (*event.ReplyEvent, *runner.RunResult, error) {
+	h, err := s.client.startFunc(ctx, func(runCtx context.Context) (*runner.RunResult, error) {
+		return s.client.resumeWithHandler(runCtx, s, pending, input, s.client.getEventHandler())
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	reply, err := h.Wait()
+	return reply, h.Result(), err
+}
+
+// Start 异步执行 agent，返回本次执行的句柄
+func (s *session) Start(ctx context.Context, query string, options *model.Options) (RunHandle, error) {
+	return s.client.start(ctx, s, query, options, s.client.getEventHandler())
+}
+
+// RunStream 流式执行 agent，按顺序返回执行过程中的事件
+func (s *session) RunStream(ctx context.Context, query string,
+	options *model.Options) iter.Seq2[event.Event, error] {
+	return s.client.runStream(ctx, s, query, options)
+}
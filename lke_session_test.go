@@ -0,0 +1,146 @@
+package lkesdk_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	lkesdk "github.com/tencent-lke/lke-sdk-go"
+	"github.com/tencent-lke/lke-sdk-go/event"
+	"github.com/tencent-lke/lke-sdk-go/lkeerrors"
+	"github.com/tencent-lke/lke-sdk-go/lketest"
+	"github.com/tencent-lke/lke-sdk-go/model"
+	"github.com/tencent-lke/lke-sdk-go/tool"
+)
+
+func TestSessions(t *testing.T) {
+	srv := lketest.NewServer()
+	defer srv.Close()
+	srv.SetHandler(func(req *model.ChatRequest) lketest.Turn {
+		return lketest.NewTurn(
+			lketest.TokenStat(event.TokenStatEvent{Procedures: []event.Procedure{{Name: event.ProcedureLLM, Count: 10}}}),
+			lketest.Reply(req.SessionID),
+		)
+	})
+	var calls int32
+	client := newTestClient(t, srv, &calls)
+	s1 := client.NewSession("user-1", "session-1")
+	s2 := client.NewSession("user-2", "session-2")
+
+	// 不同对话并发执行，请求和 token 用量互不影响
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		for _, s := range []lkesdk.Session{s1, s2} {
+			wg.Add(1)
+			go func(s lkesdk.Session) {
+				defer wg.Done()
+				reply, err := s.Run("hi", nil)
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				if reply.Content != s.GetSessionID() {
+					t.Errorf("except reply of %s, actual: %s", s.GetSessionID(), reply.Content)
+				}
+			}(s)
+		}
+	}
+	wg.Wait()
+	if s1.UsedTokens() != 40 || s2.UsedTokens() != 40 {
+		t.Fatalf("except 40 tokens for each session, actual: %d, %d", s1.UsedTokens(), s2.UsedTokens())
+	}
+	requestIDs := map[string]bool{}
+	for _, req := range srv.Requests() {
+		if (req.SessionID == "session-1") != (req.VisitorBizID == "user-1") {
+			t.Fatalf("session and visitor mismatch: %s, %s", req.SessionID, req.VisitorBizID)
+		}
+		requestIDs[req.RequestID] = true
+	}
+	if len(requestIDs) != 8 {
+		t.Fatalf("except a new request id for each run, actual: %d", len(requestIDs))
+	}
+
+	// 同一个对话可以继续使用，token 用量继续累计
+	if _, err := s1.Run("again", nil); err != nil {
+		t.Fatal(err)
+	}
+	if req := srv.LastRequest(); req.SessionID != "session-1" || req.VisitorBizID != "user-1" {
+		t.Fatalf("except session reused, actual: %s, %s", req.SessionID, req.VisitorBizID)
+	}
+	if s1.UsedTokens() != 50 || s2.UsedTokens() != 40 || client.NewSession("user-1", "session-1").UsedTokens() != 0 {
+		t.Fatalf("unexpected used tokens: %d, %d", s1.UsedTokens(), s2.UsedTokens())
+	}
+}
+
+func TestSessionBudget(t *testing.T) {
+	stat := lketest.TokenStat(event.TokenStatEvent{
+		Procedures: []event.Procedure{{Name: event.ProcedureLLM, Count: 100}},
+	})
+	srv := lketest.NewServer(lketest.NewTurn(stat, lketest.Reply("ok")), lketest.NewTurn(stat, lketest.Reply("ok")))
+	defer srv.Close()
+	var calls int32
+	client := newTestClient(t, srv, &calls)
+
+	// 对话级别的预算累计之前的执行，单次执行的预算覆盖 client 上的预算
+	client.SetBudget(&model.Budget{MaxSessionTokens: 1000})
+	session := client.NewSession("visitor", "budget-session")
+	options := &model.Options{Budget: &model.Budget{MaxSessionTokens: 150}}
+	if _, err := session.Run("hi", options); err != nil {
+		t.Fatal(err)
+	}
+	if session.UsedTokens() != 100 {
+		t.Fatalf("except 100 used tokens, actual: %d", session.UsedTokens())
+	}
+	if _, err := session.Run("hi", options); !errors.Is(err, lkeerrors.ErrBudgetExceeded) {
+		t.Fatalf("except ErrBudgetExceeded, actual: %v", err)
+	}
+}
+
+func TestSessionBudgetConcurrentRuns(t *testing.T) {
+	stat := lketest.TokenStat(event.TokenStatEvent{
+		Procedures: []event.Procedure{{Name: event.ProcedureLLM, Count: 100}},
+	})
+	srv := lketest.NewServer()
+	defer srv.Close()
+	srv.SetHandler(func(req *model.ChatRequest) lketest.Turn {
+		if req.Content == "wait" {
+			return lketest.NewTurn(stat, lketest.Interrupt("Agent-A", lketest.ToolCall("call-1", "wait", `{}`)))
+		}
+		return lketest.NewTurn(stat, lketest.Reply("ok"))
+	})
+	var calls int32
+	client := newTestClient(t, srv, &calls)
+	waiting, release := make(chan struct{}), make(chan struct{})
+	wait, err := tool.NewFunctionTool("wait", "wait until released", func(p struct{}) string {
+		close(waiting)
+		<-release
+		return "released"
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.AddFunctionTools("Agent-A", []*tool.FunctionTool{wait})
+	client.SetBudget(&model.Budget{MaxSessionTokens: 250})
+	session := client.NewSession("visitor", "concurrent-session")
+	h, err := session.Start(context.Background(), "wait", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 第一个执行还在调用本地工具，已经结束的一轮的用量已经计入对话
+	<-waiting
+	if session.UsedTokens() != 100 {
+		t.Fatalf("except 100 used tokens while the first run is in progress, actual: %d", session.UsedTokens())
+	}
+	if _, err := session.Run("hi", nil); err != nil {
+		t.Fatal(err)
+	}
+	// 并发执行的用量计入对话后，第一个执行的下一轮超过对话预算
+	close(release)
+	if _, err := h.Wait(); !errors.Is(err, lkeerrors.ErrBudgetExceeded) {
+		t.Fatalf("except ErrBudgetExceeded after the concurrent run, actual: %v", err)
+	}
+	if session.UsedTokens() != 200 || len(srv.Requests()) != 2 {
+		t.Fatalf("except 200 used tokens in 2 requests, actual: %d, %d", session.UsedTokens(), len(srv.Requests()))
+	}
+}
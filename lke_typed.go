@@ -0,0 +1,98 @@
+package lkesdk
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/tencent-lke/lke-sdk-go/event"
+	"github.com/tencent-lke/lke-sdk-go/jsonschema"
+	"github.com/tencent-lke/lke-sdk-go/lkeerrors"
+	"github.com/tencent-lke/lke-sdk-go/model"
+	"github.com/tencent-lke/lke-sdk-go/runner"
+	"github.com/tencent-lke/lke-sdk-go/tool"
+	"github.com/tencent-lke/lke-sdk-go/usage"
+)
+
+// defaultOutputRepairs 结构化输出校验失败时默认重新提示模型的次数
+const defaultOutputRepairs = 2
+
+// TypedRunner 可以执行结构化输出的对象，LkeClient 和 Session 都实现了该接口
+type TypedRunner interface {
+	RunWithResult(ctx context.Context, query string,
+		options *model.Options) (*event.ReplyEvent, *runner.RunResult, error)
+}
+
+// RunTyped 执行 agent，要求最终回复是符合 T 的 JSON schema 的 json，解析成 T 返回
+// 回复中多余的 ```json 代码块标记会被去掉，回复无法解析或者不符合 schema 时，在同一个对话中带上错误信息
+// 重新提示模型修正，最多 options.OutputRepairs 次，仍然失败时返回 *lkeerrors.OutputError
+// 返回的 RunResult 为最后一次执行的记录，UsageSummary 包括之前各次执行的用量
+func RunTyped[T any](ctx context.Context, r TypedRunner, query string,
+	options *model.Options) (T, *runner.RunResult, error) {
+	var zero T
+	schema, err := tool.SchemaOf(reflect.TypeOf((*T)(nil)).Elem())
+	if err != nil {
+		return zero, nil, fmt.Errorf("failed to generate output schema: %w", err)
+	}
+	instruction := tool.OutputInstruction(schema)
+	repairs := defaultOutputRepairs
+	if options != nil && options.OutputRepairs != 0 {
+		repairs = max(options.OutputRepairs, 0)
+	}
+	prompt := query + "\n\n" + instruction
+	summary := &usage.Summary{}
+	for attempt := 1; ; attempt++ {
+		reply, result, err := r.RunWithResult(ctx, prompt, options)
+		if result != nil {
+			summary.Add(result.UsageSummary)
+			result.UsageSummary = summary
+		}
+		if err != nil {
+			return zero, result, err
+		}
+		if reply == nil {
+			return zero, result, lkeerrors.ErrNoFinalReply
+		}
+		out, err := decodeOutput[T](reply.Content, schema)
+		if err == nil {
+			return out, result, nil
+		}
+		if attempt > repairs {
+			return zero, result, &lkeerrors.OutputError{Content: reply.Content, Attempts: attempt, Err: err}
+		}
+		prompt = fmt.Sprintf("Your previous response is invalid, error: %v\n\n%s", err, instruction)
+	}
+}
+
+// decodeOutput 去掉代码块标记后按 schema 校验回复，再解析成 T
+func decodeOutput[T any](content string, schema map[string]interface{}) (T, error) {
+	var out T
+	content = stripCodeFence(content)
+	var value interface{}
+	if err := json.Unmarshal([]byte(content), &value); err != nil {
+		return out, fmt.Errorf("response is not valid JSON: %v", err)
+	}
+	if err := jsonschema.Validate(schema, value); err != nil {
+		return out, err
+	}
+	if err := json.Unmarshal([]byte(content), &out); err != nil {
+		return out, fmt.Errorf("response does not match the schema: %v", err)
+	}
+	return out, nil
+}
+
+// stripCodeFence 去掉回复首尾的 ``` 或 ```json 代码块标记
+func stripCodeFence(content string) string {
+	content = strings.TrimSpace(content)
+	if !strings.HasPrefix(content, "```") {
+		return content
+	}
+	if i := strings.IndexByte(content, '\n'); i >= 0 {
+		content = content[i+1:]
+	} else {
+		content = strings.TrimPrefix(content, "```")
+	}
+	return strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(content), "```"))
+}
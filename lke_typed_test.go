@@ -0,0 +1,96 @@
+package lkesdk_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	lkesdk "github.com/tencent-lke/lke-sdk-go"
+	"github.com/tencent-lke/lke-sdk-go/event"
+	"github.com/tencent-lke/lke-sdk-go/lkeerrors"
+	"github.com/tencent-lke/lke-sdk-go/lketest"
+	"github.com/tencent-lke/lke-sdk-go/model"
+	"github.com/tencent-lke/lke-sdk-go/tool"
+)
+
+type orderSummary struct {
+	OrderID string   `json:"order_id"`
+	Amount  float64  `json:"amount"`
+	Items   []string `json:"items"`
+}
+
+func TestRunTyped(t *testing.T) {
+	srv := lketest.NewServer(
+		lketest.NewTurn(lketest.TokenStat(event.TokenStatEvent{TokenCount: 100}),
+			lketest.Reply("```json\n{\"order_id\": \"A1\", \"amount\": \"12\"}\n```")),
+		lketest.NewTurn(lketest.TokenStat(event.TokenStatEvent{TokenCount: 50}),
+			lketest.Reply("```json\n{\"order_id\": \"A1\", \"amount\": 12, \"items\": [\"apple\"]}\n```")),
+	)
+	defer srv.Close()
+	var calls int32
+	client := newTestClient(t, srv, &calls)
+	out, result, err := lkesdk.RunTyped[orderSummary](context.Background(), client, "summarize order A1", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out.OrderID != "A1" || out.Amount != 12 || len(out.Items) != 1 || result.FinalReply == nil {
+		t.Fatalf("unexpected output: %+v", out)
+	}
+	// 用量包括修正前的执行
+	if result.Tokens() != 150 {
+		t.Fatalf("except usage of all attempts, actual: %d", result.Tokens())
+	}
+	reqs := srv.Requests()
+	if !strings.Contains(reqs[0].Content, `"order_id"`) ||
+		!strings.Contains(reqs[1].Content, "$.amount: expected number, got string") ||
+		!strings.Contains(reqs[1].Content, "$.items: required property is missing") {
+		t.Fatalf("unexpected prompts: %s\n%s", reqs[0].Content, reqs[1].Content)
+	}
+}
+
+func TestRunTypedInvalidOutput(t *testing.T) {
+	srv := lketest.NewServer(
+		lketest.NewTurn(lketest.Reply("order A1 costs 12")),
+		lketest.NewTurn(lketest.Reply("order A1 costs 12")),
+	)
+	defer srv.Close()
+	var calls int32
+	client := newTestClient(t, srv, &calls)
+	_, _, err := lkesdk.RunTyped[orderSummary](context.Background(), client, "summarize order A1",
+		&model.Options{OutputRepairs: 1})
+	var outErr *lkeerrors.OutputError
+	if !errors.Is(err, lkeerrors.ErrInvalidOutput) || !errors.As(err, &outErr) || outErr.Attempts != 2 ||
+		outErr.Content != "order A1 costs 12" {
+		t.Fatalf("except invalid output error, actual: %v", err)
+	}
+	if len(srv.Requests()) != 2 {
+		t.Fatalf("except 2 requests, actual: %d", len(srv.Requests()))
+	}
+}
+
+func TestRunTypedTool(t *testing.T) {
+	srv := lketest.NewServer(
+		lketest.NewTurn(lketest.Interrupt("Agent-A",
+			lketest.ToolCall("call-1", "sum", `{"a":1,"b":2}`),
+		)),
+		lketest.NewTurn(lketest.Reply("done")),
+	)
+	defer srv.Close()
+	var calls int32
+	client := newTestClient(t, srv, &calls)
+	sum, err := tool.NewTypedTool("sum", "add two numbers", func(ctx context.Context, p addParams) (int, error) {
+		return p.A + p.B, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.AddTools("Agent-A", []tool.Tool{sum})
+	_, err = client.Run("1+2", &model.Options{CustomVariables: map[string]string{"tenant": "t1"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if output := srv.LastRequest().ToolOuputs[0].Output; output != "3" {
+		t.Fatalf("except output 3, actual: %s", output)
+	}
+}
@@ -0,0 +1,139 @@
+// Package lkeerrors sdk 返回的错误类型，可以通过 errors.Is/errors.As 判断
+package lkeerrors
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/tencent-lke/lke-sdk-go/event"
+)
+
+// sdk 执行过程中的错误
+var (
+	ErrClientClosed   = errors.New("client has been closed")          // client 已经 Close
+	ErrMaxToolTurns   = errors.New("reached maximum tool call turns") // 本地工具调用超过最大轮数
+	ErrNoFinalReply   = errors.New("no final reply from server")      // 云端没有返回最终回复
+	ErrToolTimeout    = errors.New("tool run timeout")                // 本地工具执行超时
+	ErrToolPanic      = errors.New("tool panic")                      // 本地工具执行 panic
+	ErrInvalidConfig  = errors.New("invalid agent config")            // agent、handoff、工具配置校验失败
+	ErrBudgetExceeded = errors.New("token budget exceeded")           // token 用量超过预算
+	ErrToolRejected   = errors.New("tool call rejected")              // 本地工具调用被审批拒绝
+	ErrRunPaused      = errors.New("run paused")                      // 执行暂停，等待 Resume
+	// ErrInvalidToolArgs 模型给出的工具参数不符合参数 schema，工具没有执行
+	ErrInvalidToolArgs = errors.New("invalid tool arguments")
+	// ErrInvalidOutput 最终回复不符合结构化输出的 schema，具体信息见 *OutputError
+	ErrInvalidOutput = errors.New("invalid structured output")
+)
+
+// 云端接口错误的分类，*APIError 可以通过 errors.Is 与之比较
+var (
+	ErrUnauthorized  = errors.New("unauthorized")       // 鉴权失败，http 401/403
+	ErrRateLimited   = errors.New("rate limited")       // 并发或配额超限，http 429
+	ErrInvalidParam  = errors.New("invalid parameter")  // 请求参数错误，http 400/404/422
+	ErrServerUnavail = errors.New("server unavailable") // 服务端错误或繁忙，http 5xx
+)
+
+// APIError 云端接口返回的错误，包括非 200 的 http 响应和 sse 中的错误事件
+type APIError struct {
+	Code       int    // 错误事件中的错误码，http 错误时为 0
+	Message    string // 错误信息，http 错误时为响应内容
+	RequestID  string // 请求 ID
+	TraceId    string // 云端 trace id，用于问题排查
+	HTTPStatus int    // http 状态码，错误事件时为 200
+}
+
+// NewAPIErrorFromEvent 从 sse 错误事件构建 APIError
+func NewAPIErrorFromEvent(ev *event.ErrorEvent) *APIError {
+	return &APIError{
+		Code:       ev.Error.Code,
+		Message:    ev.Error.Message,
+		RequestID:  ev.RequestID,
+		TraceId:    ev.TraceId,
+		HTTPStatus: http.StatusOK,
+	}
+}
+
+// Error 实现 error 接口
+func (e *APIError) Error() string {
+	if e.HTTPStatus != http.StatusOK {
+		return fmt.Sprintf("lke api error: http status %d, message: %s, request_id: %s",
+			e.HTTPStatus, e.Message, e.RequestID)
+	}
+	return fmt.Sprintf("lke api error: code %d, message: %s, request_id: %s, trace_id: %s",
+		e.Code, e.Message, e.RequestID, e.TraceId)
+}
+
+// Is 把错误归类到 ErrUnauthorized, ErrRateLimited, ErrInvalidParam, ErrServerUnavail
+// http 错误按状态码归类，错误事件按错误码归类，参考 EventCodeClass
+func (e *APIError) Is(target error) bool {
+	if e.HTTPStatus != http.StatusOK {
+		return target == StatusClass(e.HTTPStatus)
+	}
+	return target == EventCodeClass(e.Code)
+}
+
+// StatusClass http 状态码的错误分类，无法归类时返回空
+func StatusClass(status int) error {
+	switch {
+	case status == http.StatusUnauthorized || status == http.StatusForbidden:
+		return ErrUnauthorized
+	case status == http.StatusTooManyRequests:
+		return ErrRateLimited
+	case status == http.StatusBadRequest || status == http.StatusNotFound ||
+		status == http.StatusUnprocessableEntity:
+		return ErrInvalidParam
+	case status >= http.StatusInternalServerError:
+		return ErrServerUnavail
+	}
+	return nil
+}
+
+// eventCodeClasses 错误事件中已知错误码的分类
+var eventCodeClasses = map[int]error{
+	460001: ErrUnauthorized,  // token 校验失败
+	460004: ErrInvalidParam,  // 应用不存在
+	460006: ErrInvalidParam,  // 消息不存在或已被撤回
+	460009: ErrInvalidParam,  // 会话不存在或已被删除
+	460011: ErrRateLimited,   // 超出并发数限制
+	460020: ErrServerUnavail, // 模型请求超时
+	460031: ErrRateLimited,   // 模型余额不足
+}
+
+// EventCodeClass 错误事件的错误码分类，无法归类时返回空
+// 错误码在 400~599 之间时按 http 状态码归类，其他错误码按已知错误码归类，
+// 没有单独列出的 460xxx 错误码归为 ErrInvalidParam
+func EventCodeClass(code int) error {
+	if code >= 400 && code < 600 {
+		return StatusClass(code)
+	}
+	if class, ok := eventCodeClasses[code]; ok {
+		return class
+	}
+	if code >= 460000 && code < 461000 {
+		return ErrInvalidParam
+	}
+	return nil
+}
+
+// OutputError 结构化输出的最终回复无法解析或者不符合 schema，重新提示模型修正后仍然失败
+type OutputError struct {
+	Content  string // 最后一次回复的内容
+	Attempts int    // 执行的次数，包括重新提示
+	Err      error  // 最后一次解析或者校验的错误
+}
+
+// Error 实现 error 接口
+func (e *OutputError) Error() string {
+	return fmt.Sprintf("%v after %d attempts: %v", ErrInvalidOutput, e.Attempts, e.Err)
+}
+
+// Unwrap 返回解析或者校验的错误
+func (e *OutputError) Unwrap() error {
+	return e.Err
+}
+
+// Is 可以通过 errors.Is(err, ErrInvalidOutput) 判断
+func (e *OutputError) Is(target error) bool {
+	return target == ErrInvalidOutput
+}
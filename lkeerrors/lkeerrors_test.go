@@ -0,0 +1,75 @@
+package lkeerrors_test
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/tencent-lke/lke-sdk-go/event"
+	"github.com/tencent-lke/lke-sdk-go/lkeerrors"
+)
+
+func TestAPIErrorIs(t *testing.T) {
+	httpErr := func(status int) error {
+		return &lkeerrors.APIError{HTTPStatus: status}
+	}
+	eventErr := func(code int) error {
+		ev := &event.ErrorEvent{}
+		ev.Error.Code = code
+		return lkeerrors.NewAPIErrorFromEvent(ev)
+	}
+	for _, tc := range []struct {
+		err    error
+		except error
+	}{
+		{httpErr(http.StatusUnauthorized), lkeerrors.ErrUnauthorized},
+		{httpErr(http.StatusForbidden), lkeerrors.ErrUnauthorized},
+		{httpErr(http.StatusTooManyRequests), lkeerrors.ErrRateLimited},
+		{httpErr(http.StatusBadRequest), lkeerrors.ErrInvalidParam},
+		{httpErr(http.StatusBadGateway), lkeerrors.ErrServerUnavail},
+		{httpErr(http.StatusConflict), nil},
+		{eventErr(460001), lkeerrors.ErrUnauthorized},
+		{eventErr(460004), lkeerrors.ErrInvalidParam},
+		{eventErr(460011), lkeerrors.ErrRateLimited},
+		{eventErr(460020), lkeerrors.ErrServerUnavail},
+		{eventErr(460099), lkeerrors.ErrInvalidParam},
+		{eventErr(http.StatusTooManyRequests), lkeerrors.ErrRateLimited},
+		{eventErr(http.StatusServiceUnavailable), lkeerrors.ErrServerUnavail},
+		{eventErr(1), nil},
+	} {
+		wrapped := fmt.Errorf("run: %w", tc.err)
+		for _, class := range []error{
+			lkeerrors.ErrUnauthorized, lkeerrors.ErrRateLimited,
+			lkeerrors.ErrInvalidParam, lkeerrors.ErrServerUnavail,
+		} {
+			if errors.Is(wrapped, class) != (class == tc.except) {
+				t.Fatalf("%v: except class %v, errors.Is(%v) = %v", tc.err, tc.except, class, !(class == tc.except))
+			}
+		}
+	}
+}
+
+func TestAPIErrorMessage(t *testing.T) {
+	ev := &event.ErrorEvent{RequestID: "req-1"}
+	ev.TraceId = "trace-1"
+	ev.Error.Code = 460004
+	ev.Error.Message = "bot not found"
+	err := lkeerrors.NewAPIErrorFromEvent(ev)
+	if err.Code != 460004 || err.HTTPStatus != http.StatusOK ||
+		err.Error() != "lke api error: code 460004, message: bot not found, request_id: req-1, trace_id: trace-1" {
+		t.Fatalf("unexpected event error: %v", err)
+	}
+	err = &lkeerrors.APIError{HTTPStatus: http.StatusBadGateway, Message: "bad gateway", RequestID: "req-2"}
+	if err.Error() != "lke api error: http status 502, message: bad gateway, request_id: req-2" {
+		t.Fatalf("unexpected http error: %v", err)
+	}
+}
+
+func TestOutputError(t *testing.T) {
+	cause := errors.New("missing field")
+	err := fmt.Errorf("typed: %w", &lkeerrors.OutputError{Content: "{}", Attempts: 2, Err: cause})
+	if !errors.Is(err, lkeerrors.ErrInvalidOutput) || !errors.Is(err, cause) {
+		t.Fatalf("except ErrInvalidOutput wrapping the cause, actual: %v", err)
+	}
+}
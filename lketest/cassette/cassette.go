@@ -0,0 +1,294 @@
+// Package cassette 录制和回放与知识引擎的真实对话，用于离线回归测试本地工具和事件处理器
+//
+// 录制时把每次请求的 body 和返回的 sse 原始帧保存到 json 文件，回放时按请求内容匹配并原样返回：
+//
+//	rec, err := cassette.New("testdata/add.json", cassette.ModeReplay)
+//	if err != nil {
+//		t.Fatal(err)
+//	}
+//	client.SetHttpClient(rec.Client())
+package cassette
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Mode 录制回放模式
+type Mode int
+
+const (
+	// ModeReplay 只回放，没有匹配的录制记录时请求返回错误
+	ModeReplay Mode = iota
+	// ModeRecordNew 匹配的请求回放，没有匹配的请求发送到真实服务并追加到文件
+	ModeRecordNew
+)
+
+// ErrNoInteraction 回放模式下没有匹配的录制记录
+var ErrNoInteraction = errors.New("cassette: no recorded interaction matches request")
+
+// ignoredFields 匹配和保存请求时忽略的字段，request_id 每次执行都不同，bot_app_key 不写入文件
+var ignoredFields = []string{"request_id", "bot_app_key"}
+
+// Interaction 一次请求和响应
+type Interaction struct {
+	Request    json.RawMessage `json:"request"`        // 去掉忽略字段后的请求 body
+	StatusCode int             `json:"status_code"`    // http 状态码
+	Frames     []string        `json:"frames"`         // 200 时返回的 sse 原始帧，不包括帧之间的空行
+	Body       string          `json:"body,omitempty"` // 非 200 时的响应内容
+}
+
+// Cassette 录制文件的内容
+type Cassette struct {
+	Interactions []*Interaction `json:"interactions"`
+}
+
+// Recorder 录制回放的 http.RoundTripper
+type Recorder struct {
+	path      string
+	mode      Mode
+	transport http.RoundTripper
+
+	mu       sync.Mutex
+	cassette *Cassette
+	used     []bool
+}
+
+// New 创建 Recorder，path 为录制文件路径
+// ModeReplay 模式下文件必须存在，ModeRecordNew 模式下文件不存在时会在录制后创建
+func New(path string, mode Mode) (*Recorder, error) {
+	r := &Recorder{
+		path:      path,
+		mode:      mode,
+		transport: http.DefaultTransport,
+		cassette:  &Cassette{},
+	}
+	bs, err := os.ReadFile(path)
+	switch {
+	case err == nil:
+		if err := json.Unmarshal(bs, r.cassette); err != nil {
+			return nil, fmt.Errorf("cassette: parse %s error: %v", path, err)
+		}
+		// 文件中的请求是格式化过的，重新规范化后再匹配
+		for _, it := range r.cassette.Interactions {
+			if it.Request, err = Normalize(it.Request); err != nil {
+				return nil, err
+			}
+		}
+	case errors.Is(err, os.ErrNotExist) && mode == ModeRecordNew:
+	default:
+		return nil, fmt.Errorf("cassette: read %s error: %v", path, err)
+	}
+	r.used = make([]bool, len(r.cassette.Interactions))
+	return r, nil
+}
+
+// SetTransport 设置录制时发送真实请求的 http.RoundTripper，默认为 http.DefaultTransport
+func (r *Recorder) SetTransport(transport http.RoundTripper) {
+	r.transport = transport
+}
+
+// Client 返回使用该 Recorder 的 http client，用于 SetHttpClient
+func (r *Recorder) Client() *http.Client {
+	return &http.Client{Transport: r}
+}
+
+// Cassette 返回当前的录制内容
+func (r *Recorder) Cassette() *Cassette {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return &Cassette{Interactions: append([]*Interaction{}, r.cassette.Interactions...)}
+}
+
+// RoundTrip 实现 http.RoundTripper，按顺序匹配第一个未使用过且请求内容相同的录制记录
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil {
+		var err error
+		body, err = io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("cassette: read request body error: %v", err)
+		}
+	}
+	normalized, err := Normalize(body)
+	if err != nil {
+		return nil, err
+	}
+	if it := r.match(normalized); it != nil {
+		return it.response(req), nil
+	}
+	if r.mode != ModeRecordNew {
+		return nil, fmt.Errorf("%w: %s", ErrNoInteraction, normalized)
+	}
+	it, err := r.record(req, body, normalized)
+	if err != nil {
+		return nil, err
+	}
+	return it.response(req), nil
+}
+
+// match 查找并标记匹配的录制记录
+func (r *Recorder) match(normalized []byte) *Interaction {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i, it := range r.cassette.Interactions {
+		if !r.used[i] && bytes.Equal(it.Request, normalized) {
+			r.used[i] = true
+			return it
+		}
+	}
+	return nil
+}
+
+// record 发送真实请求，读取完整的响应后追加到录制文件
+func (r *Recorder) record(req *http.Request, body, normalized []byte) (*Interaction, error) {
+	realReq := req.Clone(req.Context())
+	realReq.Body = io.NopCloser(bytes.NewReader(body))
+	realReq.ContentLength = int64(len(body))
+	res, err := r.transport.RoundTrip(realReq)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	resBody, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+	it := &Interaction{
+		Request:    normalized,
+		StatusCode: res.StatusCode,
+	}
+	if res.StatusCode == http.StatusOK {
+		it.Frames = splitFrames(string(resBody))
+	} else {
+		it.Body = string(resBody)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.cassette.Interactions = append(r.cassette.Interactions, it)
+	r.used = append(r.used, true)
+	if err := r.save(); err != nil {
+		return nil, err
+	}
+	return it, nil
+}
+
+// save 写入录制文件，调用方需要持有锁
+func (r *Recorder) save() error {
+	bs, err := json.MarshalIndent(r.cassette, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(r.path), 0o755); err != nil {
+		return fmt.Errorf("cassette: create dir error: %v", err)
+	}
+	if err := os.WriteFile(r.path, bs, 0o644); err != nil {
+		return fmt.Errorf("cassette: write %s error: %v", r.path, err)
+	}
+	return nil
+}
+
+// response 根据录制记录构建响应
+func (it *Interaction) response(req *http.Request) *http.Response {
+	header := http.Header{}
+	var body string
+	if it.StatusCode == http.StatusOK {
+		header.Set("Content-Type", "text/event-stream")
+		for _, frame := range it.Frames {
+			body += frame + "\n\n"
+		}
+	} else {
+		header.Set("Content-Type", "text/plain; charset=utf-8")
+		body = it.Body
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", it.StatusCode, http.StatusText(it.StatusCode)),
+		StatusCode:    it.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(strings.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}
+}
+
+// splitFrames 按空行切分 sse 帧
+func splitFrames(body string) []string {
+	body = strings.ReplaceAll(body, "\r\n", "\n")
+	frames := []string{}
+	for _, frame := range strings.Split(body, "\n\n") {
+		if strings.TrimSpace(frame) != "" {
+			frames = append(frames, frame)
+		}
+	}
+	return frames
+}
+
+// nestedSessionSuffix agent 工具嵌套执行的对话 ID 后缀，格式为 _<agent 工具编号>_<调用序号>
+// 编号是进程内的全局计数，录制和回放时可能不同
+var nestedSessionSuffix = regexp.MustCompile(`^_\d+_\d+$`)
+
+// Normalize 去掉请求 body 中的 request_id、bot_app_key 并按 key 排序，用于匹配录制记录
+// agent 工具嵌套执行的对话 ID 中的编号替换为 *，回放时不依赖注册和调用的顺序
+// agent_config.agent_tools 按 agent_name 排序，兼容工具顺序不固定时录制的记录
+func Normalize(body []byte) ([]byte, error) {
+	if len(bytes.TrimSpace(body)) == 0 {
+		return []byte("null"), nil
+	}
+	var v interface{}
+	if err := json.Unmarshal(body, &v); err != nil {
+		return nil, fmt.Errorf("cassette: request body is not json: %v", err)
+	}
+	if m, ok := v.(map[string]interface{}); ok {
+		for _, field := range ignoredFields {
+			delete(m, field)
+		}
+		normalizeNestedSession(m)
+		normalizeAgentTools(m)
+	}
+	return json.Marshal(v)
+}
+
+// normalizeNestedSession 替换嵌套执行的对话 ID 中的编号
+// 只处理 session_id 为 <_user_task_id>_<编号>_<序号> 的请求，_user_task_id 是 agent 工具设置的发起调用的对话 ID
+func normalizeNestedSession(m map[string]interface{}) {
+	sessionID, ok := m["session_id"].(string)
+	if !ok {
+		return
+	}
+	vars, _ := m["custom_variables"].(map[string]interface{})
+	parent, ok := vars["_user_task_id"].(string)
+	if !ok || !strings.HasPrefix(sessionID, parent) ||
+		!nestedSessionSuffix.MatchString(sessionID[len(parent):]) {
+		return
+	}
+	m["session_id"] = parent + "_*_*"
+}
+
+// normalizeAgentTools 按 agent_name 排序 agent_config.agent_tools
+func normalizeAgentTools(m map[string]interface{}) {
+	config, _ := m["agent_config"].(map[string]interface{})
+	tools, ok := config["agent_tools"].([]interface{})
+	if !ok {
+		return
+	}
+	name := func(i int) string {
+		t, _ := tools[i].(map[string]interface{})
+		s, _ := t["agent_name"].(string)
+		return s
+	}
+	sort.SliceStable(tools, func(i, j int) bool { return name(i) < name(j) })
+}
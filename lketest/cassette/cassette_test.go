@@ -0,0 +1,223 @@
+package cassette_test
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	lkesdk "github.com/tencent-lke/lke-sdk-go"
+	"github.com/tencent-lke/lke-sdk-go/lketest"
+	"github.com/tencent-lke/lke-sdk-go/lketest/cassette"
+	"github.com/tencent-lke/lke-sdk-go/model"
+	"github.com/tencent-lke/lke-sdk-go/tool"
+)
+
+type testLogger struct{}
+
+func (testLogger) Info(message string)  {}
+func (testLogger) Error(message string) {}
+
+func newClient(t *testing.T, endpoint string, calls *int32) lkesdk.LkeClient {
+	type Params struct {
+		A int `json:"a" doc:"number a"`
+		B int `json:"b" doc:"number b"`
+	}
+	client := lkesdk.NewLkeClient("test-app-key", "visitor", "session", nil)
+	client.SetRunLogger(testLogger{})
+	client.SetEndpoint(endpoint)
+	client.AddAgents([]model.Agent{
+		model.NewAgent("Agent-A", "agent a", "agent a", model.DefaultModel, nil, nil),
+	})
+	client.SetStartAgent("Agent-A")
+	add, err := tool.NewFunctionTool("add", "add two numbers", func(p Params) int {
+		atomic.AddInt32(calls, 1)
+		return p.A + p.B
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.AddFunctionTools("Agent-A", []*tool.FunctionTool{add})
+	return client
+}
+
+func TestRecordAndReplay(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "add.json")
+	srv := lketest.NewServer(
+		lketest.NewTurn(lketest.Interrupt("Agent-A", lketest.ToolCall("call-1", "add", `{"a":1,"b":2}`))),
+		lketest.NewTurn(lketest.Reply("1+2=3")),
+	)
+	rec, err := cassette.New(path, cassette.ModeRecordNew)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var calls int32
+	client := newClient(t, srv.URL, &calls)
+	client.SetHttpClient(rec.Client())
+	if _, err := client.Run("1+2", nil); err != nil {
+		t.Fatal(err)
+	}
+	srv.Close()
+	if n := len(rec.Cassette().Interactions); n != 2 {
+		t.Fatalf("except 2 interactions recorded, actual: %d", n)
+	}
+
+	// 服务已经关闭，回放不依赖网络，request_id 不同也能匹配
+	replay, err := cassette.New(path, cassette.ModeReplay)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client = newClient(t, srv.URL, &calls)
+	client.SetHttpClient(replay.Client())
+	reply, err := client.Run("1+2", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reply.Content != "1+2=3" || calls != 2 {
+		t.Fatalf("except replayed reply and tool call, actual: %s, calls: %d", reply.Content, calls)
+	}
+
+	// 录制记录已经用完，再次请求没有匹配
+	_, err = client.Run("1+2", nil)
+	if !errors.Is(err, cassette.ErrNoInteraction) {
+		t.Fatalf("except ErrNoInteraction, actual: %v", err)
+	}
+}
+
+func TestReplayAgentAsTool(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "agent_tool.json")
+	srv := lketest.NewServer(
+		lketest.NewTurn(lketest.Interrupt("Agent-A", lketest.ToolCall("call-1", "ask_b", `{"query":"hi"}`))),
+		lketest.NewTurn(lketest.Reply("from b")),
+		lketest.NewTurn(lketest.Reply("done")),
+	)
+	defer srv.Close()
+	run := func(mode cassette.Mode) (*cassette.Recorder, string) {
+		rec, err := cassette.New(path, mode)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var calls int32
+		client := newClient(t, srv.URL, &calls)
+		client.SetHttpClient(rec.Client())
+		client.AddAgents([]model.Agent{
+			model.NewAgent("Agent-B", "agent b", "agent b", model.DefaultModel, nil, nil),
+		})
+		if _, err := client.AddAgentAsTool("Agent-A", "Agent-B", "ask_b", "ask agent b"); err != nil {
+			t.Fatal(err)
+		}
+		reply, err := client.Run("hi", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return rec, reply.Content
+	}
+	rec, _ := run(cassette.ModeRecordNew)
+	if n := len(rec.Cassette().Interactions); n != 3 {
+		t.Fatalf("except nested run recorded, actual interactions: %d", n)
+	}
+	// 回放时 agent 工具的编号不同，嵌套执行的请求也能匹配
+	if _, reply := run(cassette.ModeReplay); reply != "done" || len(srv.Requests()) != 3 {
+		t.Fatalf("except replayed reply done, actual: %s, requests: %d", reply, len(srv.Requests()))
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	a, err := cassette.Normalize([]byte(`{"request_id":"1","content":"hi","bot_app_key":"k","session_id":"s"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := cassette.Normalize([]byte(`{"session_id":"s","content":"hi","request_id":"2"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(a) != string(b) || string(a) != `{"content":"hi","session_id":"s"}` {
+		t.Fatalf("except the same normalized body, actual: %s, %s", a, b)
+	}
+}
+
+func TestNormalizeNestedSession(t *testing.T) {
+	a, err := cassette.Normalize([]byte(`{"session_id":"s_3_0","custom_variables":{"_user_task_id":"s"}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := cassette.Normalize([]byte(`{"session_id":"s_12_5","custom_variables":{"_user_task_id":"s"}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(a) != string(b) || string(a) != `{"custom_variables":{"_user_task_id":"s"},"session_id":"s_*_*"}` {
+		t.Fatalf("except nested session ids normalized, actual: %s, %s", a, b)
+	}
+	// 普通对话 ID 以数字结尾时不替换
+	for _, body := range []string{
+		`{"session_id":"order_12_3"}`,
+		`{"session_id":"order_12_3","custom_variables":{"_user_task_id":"s"}}`,
+	} {
+		c, err := cassette.Normalize([]byte(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(string(c), `"session_id":"order_12_3"`) {
+			t.Fatalf("except session id kept, actual: %s", c)
+		}
+	}
+}
+
+func TestNormalizeAgentTools(t *testing.T) {
+	a, err := cassette.Normalize([]byte(`{"agent_config":{"agent_tools":[{"agent_name":"B"},{"agent_name":"A"}]}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := cassette.Normalize([]byte(`{"agent_config":{"agent_tools":[{"agent_name":"A"},{"agent_name":"B"}]}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(a) != string(b) {
+		t.Fatalf("except agent tools sorted, actual: %s, %s", a, b)
+	}
+}
+
+func TestReplayMultipleAgentTools(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "multi.json")
+	srv := lketest.NewServer(
+		lketest.NewTurn(lketest.Interrupt("Agent-A", lketest.ToolCall("call-1", "add", `{"a":1,"b":2}`))),
+		lketest.NewTurn(lketest.Reply("1+2=3")),
+	)
+	defer srv.Close()
+	type Params struct {
+		Text string `json:"text" doc:"text"`
+	}
+	run := func(mode cassette.Mode) string {
+		rec, err := cassette.New(path, mode)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var calls int32
+		client := newClient(t, srv.URL, &calls)
+		client.SetHttpClient(rec.Client())
+		// 多个 agent 有工具时，请求中 agent_tools 的顺序每次相同
+		for _, name := range []string{"Agent-B", "Agent-C", "Agent-D"} {
+			client.AddAgents([]model.Agent{model.NewAgent(name, name, name, model.DefaultModel, nil, nil)})
+			echo, err := tool.NewFunctionTool("echo_"+name, "echo", func(p Params) string { return p.Text }, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			client.AddFunctionTools(name, []*tool.FunctionTool{echo})
+		}
+		reply, err := client.Run("1+2", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return reply.Content
+	}
+	run(cassette.ModeRecordNew)
+	for i := 0; i < 10; i++ {
+		if reply := run(cassette.ModeReplay); reply != "1+2=3" {
+			t.Fatalf("except replayed reply, actual: %s", reply)
+		}
+	}
+	if n := len(srv.Requests()); n != 2 {
+		t.Fatalf("except replay without requests, actual: %d", n)
+	}
+}
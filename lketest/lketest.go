@@ -0,0 +1,234 @@
+// Package lketest 提供模拟知识引擎 sse 对话接口的测试服务，用于在没有网络的情况下测试 agent 和本地工具
+//
+// 每收到一个请求，Server 按顺序返回一个预先编排的 Turn，并记录收到的 model.ChatRequest：
+//
+//	srv := lketest.NewServer(
+//		lketest.NewTurn(lketest.Interrupt("Agent-A", lketest.ToolCall("call-1", "add", `{"a":1,"b":2}`))),
+//		lketest.NewTurn(lketest.Reply("1+2=3")),
+//	)
+//	defer srv.Close()
+//	client.SetEndpoint(srv.URL)
+package lketest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+
+	"github.com/openai/openai-go"
+	"github.com/tencent-lke/lke-sdk-go/event"
+	"github.com/tencent-lke/lke-sdk-go/model"
+)
+
+// Event 一个 sse 事件
+type Event struct {
+	Data []byte // sse data 字段的内容
+}
+
+// Turn 一次请求对应的响应
+type Turn struct {
+	StatusCode int     // 不为 0 且不为 200 时直接返回该状态码和 Body，不返回事件
+	Body       string  // 非 200 时的响应内容
+	Events     []Event // 按顺序返回的 sse 事件
+}
+
+// NewTurn 创建一次正常返回 sse 事件的响应
+func NewTurn(events ...Event) Turn {
+	return Turn{Events: events}
+}
+
+// StatusTurn 创建一次返回指定 http 状态码的响应，用于测试重试和错误处理
+func StatusTurn(statusCode int, body string) Turn {
+	return Turn{StatusCode: statusCode, Body: body}
+}
+
+// Server 模拟知识引擎 sse 对话接口的测试服务
+type Server struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	turns    []Turn
+	handler  func(req *model.ChatRequest) Turn
+	requests []*model.ChatRequest
+	headers  []http.Header
+}
+
+// NewServer 启动测试服务，turns 为按顺序返回的响应
+func NewServer(turns ...Turn) *Server {
+	s := &Server{turns: turns}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+	return s
+}
+
+// Enqueue 追加按顺序返回的响应
+func (s *Server) Enqueue(turns ...Turn) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.turns = append(s.turns, turns...)
+}
+
+// SetHandler 设置动态生成响应的函数，预先编排的响应用完之后使用
+func (s *Server) SetHandler(handler func(req *model.ChatRequest) Turn) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.handler = handler
+}
+
+// Requests 返回收到的所有请求
+func (s *Server) Requests() []*model.ChatRequest {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]*model.ChatRequest{}, s.requests...)
+}
+
+// Headers 返回收到的所有请求的 http 头，和 Requests 一一对应
+func (s *Server) Headers() []http.Header {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]http.Header{}, s.headers...)
+}
+
+// LastRequest 返回最近一次收到的请求，没有请求时返回 nil
+func (s *Server) LastRequest() *model.ChatRequest {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.requests) == 0 {
+		return nil
+	}
+	return s.requests[len(s.requests)-1]
+}
+
+func (s *Server) nextTurn(req *model.ChatRequest, header http.Header) (Turn, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests = append(s.requests, req)
+	s.headers = append(s.headers, header.Clone())
+	if len(s.turns) > 0 {
+		turn := s.turns[0]
+		s.turns = s.turns[1:]
+		return turn, true
+	}
+	if s.handler != nil {
+		return s.handler(req), true
+	}
+	return Turn{}, false
+}
+
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	req := &model.ChatRequest{}
+	if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+		http.Error(w, fmt.Sprintf("lketest: invalid request body: %v", err), http.StatusBadRequest)
+		return
+	}
+	turn, ok := s.nextTurn(req, r.Header)
+	if !ok {
+		http.Error(w, "lketest: no scripted response", http.StatusInternalServerError)
+		return
+	}
+	if turn.StatusCode != 0 && turn.StatusCode != http.StatusOK {
+		http.Error(w, turn.Body, turn.StatusCode)
+		return
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher, _ := w.(http.Flusher)
+	for _, ev := range turn.Events {
+		if _, err := fmt.Fprintf(w, "data: %s\n\n", ev.Data); err != nil {
+			return
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+	}
+}
+
+// Raw 原样返回 data 的事件
+func Raw(data string) Event {
+	return Event{Data: []byte(data)}
+}
+
+// wrap 把事件消息体包装成 event.EventWrapper
+func wrap(eventType string, payload interface{}) Event {
+	bs, err := json.Marshal(payload)
+	if err != nil {
+		panic(fmt.Sprintf("lketest: marshal %s payload: %v", eventType, err))
+	}
+	data, _ := json.Marshal(event.EventWrapper{
+		Type:    eventType,
+		Payload: bs,
+	})
+	return Event{Data: data}
+}
+
+// Reply 大模型直接回复的最终回复事件
+func Reply(content string) Event {
+	return ReplyEvent(event.ReplyEvent{
+		Content:     content,
+		IsFinal:     true,
+		ReplyMethod: event.ReplyMethodModel,
+	})
+}
+
+// ReplyEvent 自定义的回复事件
+func ReplyEvent(reply event.ReplyEvent) Event {
+	return wrap(event.EventReply, reply)
+}
+
+// Interrupt 需要端上执行本地工具的中断回复事件
+func Interrupt(agentName string, toolCalls ...*openai.ToolCallDeltaUnion) Event {
+	return ReplyEvent(event.ReplyEvent{
+		IsFinal:     true,
+		ReplyMethod: event.ReplyMethodInterrupt,
+		InterruptInfo: &event.InterruptInfo{
+			CurrentAgent: agentName,
+			ToolCalls:    toolCalls,
+		},
+	})
+}
+
+// ToolCall 中断回复中的一次工具调用，arguments 为 json 字符串
+func ToolCall(id, name, arguments string) *openai.ToolCallDeltaUnion {
+	return &openai.ToolCallDeltaUnion{
+		ID:   id,
+		Type: "function",
+		Function: openai.FunctionToolCallDeltaFunction{
+			Name:      name,
+			Arguments: arguments,
+		},
+	}
+}
+
+// Thought 思考事件
+func Thought(thought event.AgentThoughtEvent) Event {
+	return wrap(event.EventThought, thought)
+}
+
+// Reference 参考来源事件
+func Reference(refer event.ReferenceEvent) Event {
+	return wrap(event.EventReference, refer)
+}
+
+// TokenStat token 统计事件
+func TokenStat(stat event.TokenStatEvent) Event {
+	return wrap(event.EventTokenStat, stat)
+}
+
+// Error 错误事件，错误事件的字段不在 payload 中
+func Error(code int, message string) Event {
+	data, err := json.Marshal(struct {
+		Type string `json:"type"`
+		event.ErrorEvent
+	}{
+		Type: event.EventError,
+		ErrorEvent: event.ErrorEvent{
+			Error: event.Error{Code: code, Message: message},
+		},
+	})
+	if err != nil {
+		panic(fmt.Sprintf("lketest: marshal error event: %v", err))
+	}
+	return Event{Data: data}
+}
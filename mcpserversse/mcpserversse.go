@@ -1,11 +1,11 @@
-// Package event contains event definitions.
+// Package mcpserversse 连接 mcp server，支持 SSE、streamable HTTP、stdio 命令和同一个进程中的 mcp-go server
 package mcpserversse
 
 import (
 	"context"
-	"fmt"
 	"net/http"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/mark3labs/mcp-go/client"
@@ -20,6 +20,10 @@ type McpServerSse struct {
 	InitRequest          mcp.InitializeRequest
 	ClientSessionTimeout int64
 	Cli                  *client.Client
+	// mu 保护 Cli 和 handlers，重连和注册回调可以和工具调用并发
+	mu sync.RWMutex
+	// handlers mcp server 通知的回调，重连后重新注册
+	handlers []func(notification mcp.JSONRPCNotification)
 }
 
 func NewMcpServerSse(sseurl string, options []transport.ClientOption, initrequest mcp.InitializeRequest, clientsessiontimeout int64) *McpServerSse {
@@ -37,42 +41,43 @@ func isHTTPURL(url string) bool {
 	return strings.HasPrefix(url, "http://") || strings.HasPrefix(url, "https://")
 }
 
-func (sse *McpServerSse) initlocal() error {
-	mcpClient, err := client.NewStdioMCPClient(
-		"python3",
-		[]string{}, // Empty ENV
-		sse.SseUrl,
-	)
-	sse.Cli = mcpClient
-	return err
-}
-
 func (sse *McpServerSse) init() error {
-	if !isHTTPURL(sse.SseUrl) {
-		return sse.initlocal()
-	}
-	options := sse.Options
-	if sse.ClientSessionTimeout > 0 {
-		httpClient := &http.Client{
-			Timeout: time.Duration(sse.ClientSessionTimeout) * time.Second,
+	// 不是 http(s) 地址时作为 python 脚本通过 stdio 启动，其他命令使用 StdioTransport
+	t := StdioTransport(StdioCommand{Command: "python3", Args: []string{sse.SseUrl}})
+	if isHTTPURL(sse.SseUrl) {
+		options := sse.Options
+		if sse.ClientSessionTimeout > 0 {
+			httpClient := &http.Client{
+				Timeout: time.Duration(sse.ClientSessionTimeout) * time.Second,
+			}
+			options = append(options, transport.WithHTTPClient(httpClient))
 		}
-		options = append(options, transport.WithHTTPClient(httpClient))
+		t = SSETransport(sse.SseUrl, options...)
 	}
-	mcpClient, err := client.NewSSEMCPClient(sse.SseUrl, options...)
+	mcpClient, err := connect(t, sse.InitRequest)
 	if err != nil {
 		return err
 	}
-	if err := mcpClient.Start(context.Background()); err != nil {
-		return err
-	}
-	_, err = mcpClient.Initialize(context.Background(), sse.InitRequest)
-	if err != nil {
-		return fmt.Errorf("failed to initialize: %v, %v", err, sse.InitRequest)
+	sse.mu.Lock()
+	old := sse.Cli
+	for _, h := range sse.handlers {
+		mcpClient.OnNotification(h)
 	}
 	sse.Cli = mcpClient
+	sse.mu.Unlock()
+	if old != nil {
+		_ = old.Close()
+	}
 	return nil
 }
 
+// current 当前的连接
+func (sse *McpServerSse) current() *client.Client {
+	sse.mu.RLock()
+	defer sse.mu.RUnlock()
+	return sse.Cli
+}
+
 func (sse *McpServerSse) Init() error {
 	return sse.init()
 }
@@ -82,13 +87,33 @@ func (sse *McpServerSse) ReConnect() error {
 }
 
 func (sse *McpServerSse) Ping(ctx context.Context) error {
-	return sse.Cli.Ping(ctx)
+	return sse.current().Ping(ctx)
 }
 
 func (sse *McpServerSse) ListTools(ctx context.Context, request mcp.ListToolsRequest) (*mcp.ListToolsResult, error) {
-	return sse.Cli.ListTools(ctx, request)
+	return sse.current().ListTools(ctx, request)
 }
 
 func (sse *McpServerSse) CallTool(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
-	return sse.Cli.CallTool(ctx, request)
+	return sse.current().CallTool(ctx, request)
+}
+
+// OnNotification 注册 mcp server 通知的回调，例如 notifications/tools/list_changed，重连后仍然有效
+// 回调在接收消息的协程中执行，不能阻塞
+func (sse *McpServerSse) OnNotification(handler func(notification mcp.JSONRPCNotification)) {
+	sse.mu.Lock()
+	defer sse.mu.Unlock()
+	sse.handlers = append(sse.handlers, handler)
+	if sse.Cli != nil {
+		sse.Cli.OnNotification(handler)
+	}
+}
+
+// Close 关闭连接，通过 stdio 启动的脚本在关闭后等待退出，超时后结束进程
+func (sse *McpServerSse) Close() error {
+	cli := sse.current()
+	if cli == nil {
+		return nil
+	}
+	return cli.Close()
 }
@@ -0,0 +1,261 @@
+package mcpserversse
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"sync"
+	"time"
+
+	"github.com/mark3labs/mcp-go/client"
+	"github.com/mark3labs/mcp-go/client/transport"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// McpServer mcp server 的连接，LkeClient.AddMcpTools 通过它获取和调用工具
+// McpServerSse 和 NewMcpServer 创建的连接都实现了该接口
+type McpServer interface {
+	Ping(ctx context.Context) error
+	// ReConnect 断开后重新连接并初始化
+	ReConnect() error
+	ListTools(ctx context.Context, request mcp.ListToolsRequest) (*mcp.ListToolsResult, error)
+	CallTool(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error)
+	Close() error
+}
+
+// NotifiableMcpServer 可以接收 mcp server 通知的连接，例如 notifications/tools/list_changed
+// 重连后之前注册的回调仍然有效
+type NotifiableMcpServer interface {
+	McpServer
+	OnNotification(handler func(notification mcp.JSONRPCNotification))
+}
+
+// Transport 创建到 mcp server 的 transport，每次连接（包括重连）都会调用一次
+type Transport func() (transport.Interface, error)
+
+// SSETransport 通过 SSE 连接 mcp server
+func SSETransport(url string, options ...transport.ClientOption) Transport {
+	return func() (transport.Interface, error) {
+		return transport.NewSSE(url, options...)
+	}
+}
+
+// StreamableHTTPTransport 通过 streamable HTTP 连接 mcp server
+func StreamableHTTPTransport(url string, options ...transport.StreamableHTTPCOption) Transport {
+	return func() (transport.Interface, error) {
+		return transport.NewStreamableHTTP(url, options...)
+	}
+}
+
+// StdioCommand 通过 stdio 通信的 mcp server 命令
+type StdioCommand struct {
+	Command string
+	Args    []string
+	Env     []string // 追加到当前进程的环境变量之后，格式为 KEY=VALUE
+	Dir     string   // 工作目录，为空时使用当前目录
+	// CloseTimeout 关闭连接后等待命令退出的时间，为 0 时使用 defaultStdioCloseTimeout，超时后结束进程
+	CloseTimeout time.Duration
+}
+
+// defaultStdioCloseTimeout 关闭 stdio 连接后默认等待命令退出的时间
+const defaultStdioCloseTimeout = 5 * time.Second
+
+// StdioTransport 启动命令，通过标准输入输出连接 mcp server，关闭连接时等待命令退出，超时后结束进程
+func StdioTransport(cmd StdioCommand) Transport {
+	return func() (transport.Interface, error) {
+		if cmd.Command == "" {
+			return nil, fmt.Errorf("stdio mcp server requires a command")
+		}
+		return &stdioTransport{cmd: cmd}, nil
+	}
+}
+
+// InProcessTransport 直接连接同一个进程中的 mcp-go server，一般用于测试
+func InProcessTransport(s *server.MCPServer) Transport {
+	return func() (transport.Interface, error) {
+		return transport.NewInProcessTransport(s), nil
+	}
+}
+
+// stdioTransport 支持工作目录的 stdio transport
+type stdioTransport struct {
+	*transport.Stdio
+	cmd  StdioCommand
+	proc *exec.Cmd
+
+	closeOnce sync.Once
+	closeErr  error // 第一次 Close 的结果，重复 Close 时返回
+}
+
+// Start 启动命令，命令的生命周期和连接相同，不受 ctx 影响
+func (t *stdioTransport) Start(ctx context.Context) error {
+	proc := exec.Command(t.cmd.Command, t.cmd.Args...)
+	proc.Env = append(os.Environ(), t.cmd.Env...)
+	proc.Dir = t.cmd.Dir
+	stdin, err := proc.StdinPipe()
+	if err != nil {
+		return fmt.Errorf("failed to create stdin pipe: %w", err)
+	}
+	stdout, err := proc.StdoutPipe()
+	if err != nil {
+		return fmt.Errorf("failed to create stdout pipe: %w", err)
+	}
+	stderr, err := proc.StderrPipe()
+	if err != nil {
+		return fmt.Errorf("failed to create stderr pipe: %w", err)
+	}
+	if err := proc.Start(); err != nil {
+		return fmt.Errorf("failed to start command %s: %w", t.cmd.Command, err)
+	}
+	t.proc = proc
+	t.Stdio = transport.NewIO(stdout, stdin, stderr)
+	return t.Stdio.Start(ctx)
+}
+
+// Close 关闭标准输入并等待命令退出，命令没有在 CloseTimeout 内退出时结束进程
+// 可以重复调用，只有第一次关闭连接，之后返回第一次的结果
+func (t *stdioTransport) Close() error {
+	if t.Stdio == nil {
+		return nil
+	}
+	t.closeOnce.Do(func() {
+		t.closeErr = t.close()
+	})
+	return t.closeErr
+}
+
+// close 关闭连接并等待命令退出
+func (t *stdioTransport) close() error {
+	err := t.Stdio.Close()
+	done := make(chan error, 1)
+	go func() {
+		done <- t.proc.Wait()
+	}()
+	timeout := t.cmd.CloseTimeout
+	if timeout <= 0 {
+		timeout = defaultStdioCloseTimeout
+	}
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case werr := <-done:
+		if err == nil {
+			err = werr
+		}
+	case <-timer.C:
+		_ = t.proc.Process.Kill()
+		<-done
+		if err == nil {
+			err = fmt.Errorf("command %s did not exit within %v after closing, killed", t.cmd.Command, timeout)
+		}
+	}
+	return err
+}
+
+// McpServerClient 通过 Transport 连接的 mcp server
+type McpServerClient struct {
+	transport   Transport
+	initRequest mcp.InitializeRequest
+	mu          sync.RWMutex
+	cli         *client.Client
+	handlers    []func(notification mcp.JSONRPCNotification)
+}
+
+// NewMcpServer 通过 transport 连接 mcp server 并完成初始化
+// initRequest 中没有设置协议版本和客户端信息时使用默认值
+func NewMcpServer(t Transport, initRequest mcp.InitializeRequest) (*McpServerClient, error) {
+	s := &McpServerClient{transport: t, initRequest: initRequest}
+	cli, err := connect(t, initRequest)
+	if err != nil {
+		return nil, err
+	}
+	s.cli = cli
+	return s, nil
+}
+
+// connect 创建 transport，启动并初始化 client
+func connect(t Transport, initRequest mcp.InitializeRequest) (*client.Client, error) {
+	tr, err := t()
+	if err != nil {
+		return nil, err
+	}
+	cli := client.NewClient(tr)
+	// 连接的生命周期和 client 相同，不能使用带超时的 ctx
+	if err := cli.Start(context.Background()); err != nil {
+		_ = cli.Close()
+		return nil, fmt.Errorf("failed to start mcp client: %w", err)
+	}
+	if initRequest.Params.ProtocolVersion == "" {
+		initRequest.Params.ProtocolVersion = mcp.LATEST_PROTOCOL_VERSION
+	}
+	if initRequest.Params.ClientInfo.Name == "" {
+		initRequest.Params.ClientInfo = mcp.Implementation{Name: "lke-sdk-go", Version: "1.0.0"}
+	}
+	if _, err := cli.Initialize(context.Background(), initRequest); err != nil {
+		_ = cli.Close()
+		return nil, fmt.Errorf("failed to initialize: %v, %v", err, initRequest)
+	}
+	return cli, nil
+}
+
+// current 当前的连接
+func (s *McpServerClient) current() *client.Client {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.cli
+}
+
+// Client 获取底层的 mcp-go client
+func (s *McpServerClient) Client() *client.Client {
+	return s.current()
+}
+
+// ReConnect 关闭当前连接，重新创建 transport 连接
+func (s *McpServerClient) ReConnect() error {
+	cli, err := connect(s.transport, s.initRequest)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	old := s.cli
+	s.cli = cli
+	for _, h := range s.handlers {
+		cli.OnNotification(h)
+	}
+	s.mu.Unlock()
+	if old != nil {
+		_ = old.Close()
+	}
+	return nil
+}
+
+// OnNotification 注册 mcp server 通知的回调，回调在接收消息的协程中执行，不能阻塞
+func (s *McpServerClient) OnNotification(handler func(notification mcp.JSONRPCNotification)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.handlers = append(s.handlers, handler)
+	s.cli.OnNotification(handler)
+}
+
+// Ping 检查连接
+func (s *McpServerClient) Ping(ctx context.Context) error {
+	return s.current().Ping(ctx)
+}
+
+// ListTools 获取工具列表
+func (s *McpServerClient) ListTools(ctx context.Context,
+	request mcp.ListToolsRequest) (*mcp.ListToolsResult, error) {
+	return s.current().ListTools(ctx, request)
+}
+
+// CallTool 调用工具
+func (s *McpServerClient) CallTool(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	return s.current().CallTool(ctx, request)
+}
+
+// Close 关闭连接
+func (s *McpServerClient) Close() error {
+	return s.current().Close()
+}
@@ -0,0 +1,232 @@
+package mcpserversse_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/tencent-lke/lke-sdk-go/mcpserversse"
+)
+
+// stdioServerEnv 设置时测试进程作为 stdio mcp server 运行
+const stdioServerEnv = "LKE_TEST_MCP_STDIO_SERVER"
+
+// stdioHangEnv 设置时 stdio mcp server 在标准输入关闭后不退出
+const stdioHangEnv = "LKE_TEST_MCP_STDIO_HANG"
+
+func TestMain(m *testing.M) {
+	if os.Getenv(stdioServerEnv) != "" {
+		err := server.ServeStdio(newTestServer())
+		if os.Getenv(stdioHangEnv) != "" {
+			select {}
+		}
+		if err != nil {
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+// newTestServer 返回 echo 和 cwd 两个工具的 mcp server
+func newTestServer() *server.MCPServer {
+	s := server.NewMCPServer("test-server", "1.0.0")
+	s.AddTool(mcp.NewTool("echo", mcp.WithDescription("echo the text"),
+		mcp.WithString("text", mcp.Required())),
+		func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+			args, ok := request.Params.Arguments.(map[string]interface{})
+			if !ok {
+				return nil, errors.New("arguments must be map[string]interface{}")
+			}
+			text, _ := args["text"].(string)
+			return mcp.NewToolResultText(text + os.Getenv("ECHO_SUFFIX")), nil
+		})
+	s.AddTool(mcp.NewTool("cwd", mcp.WithDescription("current working directory")),
+		func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+			dir, err := os.Getwd()
+			if err != nil {
+				return nil, err
+			}
+			return mcp.NewToolResultText(dir), nil
+		})
+	return s
+}
+
+// callText 调用工具，返回文本输出
+func callText(t *testing.T, s mcpserversse.McpServer, name string, args map[string]interface{}) string {
+	t.Helper()
+	req := mcp.CallToolRequest{}
+	req.Params.Name = name
+	req.Params.Arguments = args
+	res, err := s.CallTool(context.Background(), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Content) != 1 {
+		t.Fatalf("unexpected result: %+v", res)
+	}
+	text, ok := res.Content[0].(mcp.TextContent)
+	if !ok {
+		t.Fatalf("unexpected content: %+v", res.Content[0])
+	}
+	return text.Text
+}
+
+func TestTransports(t *testing.T) {
+	sseServer := server.NewTestServer(newTestServer())
+	defer sseServer.Close()
+	httpServer := server.NewTestStreamableHTTPServer(newTestServer())
+	defer httpServer.Close()
+	transports := map[string]mcpserversse.Transport{
+		"in_process":      mcpserversse.InProcessTransport(newTestServer()),
+		"sse":             mcpserversse.SSETransport(sseServer.URL + "/sse"),
+		"streamable_http": mcpserversse.StreamableHTTPTransport(httpServer.URL + "/mcp"),
+	}
+	for name, transport := range transports {
+		t.Run(name, func(t *testing.T) {
+			s, err := mcpserversse.NewMcpServer(transport, mcp.InitializeRequest{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer s.Close()
+			tools, err := s.ListTools(context.Background(), mcp.ListToolsRequest{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(tools.Tools) != 2 {
+				t.Fatalf("unexpected tools: %+v", tools.Tools)
+			}
+			if out := callText(t, s, "echo", map[string]interface{}{"text": "hi"}); out != "hi" {
+				t.Fatalf("unexpected output: %s", out)
+			}
+			// 重连后可以继续调用
+			if err := s.ReConnect(); err != nil {
+				t.Fatal(err)
+			}
+			if err := s.Ping(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+			if out := callText(t, s, "echo", map[string]interface{}{"text": "again"}); out != "again" {
+				t.Fatalf("unexpected output: %s", out)
+			}
+		})
+	}
+}
+
+func TestStdioTransport(t *testing.T) {
+	exe, err := os.Executable()
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	s, err := mcpserversse.NewMcpServer(mcpserversse.StdioTransport(mcpserversse.StdioCommand{
+		Command: exe,
+		Args:    []string{"-test.run=^$"},
+		Env:     []string{stdioServerEnv + "=1", "ECHO_SUFFIX=!"},
+		Dir:     dir,
+	}), mcp.InitializeRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out := callText(t, s, "echo", map[string]interface{}{"text": "hi"}); out != "hi!" {
+		t.Fatalf("unexpected output: %s", out)
+	}
+	cwd := callText(t, s, "cwd", nil)
+	if want, _ := filepath.EvalSymlinks(dir); cwd != want && cwd != dir {
+		t.Fatalf("except cwd %s, actual: %s", dir, cwd)
+	}
+	if err := s.ReConnect(); err != nil {
+		t.Fatal(err)
+	}
+	if out := callText(t, s, "echo", map[string]interface{}{"text": "again"}); out != "again!" {
+		t.Fatalf("unexpected output: %s", out)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+	// 重复关闭不会再次等待命令退出
+	if err := s.Close(); err != nil {
+		t.Fatalf("except close again returns the first result, actual: %v", err)
+	}
+}
+
+func TestStdioTransportCloseTimeout(t *testing.T) {
+	exe, err := os.Executable()
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := mcpserversse.NewMcpServer(mcpserversse.StdioTransport(mcpserversse.StdioCommand{
+		Command:      exe,
+		Args:         []string{"-test.run=^$"},
+		Env:          []string{stdioServerEnv + "=1", stdioHangEnv + "=1"},
+		CloseTimeout: 100 * time.Millisecond,
+	}), mcp.InitializeRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out := callText(t, s, "echo", map[string]interface{}{"text": "hi"}); out != "hi" {
+		t.Fatalf("unexpected output: %s", out)
+	}
+	// 命令在标准输入关闭后不退出时，超时后结束进程
+	begin := time.Now()
+	if err := s.Close(); err == nil || !strings.Contains(err.Error(), "killed") {
+		t.Fatalf("except killed after timeout, actual: %v", err)
+	}
+	if elapsed := time.Since(begin); elapsed > 5*time.Second {
+		t.Fatalf("except close returns after timeout, actual: %v", elapsed)
+	}
+	// 重复关闭返回第一次的结果，不再等待命令退出
+	begin = time.Now()
+	if err := s.Close(); err == nil || !strings.Contains(err.Error(), "killed") {
+		t.Fatalf("except the first close result, actual: %v", err)
+	}
+	if elapsed := time.Since(begin); elapsed > 100*time.Millisecond {
+		t.Fatalf("except close again returns immediately, actual: %v", elapsed)
+	}
+}
+
+func TestMcpServerSseConcurrentReConnect(t *testing.T) {
+	sseServer := server.NewTestServer(newTestServer())
+	defer sseServer.Close()
+	s := mcpserversse.NewMcpServerSse(sseServer.URL+"/sse", nil, mcp.InitializeRequest{}, 0)
+	defer s.Close()
+	// 重连、注册回调和工具调用可以并发
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(3)
+		go func() {
+			defer wg.Done()
+			if err := s.ReConnect(); err != nil {
+				t.Error(err)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			s.OnNotification(func(notification mcp.JSONRPCNotification) {})
+		}()
+		go func() {
+			defer wg.Done()
+			if _, err := s.ListTools(context.Background(), mcp.ListToolsRequest{}); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	if out := callText(t, s, "echo", map[string]interface{}{"text": "hi"}); out != "hi" {
+		t.Fatalf("unexpected output: %s", out)
+	}
+}
+
+func TestStdioTransportNoCommand(t *testing.T) {
+	if _, err := mcpserversse.NewMcpServer(mcpserversse.StdioTransport(mcpserversse.StdioCommand{}),
+		mcp.InitializeRequest{}); err == nil {
+		t.Fatal("expect error for empty command")
+	}
+}
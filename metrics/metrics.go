@@ -0,0 +1,132 @@
+// Package metrics sdk 的运行指标
+//
+// Recorder 是指标记录接口，默认不记录，prommetrics 包提供 Prometheus 的实现。
+// 执行时 Recorder 会放到 context 中，本地工具、mcp 工具和嵌套执行的 agent 工具都使用同一个 Recorder。
+package metrics
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/tencent-lke/lke-sdk-go/lkeerrors"
+)
+
+// 执行结果
+const (
+	OutcomeSucceeded = "succeeded" // 执行成功
+	OutcomeFailed    = "failed"    // 执行失败
+	OutcomeCanceled  = "canceled"  // 执行被取消
+	OutcomePaused    = "paused"    // 执行暂停，等待 Resume
+)
+
+// 工具调用结果
+const (
+	ToolOK       = "ok"       // 执行成功
+	ToolError    = "error"    // 返回错误
+	ToolTimeout  = "timeout"  // 执行超时
+	ToolPanic    = "panic"    // 执行 panic
+	ToolRejected = "rejected" // 审批拒绝，没有执行
+	// ToolInvalidArgs 参数校验失败，没有执行
+	ToolInvalidArgs = "invalid_args"
+)
+
+// Recorder 指标记录接口，实现需要并发安全
+type Recorder interface {
+	// RunStarted 开始一次执行，agent 为入口 agent
+	RunStarted(agent string)
+
+	// RunFinished 结束一次执行，outcome 为 Outcome* 常量，errClass 为 ErrorClass 的返回，toolTurns 为本地工具调用的轮数
+	RunFinished(agent, outcome, errClass string, toolTurns int, elapsed time.Duration)
+
+	// ToolCall 一次本地工具调用，outcome 为 Tool* 常量
+	ToolCall(tool, agent, outcome string, elapsed time.Duration)
+
+	// McpReconnect mcp 服务 ping 失败后重连，err 为重连的错误
+	McpReconnect(tool string, err error)
+
+	// SSEEvent 收到一个 sse 事件，eventType 为事件类型
+	SSEEvent(eventType string)
+
+	// FirstReply 从开始执行到收到第一个回复事件的时间
+	FirstReply(agent string, elapsed time.Duration)
+
+	// Tokens 消耗的 token 数
+	Tokens(agent, model string, tokens uint64)
+}
+
+// Nop 不记录任何指标的 Recorder
+type Nop struct{}
+
+// RunStarted 实现 Recorder
+func (Nop) RunStarted(agent string) {}
+
+// RunFinished 实现 Recorder
+func (Nop) RunFinished(agent, outcome, errClass string, toolTurns int, elapsed time.Duration) {}
+
+// ToolCall 实现 Recorder
+func (Nop) ToolCall(tool, agent, outcome string, elapsed time.Duration) {}
+
+// McpReconnect 实现 Recorder
+func (Nop) McpReconnect(tool string, err error) {}
+
+// SSEEvent 实现 Recorder
+func (Nop) SSEEvent(eventType string) {}
+
+// FirstReply 实现 Recorder
+func (Nop) FirstReply(agent string, elapsed time.Duration) {}
+
+// Tokens 实现 Recorder
+func (Nop) Tokens(agent, model string, tokens uint64) {}
+
+// recorderKey context 中保存 Recorder 的 key
+type recorderKey struct{}
+
+// WithRecorder 把 Recorder 放到 context 中
+func WithRecorder(ctx context.Context, r Recorder) context.Context {
+	return context.WithValue(ctx, recorderKey{}, r)
+}
+
+// FromContext 获取 context 中的 Recorder，没有时返回 Nop
+func FromContext(ctx context.Context) Recorder {
+	if r, ok := ctx.Value(recorderKey{}).(Recorder); ok && r != nil {
+		return r
+	}
+	return Nop{}
+}
+
+// ErrorClass 错误分类，用作指标的标签，err 为空时返回空字符串
+func ErrorClass(err error) string {
+	var apiErr *lkeerrors.APIError
+	switch {
+	case err == nil:
+		return ""
+	case errors.Is(err, context.Canceled):
+		return "canceled"
+	case errors.Is(err, context.DeadlineExceeded):
+		return "deadline_exceeded"
+	case errors.Is(err, lkeerrors.ErrClientClosed):
+		return "client_closed"
+	case errors.Is(err, lkeerrors.ErrRunPaused):
+		return "paused"
+	case errors.Is(err, lkeerrors.ErrBudgetExceeded):
+		return "budget_exceeded"
+	case errors.Is(err, lkeerrors.ErrMaxToolTurns):
+		return "max_tool_turns"
+	case errors.Is(err, lkeerrors.ErrNoFinalReply):
+		return "no_final_reply"
+	case errors.Is(err, lkeerrors.ErrInvalidConfig):
+		return "invalid_config"
+	case errors.Is(err, lkeerrors.ErrUnauthorized):
+		return "unauthorized"
+	case errors.Is(err, lkeerrors.ErrRateLimited):
+		return "rate_limited"
+	case errors.Is(err, lkeerrors.ErrInvalidParam):
+		return "invalid_param"
+	case errors.Is(err, lkeerrors.ErrServerUnavail):
+		return "server_unavailable"
+	case errors.As(err, &apiErr):
+		return "api_error"
+	}
+	return "other"
+}
@@ -0,0 +1,143 @@
+// Package prommetrics metrics.Recorder 的 Prometheus 实现
+//
+//	recorder, err := prommetrics.New(prometheus.DefaultRegisterer, prommetrics.Options{})
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	client.SetMetricsRecorder(recorder)
+package prommetrics
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/tencent-lke/lke-sdk-go/metrics"
+)
+
+// Options 指标配置
+type Options struct {
+	Namespace         string            // 指标名前缀，为空时使用 lke
+	ConstLabels       prometheus.Labels // 所有指标都带上的固定标签
+	ToolBuckets       []float64         // 工具耗时的 bucket，单位秒，为空时使用 prometheus.DefBuckets
+	FirstReplyBuckets []float64         // 首个回复耗时的 bucket，单位秒，为空时使用 prometheus.DefBuckets
+	RunBuckets        []float64         // 执行耗时的 bucket，单位秒，为空时使用 1s 到 512s 的指数 bucket
+	ToolTurnBuckets   []float64         // 每次执行本地工具调用轮数的 bucket，为空时使用 0 到 10
+}
+
+// Recorder metrics.Recorder 的 Prometheus 实现
+type Recorder struct {
+	runsStarted  *prometheus.CounterVec
+	runsFinished *prometheus.CounterVec
+	runDuration  *prometheus.HistogramVec
+	toolTurns    *prometheus.HistogramVec
+	toolDuration *prometheus.HistogramVec
+	toolTimeouts *prometheus.CounterVec
+	toolPanics   *prometheus.CounterVec
+	mcpReconnect *prometheus.CounterVec
+	sseEvents    *prometheus.CounterVec
+	firstReply   *prometheus.HistogramVec
+	tokens       *prometheus.CounterVec
+}
+
+var _ metrics.Recorder = (*Recorder)(nil)
+
+// New 创建 Recorder 并注册到 reg
+func New(reg prometheus.Registerer, opts Options) (*Recorder, error) {
+	ns := opts.Namespace
+	if ns == "" {
+		ns = "lke"
+	}
+	buckets := func(b, def []float64) []float64 {
+		if len(b) == 0 {
+			return def
+		}
+		return b
+	}
+	counter := func(name, help string, labels ...string) *prometheus.CounterVec {
+		return prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: ns, Name: name, Help: help, ConstLabels: opts.ConstLabels,
+		}, labels)
+	}
+	histogram := func(name, help string, b []float64, labels ...string) *prometheus.HistogramVec {
+		return prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: ns, Name: name, Help: help, ConstLabels: opts.ConstLabels, Buckets: b,
+		}, labels)
+	}
+	r := &Recorder{
+		runsStarted: counter("runs_started_total", "Number of agent runs started.", "agent"),
+		runsFinished: counter("runs_finished_total", "Number of agent runs finished by outcome and error class.",
+			"agent", "outcome", "error_class"),
+		runDuration: histogram("run_duration_seconds", "Duration of agent runs.",
+			buckets(opts.RunBuckets, prometheus.ExponentialBuckets(1, 2, 10)), "agent", "outcome"),
+		toolTurns: histogram("run_tool_turns", "Number of local tool turns per run.",
+			buckets(opts.ToolTurnBuckets, prometheus.LinearBuckets(0, 1, 11)), "agent"),
+		toolDuration: histogram("tool_duration_seconds", "Duration of local tool calls.",
+			buckets(opts.ToolBuckets, prometheus.DefBuckets), "tool", "agent", "outcome"),
+		toolTimeouts: counter("tool_timeouts_total", "Number of local tool calls that timed out.", "tool", "agent"),
+		toolPanics:   counter("tool_panics_total", "Number of local tool calls that panicked.", "tool", "agent"),
+		mcpReconnect: counter("mcp_reconnects_total", "Number of MCP server reconnects by result.", "tool", "result"),
+		sseEvents:    counter("sse_events_total", "Number of SSE events received by type.", "type"),
+		firstReply: histogram("first_reply_seconds", "Time from run start to the first reply event.",
+			buckets(opts.FirstReplyBuckets, prometheus.DefBuckets), "agent"),
+		tokens: counter("tokens_total", "Number of tokens consumed.", "agent", "model"),
+	}
+	for _, c := range []prometheus.Collector{
+		r.runsStarted, r.runsFinished, r.runDuration, r.toolTurns, r.toolDuration, r.toolTimeouts,
+		r.toolPanics, r.mcpReconnect, r.sseEvents, r.firstReply, r.tokens,
+	} {
+		if err := reg.Register(c); err != nil {
+			return nil, err
+		}
+	}
+	return r, nil
+}
+
+// RunStarted 实现 metrics.Recorder
+func (r *Recorder) RunStarted(agent string) {
+	r.runsStarted.WithLabelValues(agent).Inc()
+}
+
+// RunFinished 实现 metrics.Recorder
+func (r *Recorder) RunFinished(agent, outcome, errClass string, toolTurns int, elapsed time.Duration) {
+	r.runsFinished.WithLabelValues(agent, outcome, errClass).Inc()
+	r.runDuration.WithLabelValues(agent, outcome).Observe(elapsed.Seconds())
+	r.toolTurns.WithLabelValues(agent).Observe(float64(toolTurns))
+}
+
+// ToolCall 实现 metrics.Recorder
+func (r *Recorder) ToolCall(tool, agent, outcome string, elapsed time.Duration) {
+	r.toolDuration.WithLabelValues(tool, agent, outcome).Observe(elapsed.Seconds())
+	switch outcome {
+	case metrics.ToolTimeout:
+		r.toolTimeouts.WithLabelValues(tool, agent).Inc()
+	case metrics.ToolPanic:
+		r.toolPanics.WithLabelValues(tool, agent).Inc()
+	}
+}
+
+// McpReconnect 实现 metrics.Recorder
+func (r *Recorder) McpReconnect(tool string, err error) {
+	result := "ok"
+	if err != nil {
+		result = "error"
+	}
+	r.mcpReconnect.WithLabelValues(tool, result).Inc()
+}
+
+// SSEEvent 实现 metrics.Recorder
+func (r *Recorder) SSEEvent(eventType string) {
+	if eventType == "" {
+		eventType = "unknown"
+	}
+	r.sseEvents.WithLabelValues(eventType).Inc()
+}
+
+// FirstReply 实现 metrics.Recorder
+func (r *Recorder) FirstReply(agent string, elapsed time.Duration) {
+	r.firstReply.WithLabelValues(agent).Observe(elapsed.Seconds())
+}
+
+// Tokens 实现 metrics.Recorder
+func (r *Recorder) Tokens(agent, model string, tokens uint64) {
+	r.tokens.WithLabelValues(agent, model).Add(float64(tokens))
+}
@@ -0,0 +1,8 @@
+package model
+
+// Budget token 预算，为 0 的字段表示不限制
+type Budget struct {
+	MaxTotalTokens   uint32            // 单次执行最多消耗的 token 数，包括所有本地工具调用的轮次
+	MaxModelTokens   map[string]uint32 // 单次执行每个模型最多消耗的 token 数，key 为模型名
+	MaxSessionTokens uint32            // 一个对话累计最多消耗的 token 数
+}
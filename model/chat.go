@@ -23,7 +23,14 @@ type Options struct {
 	ToolOuputs  []ToolOuput `json:"tool_ouputs"`  // 端上调用工具的输出提交到云上
 	AgentConfig AgentConfig `json:"agent_config"` // agent配置
 	
-	EnvSet string `json:"-"` // 泳道环境设置
+	EnvSet string  `json:"-"` // 泳道环境设置
+	Budget *Budget `json:"-"` // 本次执行的 token 预算，不为空时覆盖 client 上设置的预算
+	// SuspendOnInterrupt 需要执行本地工具时暂停执行，返回 lkeerrors.ErrRunPaused 和 RunResult.Pending，
+	// 工具输出可以在其他进程中通过 Resume 提交
+	SuspendOnInterrupt bool `json:"-"`
+	// OutputRepairs lkesdk.RunTyped 的回复不符合输出 schema 时，带上错误信息重新提示模型的最大次数，
+	// 0 使用默认值 2，小于 0 不重新提示
+	OutputRepairs int `json:"-"`
 }
 
 // VisitorLabel 定义了知识标签的结构
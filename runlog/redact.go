@@ -0,0 +1,101 @@
+package runlog
+
+import (
+	"encoding/json"
+	"log/slog"
+)
+
+// Redacted 脱敏后的值
+const Redacted = "******"
+
+// DefaultRedactKeys 默认脱敏的字段
+var DefaultRedactKeys = []string{"bot_app_key"}
+
+// Redactor 日志脱敏，字段名匹配的值替换成 Redacted，对 json 中任意层级的字段生效
+// 例如配置 CustomVariables 的 key 后，请求中 custom_variables 和工具入参中对应的值都会脱敏
+type Redactor struct {
+	keys map[string]struct{}
+}
+
+// NewRedactor 创建 Redactor，除了 keys 也会脱敏 DefaultRedactKeys
+func NewRedactor(keys ...string) *Redactor {
+	r := &Redactor{keys: map[string]struct{}{}}
+	for _, k := range DefaultRedactKeys {
+		r.keys[k] = struct{}{}
+	}
+	for _, k := range keys {
+		r.keys[k] = struct{}{}
+	}
+	return r
+}
+
+// match 字段是否需要脱敏，r 为空时使用 DefaultRedactKeys
+func (r *Redactor) match(key string) bool {
+	if r == nil {
+		for _, k := range DefaultRedactKeys {
+			if k == key {
+				return true
+			}
+		}
+		return false
+	}
+	_, ok := r.keys[key]
+	return ok
+}
+
+// JSON 把 v 序列化成 json 并脱敏
+func (r *Redactor) JSON(v interface{}) string {
+	bs, err := json.Marshal(v)
+	if err != nil {
+		return err.Error()
+	}
+	var data interface{}
+	if err := json.Unmarshal(bs, &data); err != nil {
+		return string(bs)
+	}
+	bs, _ = json.Marshal(r.value(data))
+	return string(bs)
+}
+
+// Text 脱敏文本，文本是 json 时按字段脱敏，否则原样返回，用于工具输出等不确定格式的内容
+func (r *Redactor) Text(s string) string {
+	if !json.Valid([]byte(s)) {
+		return s
+	}
+	return r.JSON(json.RawMessage(s))
+}
+
+// value 递归脱敏 json 反序列化得到的值
+func (r *Redactor) value(v interface{}) interface{} {
+	switch val := v.(type) {
+	case map[string]interface{}:
+		for k, item := range val {
+			if r.match(k) {
+				val[k] = Redacted
+				continue
+			}
+			val[k] = r.value(item)
+		}
+	case []interface{}:
+		for i, item := range val {
+			val[i] = r.value(item)
+		}
+	}
+	return v
+}
+
+// Attrs 脱敏日志字段，字段名匹配时替换值，group 字段递归处理
+func (r *Redactor) Attrs(attrs []slog.Attr) []slog.Attr {
+	out := make([]slog.Attr, len(attrs))
+	for i, a := range attrs {
+		switch {
+		case r.match(a.Key):
+			out[i] = slog.String(a.Key, Redacted)
+		case a.Value.Kind() == slog.KindGroup:
+			out[i] = slog.Attr{Key: a.Key, Value: slog.GroupValue(r.Attrs(a.Value.Group())...)}
+		default:
+			out[i] = a
+		}
+	}
+	return out
+}
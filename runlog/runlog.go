@@ -1,5 +1,17 @@
+// Package runlog sdk 的执行日志
+//
+// Logger 是分级的结构化日志接口，默认不输出任何日志。NewSlog 把 *slog.Logger 适配成 Logger，
+// FromRunLogger 把旧的 RunLogger 适配成 Logger。sdk 输出日志之前会脱敏 bot_app_key 以及配置的字段。
 package runlog
 
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+)
+
+// RunLogger 只区分 Info 和 Error 的日志接口，新代码建议使用 Logger
 type RunLogger interface {
 	// Info logs information with a specified message.
 	Info(message string)
@@ -7,3 +19,130 @@ type RunLogger interface {
 	// Error logs error information with a specified message.
 	Error(message string)
 }
+
+// 日志字段
+const (
+	KeyRunID     = "run_id"     // 执行 ID，即请求的 request_id
+	KeySessionID = "session_id" // 对话 ID
+	KeyAgent     = "agent"      // agent 名称
+	KeyTool      = "tool"       // 工具名称
+	KeyCallID    = "call_id"    // 工具调用 ID
+	KeyTurn      = "turn"       // 轮次，从 0 开始
+)
+
+// Logger 分级的结构化日志接口，实现需要并发安全
+type Logger interface {
+	// Enabled 是否输出 level 级别的日志，用于跳过序列化请求等开销较大的操作
+	Enabled(ctx context.Context, level slog.Level) bool
+
+	// Log 输出一条日志，attrs 已经包括 ctx 中的字段并完成脱敏
+	Log(ctx context.Context, level slog.Level, msg string, attrs ...slog.Attr)
+}
+
+// Nop 不输出任何日志的 Logger
+type Nop struct{}
+
+// Enabled 实现 Logger
+func (Nop) Enabled(ctx context.Context, level slog.Level) bool { return false }
+
+// Log 实现 Logger
+func (Nop) Log(ctx context.Context, level slog.Level, msg string, attrs ...slog.Attr) {}
+
+// slogLogger *slog.Logger 的适配
+type slogLogger struct {
+	l *slog.Logger
+}
+
+// NewSlog 把 *slog.Logger 适配成 Logger，l 为空时使用 slog.Default()
+func NewSlog(l *slog.Logger) Logger {
+	if l == nil {
+		l = slog.Default()
+	}
+	return slogLogger{l: l}
+}
+
+// Enabled 实现 Logger
+func (s slogLogger) Enabled(ctx context.Context, level slog.Level) bool {
+	return s.l.Enabled(ctx, level)
+}
+
+// Log 实现 Logger
+func (s slogLogger) Log(ctx context.Context, level slog.Level, msg string, attrs ...slog.Attr) {
+	s.l.LogAttrs(ctx, level, msg, attrs...)
+}
+
+// runLogger RunLogger 的适配
+type runLogger struct {
+	l RunLogger
+}
+
+// FromRunLogger 把 RunLogger 适配成 Logger，l 为空时返回 Nop
+// Debug 和 Info 级别的日志使用 Info 输出，Warn 及以上级别使用 Error 输出，字段以 key=value 的形式拼接在消息后面
+func FromRunLogger(l RunLogger) Logger {
+	if l == nil {
+		return Nop{}
+	}
+	return runLogger{l: l}
+}
+
+// Enabled 实现 Logger
+func (r runLogger) Enabled(ctx context.Context, level slog.Level) bool {
+	return true
+}
+
+// Log 实现 Logger
+func (r runLogger) Log(ctx context.Context, level slog.Level, msg string, attrs ...slog.Attr) {
+	b := strings.Builder{}
+	b.WriteString("[lkesdk]")
+	b.WriteString(msg)
+	for _, a := range attrs {
+		fmt.Fprintf(&b, " %s=%v", a.Key, a.Value)
+	}
+	if level >= slog.LevelWarn {
+		r.l.Error(b.String())
+		return
+	}
+	r.l.Info(b.String())
+}
+
+// attrsKey context 中保存日志字段的 key
+type attrsKey struct{}
+
+// WithAttrs 把日志字段放到 context 中，sdk 使用该 context 输出的日志都会带上这些字段
+// 同名的字段覆盖 context 中已有的字段，例如嵌套执行的 agent 工具使用自己的 run_id
+func WithAttrs(ctx context.Context, attrs ...slog.Attr) context.Context {
+	parent := Attrs(ctx)
+	merged := make([]slog.Attr, 0, len(parent)+len(attrs))
+	for _, a := range parent {
+		overridden := false
+		for _, b := range attrs {
+			if a.Key == b.Key {
+				overridden = true
+				break
+			}
+		}
+		if !overridden {
+			merged = append(merged, a)
+		}
+	}
+	merged = append(merged, attrs...)
+	return context.WithValue(ctx, attrsKey{}, merged)
+}
+
+// Attrs 获取 context 中的日志字段
+func Attrs(ctx context.Context) []slog.Attr {
+	attrs, _ := ctx.Value(attrsKey{}).([]slog.Attr)
+	return attrs
+}
+
+// Log 输出一条日志，带上 ctx 中的字段并脱敏，l 为空时不输出
+func Log(ctx context.Context, l Logger, r *Redactor, level slog.Level, msg string, attrs ...slog.Attr) {
+	if l == nil || !l.Enabled(ctx, level) {
+		return
+	}
+	ctxAttrs := Attrs(ctx)
+	all := make([]slog.Attr, 0, len(ctxAttrs)+len(attrs))
+	all = append(all, ctxAttrs...)
+	all = append(all, attrs...)
+	l.Log(ctx, level, msg, r.Attrs(all)...)
+}
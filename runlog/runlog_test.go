@@ -0,0 +1,90 @@
+package runlog_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/tencent-lke/lke-sdk-go/runlog"
+)
+
+func TestRedactorJSON(t *testing.T) {
+	r := runlog.NewRedactor("token")
+	out := r.JSON(map[string]interface{}{
+		"bot_app_key": "secret",
+		"content":     "hello",
+		"custom_variables": map[string]string{
+			"token": "abc",
+			"city":  "shenzhen",
+		},
+		"tool_ouputs": []map[string]string{{"token": "def"}},
+	})
+	for _, secret := range []string{"secret", "abc", "def"} {
+		if strings.Contains(out, secret) {
+			t.Fatalf("except %s redacted, actual: %s", secret, out)
+		}
+	}
+	if !strings.Contains(out, "shenzhen") || !strings.Contains(out, "hello") {
+		t.Fatalf("unexpected redaction: %s", out)
+	}
+}
+
+func TestRedactorText(t *testing.T) {
+	r := runlog.NewRedactor("token")
+	if out := r.Text(`{"token":"abc","city":"shenzhen"}`); strings.Contains(out, "abc") || !strings.Contains(out, "shenzhen") {
+		t.Fatalf("unexpected redaction: %s", out)
+	}
+	if out := r.Text("plain text"); out != "plain text" {
+		t.Fatalf("except plain text unchanged, actual: %s", out)
+	}
+}
+
+func TestLogWithContextAttrs(t *testing.T) {
+	buf := &bytes.Buffer{}
+	logger := runlog.NewSlog(slog.New(slog.NewJSONHandler(buf, &slog.HandlerOptions{Level: slog.LevelDebug})))
+	ctx := runlog.WithAttrs(context.Background(), slog.String(runlog.KeyRunID, "run-1"),
+		slog.String(runlog.KeyAgent, "Agent-A"))
+	ctx = runlog.WithAttrs(ctx, slog.String(runlog.KeyAgent, "Agent-B"))
+	runlog.Log(ctx, logger, runlog.NewRedactor("password"), slog.LevelWarn, "hello",
+		slog.String("password", "123456"))
+
+	record := map[string]interface{}{}
+	if err := json.Unmarshal(buf.Bytes(), &record); err != nil {
+		t.Fatal(err)
+	}
+	if record["level"] != "WARN" || record["msg"] != "hello" || record[runlog.KeyRunID] != "run-1" ||
+		record[runlog.KeyAgent] != "Agent-B" || record["password"] != runlog.Redacted {
+		t.Fatalf("unexpected record: %v", record)
+	}
+}
+
+type lineLogger struct {
+	info, error []string
+}
+
+func (l *lineLogger) Info(message string) {
+	l.info = append(l.info, message)
+}
+
+func (l *lineLogger) Error(message string) {
+	l.error = append(l.error, message)
+}
+
+func TestFromRunLogger(t *testing.T) {
+	l := &lineLogger{}
+	logger := runlog.FromRunLogger(l)
+	ctx := context.Background()
+	runlog.Log(ctx, logger, nil, slog.LevelDebug, "debug")
+	runlog.Log(ctx, logger, nil, slog.LevelInfo, "info", slog.Int(runlog.KeyTurn, 1))
+	runlog.Log(ctx, logger, nil, slog.LevelWarn, "warn")
+	if len(l.info) != 2 || l.info[0] != "[lkesdk]debug" || l.info[1] != "[lkesdk]info turn=1" {
+		t.Fatalf("unexpected info logs: %v", l.info)
+	}
+	if len(l.error) != 1 || l.error[0] != "[lkesdk]warn" {
+		t.Fatalf("unexpected error logs: %v", l.error)
+	}
+	runlog.Log(ctx, runlog.FromRunLogger(nil), nil, slog.LevelError, "nop")
+}
@@ -0,0 +1,115 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/tencent-lke/lke-sdk-go/lkeerrors"
+	"github.com/tencent-lke/lke-sdk-go/usage"
+)
+
+// tokenUsage 预算检查使用的 token 数，按模型分别统计
+type tokenUsage struct {
+	total  uint64
+	models map[string]uint64
+}
+
+func newTokenUsage(records []usage.Record) tokenUsage {
+	u := tokenUsage{models: map[string]uint64{}}
+	u.add(records)
+	return u
+}
+
+func (u *tokenUsage) add(records []usage.Record) {
+	for _, r := range records {
+		u.total += r.TotalTokens
+		u.models[r.Model] += r.TotalTokens
+	}
+}
+
+// checkBudget 收到 token 统计事件后检查是否已经超过预算
+func (r *RunResult) checkBudget() error {
+	return r.check(nil, tokenUsage{})
+}
+
+// checkNextTurn 按消耗最多的一轮估算下一轮的用量，可能超过预算时不再开始新的一轮
+func (r *RunResult) checkNextTurn() error {
+	estimate := tokenUsage{models: map[string]uint64{}}
+	for _, turn := range r.Turns {
+		u := newTokenUsage(turn.records())
+		estimate.total = max(estimate.total, u.total)
+		for name, n := range u.models {
+			estimate.models[name] = max(estimate.models[name], n)
+		}
+	}
+	return r.check(nil, estimate)
+}
+
+// check 检查本次执行和上层执行的预算
+// 已用量来自用量收集器，包括嵌套的 agent 工具，进行中的一轮使用最近一次 token 统计
+// extra 为嵌套执行进行中的一轮的用量，estimate 为预估的下一轮用量
+func (r *RunResult) check(extra []usage.Record, estimate tokenUsage) error {
+	r.mu.Lock()
+	live := append(append([]usage.Record{}, r.liveRecords...), extra...)
+	used := newTokenUsage(r.collector.Records())
+	used.add(live)
+	session := used
+	if r.sessionUsage != nil {
+		// 对话的用量包括本次执行已经结束的轮次和同一个对话中并发的执行
+		session = newTokenUsage(r.sessionUsage.Records())
+		session.add(live)
+	}
+	r.mu.Unlock()
+	if r.budget != nil {
+		if err := r.exceeds(used, session, estimate); err != nil {
+			return err
+		}
+	}
+	if r.parent != nil {
+		return r.parent.check(live, estimate)
+	}
+	return nil
+}
+
+// exceeds 已用量加上预估用量超过预算时返回 ErrBudgetExceeded
+func (r *RunResult) exceeds(used, session, estimate tokenUsage) error {
+	b := r.budget
+	if err := budgetErr("total", used.total, estimate.total, b.MaxTotalTokens); err != nil {
+		return err
+	}
+	if err := budgetErr("session", session.total, estimate.total, b.MaxSessionTokens); err != nil {
+		return err
+	}
+	for name, limit := range b.MaxModelTokens {
+		if err := budgetErr("model "+name, used.models[name], estimate.models[name], limit); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// budgetErr limit 为 0 表示不限制
+func budgetErr(scope string, used, estimate uint64, limit uint32) error {
+	if limit == 0 || used+estimate <= uint64(limit) {
+		return nil
+	}
+	if estimate > 0 {
+		return fmt.Errorf("%w: %s tokens used %d, next turn estimated %d, limit %d",
+			lkeerrors.ErrBudgetExceeded, scope, used, estimate, limit)
+	}
+	return fmt.Errorf("%w: %s tokens used %d, limit %d", lkeerrors.ErrBudgetExceeded, scope, used, limit)
+}
+
+// resultKey context 中保存当前执行的 key
+type resultKey struct{}
+
+// withResult 把当前执行放到 context 中，嵌套执行的 agent 工具通过它检查上层执行的预算
+func withResult(ctx context.Context, r *RunResult) context.Context {
+	return context.WithValue(ctx, resultKey{}, r)
+}
+
+// resultFromContext 获取 context 中的上层执行，没有时返回空
+func resultFromContext(ctx context.Context) *RunResult {
+	r, _ := ctx.Value(resultKey{}).(*RunResult)
+	return r
+}
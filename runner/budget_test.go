@@ -0,0 +1,70 @@
+package runner_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/tencent-lke/lke-sdk-go/event"
+	"github.com/tencent-lke/lke-sdk-go/lkeerrors"
+	"github.com/tencent-lke/lke-sdk-go/lketest"
+	"github.com/tencent-lke/lke-sdk-go/model"
+	"github.com/tencent-lke/lke-sdk-go/runner"
+	"github.com/tencent-lke/lke-sdk-go/usage"
+)
+
+// modelTokenStat 一个模型消耗 tokens 个 token 的统计事件
+func modelTokenStat(model string, tokens uint32) lketest.Event {
+	return lketest.TokenStat(event.TokenStatEvent{
+		TokenCount: tokens,
+		Procedures: []event.Procedure{{
+			Name:              event.ProcedureLLM,
+			TokenUsageDetails: []*event.TokenUsage{{ModelName: model, TotalTokens: tokens}},
+		}},
+	})
+}
+
+func TestRunBudget(t *testing.T) {
+	interrupt := lketest.Interrupt("Agent-A", lketest.ToolCall("call-1", "add", `{"a":1,"b":2}`))
+	srv := lketest.NewServer()
+	defer srv.Close()
+	var calls int32
+
+	// token 统计超过预算时立即中止，返回已经完成部分的记录
+	srv.Enqueue(lketest.NewTurn(modelTokenStat("function-call-pro", 150), interrupt))
+	r := newTestRunner(t, srv, &calls, runner.RunnerConf{
+		Budget: &model.Budget{MaxModelTokens: map[string]uint32{"function-call-pro": 100}},
+	})
+	result, err := run(r, "1+2")
+	if !errors.Is(err, lkeerrors.ErrBudgetExceeded) {
+		t.Fatalf("except ErrBudgetExceeded, actual: %v", err)
+	}
+	if result == nil || len(result.Turns) != 1 || result.Tokens() != 150 || calls != 0 {
+		t.Fatalf("unexpected partial result: %+v, calls: %d", result, calls)
+	}
+
+	// 预估提交工具输出的下一轮会超过预算时不再执行本地工具
+	srv.Enqueue(lketest.NewTurn(modelTokenStat("function-call-pro", 150), interrupt))
+	r = newTestRunner(t, srv, &calls, runner.RunnerConf{Budget: &model.Budget{MaxTotalTokens: 250}})
+	result, err = run(r, "1+2")
+	if !errors.Is(err, lkeerrors.ErrBudgetExceeded) || len(result.Turns) != 1 || calls != 0 {
+		t.Fatalf("except refused next turn before running tools, actual: %v, calls: %d", err, calls)
+	}
+	if len(srv.Requests()) != 2 {
+		t.Fatalf("except 2 requests, actual: %d", len(srv.Requests()))
+	}
+
+	// 对话级别的预算包括对话中其他执行已经消耗的 token，本次执行的用量实时累加到对话
+	session := usage.NewCollector()
+	session.Add(usage.Record{Usage: usage.Usage{TotalTokens: 100}})
+	srv.Enqueue(lketest.NewTurn(modelTokenStat("function-call-pro", 100), lketest.Reply("ok")))
+	r = newTestRunner(t, srv, &calls, runner.RunnerConf{
+		Budget:       &model.Budget{MaxSessionTokens: 150},
+		SessionUsage: session,
+	})
+	if _, err := run(r, "hi"); !errors.Is(err, lkeerrors.ErrBudgetExceeded) {
+		t.Fatalf("except ErrBudgetExceeded, actual: %v", err)
+	}
+	if session.Total().TotalTokens != 200 {
+		t.Fatalf("except session usage 200, actual: %+v", session.Total())
+	}
+}
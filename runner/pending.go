@@ -0,0 +1,158 @@
+package runner
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/openai/openai-go"
+	"github.com/tencent-lke/lke-sdk-go/approval"
+	"github.com/tencent-lke/lke-sdk-go/event"
+	"github.com/tencent-lke/lke-sdk-go/lkeerrors"
+	"github.com/tencent-lke/lke-sdk-go/model"
+	"github.com/tencent-lke/lke-sdk-go/usage"
+)
+
+// Pending 暂停的执行，可以序列化成 json 保存，之后可以在其他进程中通过 Resume 继续执行
+// 需要审批的调用没有审批结果，或者执行时设置了 model.Options.SuspendOnInterrupt 时，执行在中断处暂停
+type Pending struct {
+	RequestID    string             `json:"request_id"`
+	SessionID    string             `json:"session_id"`
+	VisitorBizID string             `json:"visitor_biz_id"`
+	Query        string             `json:"query"`
+	Turn         int                `json:"turn"`              // 暂停的轮次，从 0 开始
+	Request      *model.ChatRequest `json:"request"`           // 暂停轮次发送的请求，不包括 bot_app_key 和 custom_variables
+	Reply        *event.ReplyEvent  `json:"reply"`             // 暂停轮次收到的中断回复，包括需要执行的工具调用
+	CurrentAgent string             `json:"current_agent"`     // 要求执行工具的 agent
+	Suspend      bool               `json:"suspend,omitempty"` // 继续执行后，之后的中断是否也暂停
+	Budget       *model.Budget      `json:"budget,omitempty"`  // 执行时 model.Options 中的 token 预算，为空时继续执行使用 client 当前的预算
+	EnvSet       string             `json:"env_set,omitempty"` // 执行时 model.Options 中的泳道环境
+	// Approvals 等待审批的工具调用
+	Approvals []approval.Request `json:"approvals,omitempty"`
+	// Usage 暂停前已经使用的 token，继续执行时计入本次执行的用量和预算
+	Usage []usage.Record `json:"usage,omitempty"`
+}
+
+// ResumeInput 继续执行时提供的输入
+type ResumeInput struct {
+	// Outputs 工具调用 ID 到工具输出的映射，提供了输出的调用不在本地执行，直接把输出提交给云端
+	Outputs map[string]string `json:"outputs,omitempty"`
+	// Decisions 工具调用 ID 到审批结果的映射，没有输出也没有审批结果的需要审批的调用会再次暂停
+	Decisions map[string]approval.Decision `json:"decisions,omitempty"`
+	// CustomVariables 用户自定义参数，可能包括敏感信息，Pending 中不保存，需要在继续执行时重新传入
+	CustomVariables map[string]string `json:"-"`
+}
+
+// ToolCalls 暂停轮次需要执行的工具调用
+func (p *Pending) ToolCalls() []*openai.ToolCallDeltaUnion {
+	if p.Reply == nil || p.Reply.InterruptInfo == nil {
+		return nil
+	}
+	return p.Reply.InterruptInfo.ToolCalls
+}
+
+// newPending 创建暂停的执行
+func (r *RunResult) newPending(req *model.ChatRequest, turn *Turn, reply *event.ReplyEvent,
+	approvals []approval.Request) *Pending {
+	snapshot := *req
+	snapshot.BotAppKey = ""
+	snapshot.CustomVariables = nil
+	return &Pending{
+		RequestID:    r.RequestID,
+		SessionID:    r.SessionID,
+		VisitorBizID: r.VisitorBizID,
+		Query:        r.Query,
+		Turn:         turn.Index,
+		Request:      &snapshot,
+		Reply:        reply,
+		CurrentAgent: reply.InterruptInfo.CurrentAgent,
+		Suspend:      r.suspend,
+		Budget:       r.optsBudget,
+		EnvSet:       req.Options.EnvSet,
+		Approvals:    approvals,
+		Usage:        r.collector.Records(),
+	}
+}
+
+// pendingApprovals 没有输出和审批结果、也没有 Approver 的需要审批的工具调用
+func (c *RunnerImp) pendingApprovals(reply *event.ReplyEvent, input ResumeInput) []approval.Request {
+	if c.runconf.Approver != nil || reply.InterruptInfo == nil {
+		return nil
+	}
+	var approvals []approval.Request
+	for _, call := range reply.InterruptInfo.ToolCalls {
+		if call == nil {
+			continue
+		}
+		agent := reply.InterruptInfo.CurrentAgent
+		if c.runconf.ApprovalPolicy.Mode(agent, call.Function.Name) != approval.ModeRequired {
+			continue
+		}
+		if _, ok := input.Outputs[call.ID]; ok {
+			continue
+		}
+		if _, ok := input.Decisions[call.ID]; ok {
+			continue
+		}
+		req := approval.Request{CallID: call.ID, ToolName: call.Function.Name, AgentName: agent}
+		_ = json.Unmarshal([]byte(call.Function.Arguments), &req.Arguments)
+		approvals = append(approvals, req)
+	}
+	return approvals
+}
+
+// approve 按审批策略决定工具调用是否执行，args 为模型给出的参数
+func (c *RunnerImp) approve(ctx context.Context, agent string, call *openai.ToolCallDeltaUnion,
+	args map[string]interface{}, decisions map[string]approval.Decision) approval.Decision {
+	switch c.runconf.ApprovalPolicy.Mode(agent, call.Function.Name) {
+	case approval.ModeDenied:
+		return approval.Reject("the tool is denied by policy")
+	case approval.ModeRequired:
+		if d, ok := decisions[call.ID]; ok {
+			return d
+		}
+		if c.runconf.Approver == nil {
+			// 作为工具的 agent 嵌套执行和 mock 模式执行工具时会走到这里，它们不能暂停等待审批
+			return approval.Reject("approval required but no approver is set and the run can not pause")
+		}
+		d, err := c.runconf.Approver.Approve(ctx, approval.Request{
+			CallID:    call.ID,
+			ToolName:  call.Function.Name,
+			AgentName: agent,
+			Arguments: args,
+		})
+		if err != nil {
+			return approval.Reject(fmt.Sprintf("approval failed: %v", err))
+		}
+		return d
+	}
+	return approval.Approve()
+}
+
+// Resume 从暂停的执行继续，先按 input 处理暂停轮次的工具调用，再继续调用云端
+func (c *RunnerImp) Resume(ctx context.Context, pending *Pending, input ResumeInput) (*RunResult, error) {
+	if pending == nil || pending.Request == nil || pending.Reply == nil || pending.Reply.InterruptInfo == nil {
+		return &RunResult{}, errors.New("invalid pending run: missing request or interrupt reply")
+	}
+	req := *pending.Request
+	req.BotAppKey = c.runconf.BotAppKey
+	req.Options.EnvSet = pending.EnvSet
+	req.CustomVariables = input.CustomVariables
+	return c.run(ctx, &req, pending.Query, pending.Budget, func(ctx context.Context, result *RunResult) error {
+		result.suspend = pending.Suspend
+		// 暂停前的用量已经累加到调用方和对话，只计入本次执行
+		result.collector.Seed(pending.Usage...)
+		return c.loop(ctx, result, &req, pending, input)
+	})
+}
+
+// errRunPaused 暂停执行的错误
+func errRunPaused(pending *Pending) error {
+	if len(pending.Approvals) > 0 {
+		return fmt.Errorf("%w at turn %d: %d tool calls waiting for approval", lkeerrors.ErrRunPaused,
+			pending.Turn, len(pending.Approvals))
+	}
+	return fmt.Errorf("%w at turn %d: %d tool calls waiting for outputs", lkeerrors.ErrRunPaused,
+		pending.Turn, len(pending.ToolCalls()))
+}
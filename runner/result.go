@@ -0,0 +1,195 @@
+package runner
+
+import (
+	"sync"
+	"time"
+
+	"github.com/tencent-lke/lke-sdk-go/event"
+	"github.com/tencent-lke/lke-sdk-go/metrics"
+	"github.com/tencent-lke/lke-sdk-go/model"
+	"github.com/tencent-lke/lke-sdk-go/usage"
+)
+
+// RunResult 单次执行的完整记录，可以序列化成 json 保存
+type RunResult struct {
+	RequestID    string            `json:"request_id"`
+	SessionID    string            `json:"session_id"`
+	VisitorBizID string            `json:"visitor_biz_id"`
+	Query        string            `json:"query"`
+	Turns        []*Turn           `json:"turns"`                 // 按顺序的每一轮云端调用
+	FinalReply   *event.ReplyEvent `json:"final_reply,omitempty"` // 最终回复，执行失败时为空
+	// UsageSummary 按 agent、模型、过程汇总的用量和费用，包括嵌套的 agent 工具
+	UsageSummary *usage.Summary `json:"usage_summary,omitempty"`
+	StartTime    time.Time      `json:"start_time"`
+	Elapsed      time.Duration  `json:"elapsed"` // 总耗时，json 中单位为纳秒
+	Error        string         `json:"error,omitempty"`
+	Pending      *Pending       `json:"pending,omitempty"` // 执行暂停时的状态，用于 Resume
+
+	budget       *model.Budget    // 本次执行的 token 预算
+	optsBudget   *model.Budget    // model.Options 中的 token 预算，没有设置时为空，暂停时保存到 Pending
+	sessionUsage *usage.Collector // 所属对话的用量收集器，可以为空
+	collector    *usage.Collector // 本次执行的用量收集器，包括嵌套的 agent 工具，是用量和预算的唯一来源
+	parent       *RunResult       // 调用 agent 工具的上层执行，嵌套执行同时检查上层执行的预算
+
+	mu          sync.Mutex       // 保护 liveRecords，嵌套执行会在其他协程中检查上层执行的预算
+	liveRecords []usage.Record   // 进行中的一轮最近一次 token 统计的用量，还没有累加到 collector
+	prices      usage.PriceTable // 计算费用的价格表
+	startAgent  string           // 入口 agent，没有中断信息的轮次用量记在入口 agent 上
+	metrics     metrics.Recorder // 指标记录
+	replied     bool             // 是否已经收到回复事件
+	suspend     bool             // 需要执行本地工具时是否暂停
+}
+
+// Turn 一轮云端调用，以及云端要求执行的本地工具
+type Turn struct {
+	Index         int                      `json:"index"`                    // 轮次，从 0 开始
+	Request       *model.ChatRequest       `json:"request"`                  // 发送的请求，不包括 bot_app_key 和用户自定义参数
+	Attempts      int                      `json:"attempts"`                 // 调用云端接口的次数，包括重试
+	TraceID       string                   `json:"trace_id,omitempty"`       // 云端返回的 trace_id，用于问题排查
+	Replies       []*event.ReplyEvent      `json:"replies,omitempty"`        // 本轮收到的完整回复，包括中间 agent 的回复
+	Thought       *event.AgentThoughtEvent `json:"thought,omitempty"`        // 本轮最后一次思考事件
+	References    []*event.ReferenceEvent  `json:"references,omitempty"`     // 本轮的参考来源
+	TokenStat     *event.TokenStatEvent    `json:"token_stat,omitempty"`     // 本轮最后一次 token 统计
+	InterruptInfo *event.InterruptInfo     `json:"interrupt_info,omitempty"` // 需要执行本地工具时的中断信息
+	ToolCalls     []*ToolCallRecord        `json:"tool_calls,omitempty"`     // 本地工具调用，和中断信息中的工具调用一一对应
+	StartTime     time.Time                `json:"start_time"`
+	Elapsed       time.Duration            `json:"elapsed"` // 本轮耗时，包括本地工具执行
+	Error         string                   `json:"error,omitempty"`
+
+	result  *RunResult
+	charged bool // 用量是否已经累加到用量收集器
+}
+
+// ToolCallRecord 一次本地工具调用
+type ToolCallRecord struct {
+	CallID    string                 `json:"call_id"`
+	ToolName  string                 `json:"tool_name"`
+	AgentName string                 `json:"agent_name"`
+	Input     map[string]interface{} `json:"input"`  // 工具的输入，包括用户自定义参数
+	Output    string                 `json:"output"` // 提交给云端的工具输出
+	Error     string                 `json:"error,omitempty"`
+	Rejected  string                 `json:"rejected,omitempty"` // 审批拒绝的原因，拒绝时工具没有执行
+	External  bool                   `json:"external,omitempty"` // 输出由 Resume 提供，没有在本地执行
+	Attempts  int                    `json:"attempts,omitempty"` // 工具的执行次数，包括重试，被中间件拦截时为 0
+	StartTime time.Time              `json:"start_time"`
+	Elapsed   time.Duration          `json:"elapsed"`
+}
+
+// newTurn 开始第 index 轮，记录请求的快照，快照中不包括 bot_app_key 和用户自定义参数
+func (r *RunResult) newTurn(index int, req *model.ChatRequest) *Turn {
+	snapshot := *req
+	snapshot.BotAppKey = ""
+	snapshot.CustomVariables = nil
+	turn := &Turn{
+		Index:     index,
+		Request:   &snapshot,
+		StartTime: time.Now(),
+		result:    r,
+	}
+	r.Turns = append(r.Turns, turn)
+	return turn
+}
+
+// finish 结束执行，汇总耗时和用量
+func (r *RunResult) finish(err error) {
+	r.Elapsed = time.Since(r.StartTime)
+	if err != nil {
+		r.Error = err.Error()
+	}
+	r.UsageSummary = r.collector.Summary(r.prices)
+}
+
+// Tokens 本次执行消耗的 token 总数，包括嵌套的 agent 工具
+func (r *RunResult) Tokens() uint64 {
+	if r.UsageSummary != nil {
+		return r.UsageSummary.Total.TotalTokens
+	}
+	return r.collector.Total().TotalTokens
+}
+
+// setTraceID 记录云端返回的第一个 trace_id
+func (t *Turn) setTraceID(traceID string) {
+	if t.TraceID == "" {
+		t.TraceID = traceID
+	}
+}
+
+// finish 结束本轮，本轮的用量还没有累加时累加到用量收集器
+func (t *Turn) finish(err error) {
+	t.Elapsed = time.Since(t.StartTime)
+	if err != nil {
+		t.Error = err.Error()
+	}
+	t.charge()
+}
+
+// charge 云端调用结束后把本轮最后一次 token 统计累加到用量收集器和所属的对话，每轮只累加一次
+// 在执行本地工具之前调用，同一个对话中并发的执行可以及时看到本轮的用量
+func (t *Turn) charge() {
+	if t.charged {
+		return
+	}
+	t.charged = true
+	records := t.records()
+	models := map[string]uint64{}
+	t.result.mu.Lock()
+	for _, r := range records {
+		t.result.collector.Add(r)
+		models[r.Model] += r.TotalTokens
+	}
+	t.result.liveRecords = nil
+	t.result.mu.Unlock()
+	for model, tokens := range models {
+		t.result.recorder().Tokens(t.agent(), model, tokens)
+	}
+}
+
+// setTokenStat 记录本轮最近一次 token 统计，用量在累加到用量收集器之前计入预算检查
+func (t *Turn) setTokenStat(stat *event.TokenStatEvent) {
+	t.TokenStat = stat
+	records := t.records()
+	t.result.mu.Lock()
+	t.result.liveRecords = records
+	t.result.mu.Unlock()
+}
+
+// agent 产生本轮对话的 agent，没有中断信息时为入口 agent
+func (t *Turn) agent() string {
+	if t.InterruptInfo != nil && t.InterruptInfo.CurrentAgent != "" {
+		return t.InterruptInfo.CurrentAgent
+	}
+	return t.result.startAgent
+}
+
+// records 本轮最后一次 token 统计的用量
+func (t *Turn) records() []usage.Record {
+	return usage.TokenStatRecords(t.agent(), t.TokenStat)
+}
+
+// recorder 指标记录，没有设置时不记录
+func (r *RunResult) recorder() metrics.Recorder {
+	if r.metrics == nil {
+		return metrics.Nop{}
+	}
+	return r.metrics
+}
+
+// firstReply 记录第一个回复事件的时间
+func (r *RunResult) firstReply() {
+	if r.replied {
+		return
+	}
+	r.replied = true
+	r.recorder().FirstReply(r.startAgent, time.Since(r.StartTime))
+}
+
+// toolTurns 本地工具调用的轮数
+func (r *RunResult) toolTurns() int {
+	n := 0
+	for _, turn := range r.Turns {
+		if turn.InterruptInfo != nil {
+			n++
+		}
+	}
+	return n
+}
@@ -0,0 +1,118 @@
+package runner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/tencent-lke/lke-sdk-go/lkeerrors"
+	"github.com/tencent-lke/lke-sdk-go/util"
+)
+
+// RetryPolicy 调用云端接口的重试策略
+// 重试会复用同一个请求（包括 ToolOuputs），本地工具不会被重复执行
+type RetryPolicy struct {
+	MaxAttempts     int           // 最大尝试次数，包括第一次调用，小于等于 1 时不重试
+	InitialBackoff  time.Duration // 第一次重试前的等待时间
+	MaxBackoff      time.Duration // 最大等待时间，0 表示不限制
+	Multiplier      float64       // 每次重试等待时间的增长倍数，小于 1 时按 1 处理
+	Jitter          float64       // 随机抖动比例，取值 [0, 1]，实际等待时间在 backoff*(1±Jitter) 之间
+	RetryableStatus []int         // 可以重试的 http 状态码
+	// RetryableError 自定义错误是否可以重试，为空时传输错误和 sse 读取错误可以重试
+	RetryableError func(err error) bool
+}
+
+// DefaultRetryPolicy 默认的重试策略，最多尝试 3 次，指数退避并带随机抖动
+func DefaultRetryPolicy() *RetryPolicy {
+	return &RetryPolicy{
+		MaxAttempts:    3,
+		InitialBackoff: 500 * time.Millisecond,
+		MaxBackoff:     5 * time.Second,
+		Multiplier:     2,
+		Jitter:         0.2,
+		RetryableStatus: []int{
+			http.StatusTooManyRequests,
+			http.StatusInternalServerError,
+			http.StatusBadGateway,
+			http.StatusServiceUnavailable,
+			http.StatusGatewayTimeout,
+		},
+	}
+}
+
+// maxAttempts 最大尝试次数
+func (p *RetryPolicy) maxAttempts() int {
+	if p == nil || p.MaxAttempts < 1 {
+		return 1
+	}
+	return p.MaxAttempts
+}
+
+// backoff 第 attempt 次尝试失败后的等待时间，attempt 从 1 开始
+func (p *RetryPolicy) backoff(attempt int) time.Duration {
+	return util.Backoff(p.InitialBackoff, p.MaxBackoff, p.Multiplier, p.Jitter, attempt)
+}
+
+// retryable 判断错误是否可以重试
+func (p *RetryPolicy) retryable(err error) bool {
+	if errors.Is(err, lkeerrors.ErrBudgetExceeded) {
+		return false
+	}
+	var apiErr *lkeerrors.APIError
+	if errors.As(err, &apiErr) {
+		for _, code := range p.RetryableStatus {
+			if code == apiErr.HTTPStatus {
+				return true
+			}
+		}
+	}
+	if p.RetryableError != nil {
+		return p.RetryableError(err)
+	}
+	var transportErr *transportError
+	return errors.As(err, &transportErr)
+}
+
+// transportError 发送请求或读取 sse 流的错误
+type transportError struct {
+	msg string
+	err error
+}
+
+func (e *transportError) Error() string {
+	return fmt.Sprintf("%s: %v", e.msg, e.err)
+}
+
+func (e *transportError) Unwrap() error {
+	return e.err
+}
+
+// streamError 已经处理过 sse 事件之后的错误，不能重试
+type streamError struct {
+	err error
+}
+
+func (e *streamError) Error() string {
+	return e.err.Error()
+}
+
+func (e *streamError) Unwrap() error {
+	return e.err
+}
+
+// sleepWithContext 等待 d，ctx 取消时提前返回错误
+func sleepWithContext(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
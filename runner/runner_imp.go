@@ -4,32 +4,64 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"log/slog"
 	"net/http"
 	"runtime/debug"
+	"sort"
 	"sync"
 	"sync/atomic"
 	"time"
 
+	"github.com/openai/openai-go"
+	"github.com/tencent-lke/lke-sdk-go/approval"
 	"github.com/tencent-lke/lke-sdk-go/event"
 	"github.com/tencent-lke/lke-sdk-go/eventhandler"
+	"github.com/tencent-lke/lke-sdk-go/lkeerrors"
+	"github.com/tencent-lke/lke-sdk-go/metrics"
 	"github.com/tencent-lke/lke-sdk-go/model"
 	"github.com/tencent-lke/lke-sdk-go/runlog"
 	"github.com/tencent-lke/lke-sdk-go/tool"
+	"github.com/tencent-lke/lke-sdk-go/tracing"
+	"github.com/tencent-lke/lke-sdk-go/usage"
+	"github.com/tencent-lke/lke-sdk-go/util"
 	"github.com/tmaxmax/go-sse"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // RunnerConf TODO
 type RunnerConf struct {
-	EnableSystemOpt     bool
-	StartAgent          string
+	EnableSystemOpt bool
+	StartAgent      string
+	// Deprecated: 使用 StructuredLogger，只设置 Logger 时通过 runlog.FromRunLogger 适配
 	Logger              runlog.RunLogger
+	StructuredLogger    runlog.Logger // 分级的结构化执行日志，为空时使用 Logger，都为空不输出
+	RedactKeys          []string      // 日志中需要脱敏的字段，bot_app_key 默认脱敏
 	EventHandler        eventhandler.EventHandler
 	MaxToolTurns        uint // 单次对话本地工具调用最大次数
 	Endpoint            string
 	BotAppKey           string
 	HttpClient          *http.Client
 	LocalToolRunTimeout time.Duration
+	RetryPolicy         *RetryPolicy     // 调用云端接口的重试策略，为空不重试
+	Budget              *model.Budget    // token 预算，为空不限制，model.Options 中的预算优先
+	SessionUsage        *usage.Collector // 所属对话的用量，执行中的用量实时累加，用于对话级别的预算，可以为空
+	PriceTable          usage.PriceTable // 计算费用的价格表，为空时费用为 0
+	Metrics             metrics.Recorder // 指标记录，为空时使用 ctx 中的 Recorder
+	// TracerProvider OpenTelemetry 的 TracerProvider，为空时使用全局的
+	TracerProvider trace.TracerProvider
+	// Propagator 向云端请求头注入 trace 上下文的 propagator，为空时使用全局的
+	Propagator propagation.TextMapPropagator
+	// ApprovalPolicy 工具调用的审批策略，为空时都自动执行
+	ApprovalPolicy *approval.Policy
+	// Approver 审批回调，为空时需要审批的调用会暂停执行
+	Approver approval.Approver
+	// Middlewares 工具中间件，函数工具、mcp 工具和 agent 工具都会经过中间件
+	Middlewares *tool.Middlewares
 }
 
 // RunnerImp TODO
@@ -38,7 +70,8 @@ type RunnerImp struct {
 	agents   []model.Agent
 	handoffs []model.Handoff
 	runconf  RunnerConf
-	IsClosed atomic.Bool
+	logger   runlog.Logger
+	redactor *runlog.Redactor
 }
 
 // NewRunnerImp TODO
@@ -49,16 +82,78 @@ func NewRunnerImp(toolsMap map[string][]tool.Tool, agents []model.Agent,
 		agents:   agents,
 		handoffs: handoffs,
 		runconf:  conf,
+		logger:   conf.StructuredLogger,
+		redactor: runlog.NewRedactor(conf.RedactKeys...),
+	}
+	if runner.logger == nil {
+		runner.logger = runlog.FromRunLogger(conf.Logger)
 	}
 	return runner
 }
 
-// RunWithTimeout TODO
+// log 输出一条带 ctx 中字段的脱敏日志
+func (c *RunnerImp) log(ctx context.Context, level slog.Level, msg string, attrs ...slog.Attr) {
+	runlog.Log(ctx, c.logger, c.redactor, level, msg, attrs...)
+}
+
+// logEnabled 是否输出 level 级别的日志，用于跳过序列化请求等开销较大的操作
+func (c *RunnerImp) logEnabled(ctx context.Context, level slog.Level) bool {
+	return c.logger.Enabled(ctx, level)
+}
+
+// metricsRecorder 获取指标记录，优先使用配置的 Recorder
+func (c *RunnerImp) metricsRecorder(ctx context.Context) metrics.Recorder {
+	if c.runconf.Metrics != nil {
+		return c.runconf.Metrics
+	}
+	return metrics.FromContext(ctx)
+}
+
+// runOutcome 执行结果
+func runOutcome(ctx context.Context, err error) string {
+	switch {
+	case err == nil:
+		return metrics.OutcomeSucceeded
+	case ctx.Err() != nil || errors.Is(err, context.Canceled):
+		return metrics.OutcomeCanceled
+	case errors.Is(err, lkeerrors.ErrRunPaused):
+		return metrics.OutcomePaused
+	}
+	return metrics.OutcomeFailed
+}
+
+// toolOutcome 工具调用结果
+func toolOutcome(err error) string {
+	switch {
+	case err == nil:
+		return metrics.ToolOK
+	case errors.Is(err, lkeerrors.ErrToolTimeout):
+		return metrics.ToolTimeout
+	case errors.Is(err, lkeerrors.ErrToolPanic):
+		return metrics.ToolPanic
+	case errors.Is(err, lkeerrors.ErrToolRejected):
+		return metrics.ToolRejected
+	case errors.Is(err, lkeerrors.ErrInvalidToolArgs):
+		return metrics.ToolInvalidArgs
+	}
+	return metrics.ToolError
+}
+
+// toolResult 工具执行结果
+type toolResult struct {
+	output interface{}
+	err    error
+}
+
+// RunWithTimeout 执行工具，超时或者 ctx 取消时立即返回，不等待工具结束
 func (c *RunnerImp) RunWithTimeout(ctx context.Context, f tool.Tool,
 	input map[string]interface{}) (output interface{}, err error) {
-	if c.runconf.LocalToolRunTimeout.Seconds() == 0 && f.GetTimeout() == 0 {
-		return f.Execute(ctx, input)
-	}
+	return c.runWithTimeout(ctx, f, f.Execute, input)
+}
+
+// runWithTimeout 使用 exec 执行工具 f，exec 为包装了中间件的 f.Execute
+func (c *RunnerImp) runWithTimeout(ctx context.Context, f tool.Tool, exec tool.ToolFunc,
+	input map[string]interface{}) (output interface{}, err error) {
 	var timeout time.Duration
 	if f.GetTimeout() != 0 {
 		timeout = f.GetTimeout()
@@ -67,40 +162,54 @@ func (c *RunnerImp) RunWithTimeout(ctx context.Context, f tool.Tool,
 	}
 	runCtx, cancel := context.WithCancel(ctx)
 	defer cancel()
-	signal := make(chan struct{}) // 无缓冲通道
+	resultCh := make(chan toolResult, 1) // 带缓冲，超时返回后工具协程不会阻塞
 	go func() {
-		defer close(signal) // 关闭通道广播完成
+		res := toolResult{}
 		defer func() {
 			if p := recover(); p != nil {
-				err = fmt.Errorf("panic: %v", p)
+				res.err = fmt.Errorf("%w: %v", lkeerrors.ErrToolPanic, p)
 			}
+			resultCh <- res
 		}()
 		begin := time.Now()
-		output, err = f.Execute(runCtx, input)
-		end := time.Now()
-		if c.runconf.Logger != nil {
-			c.runconf.Logger.Info(fmt.Sprintf("runWithTimeoutExecute: %s, cost: %v", f.GetName(), end.Sub(begin)))
-		}
+		res.output, res.err = exec(runCtx, input)
+		c.log(ctx, slog.LevelDebug, "tool executed", slog.Duration("cost", time.Since(begin)))
 	}()
-	t := time.NewTimer(timeout)
-	defer t.Stop() // 确保定时器释放
+	var timeoutC <-chan time.Time
+	if timeout > 0 {
+		t := time.NewTimer(timeout)
+		defer t.Stop() // 确保定时器释放
+		timeoutC = t.C
+	}
 
 	select {
-	case <-t.C:
-		return nil, fmt.Errorf("run tool %s timeout %ds", f.GetName(), int(timeout.Seconds()))
+	case <-timeoutC:
+		return nil, fmt.Errorf("run tool %s timeout %ds: %w", f.GetName(), int(timeout.Seconds()),
+			lkeerrors.ErrToolTimeout)
 	case <-runCtx.Done():
-		if err != nil {
-			return output, err // 工具错误优先
-		}
-		return output, runCtx.Err()
-	case <-signal:
-		return output, err
+		return nil, runCtx.Err()
+	case res := <-resultCh:
+		return res.output, res.err
+	}
+}
+
+// toolCallID 工具调用 ID，call 为空时返回空字符串
+func toolCallID(call *openai.ToolCallDeltaUnion) string {
+	if call == nil {
+		return ""
 	}
+	return call.ID
 }
 
 // RunTools TODO
 func (c *RunnerImp) RunTools(ctx context.Context, req *model.ChatRequest,
 	reply *event.ReplyEvent, output *[]string) {
+	c.runTools(ctx, req, reply, output, nil, ResumeInput{})
+}
+
+// runTools 并行执行中断信息中的工具调用，turn 不为空时记录每次调用，input 为 Resume 时提供的输出和审批结果
+func (c *RunnerImp) runTools(ctx context.Context, req *model.ChatRequest,
+	reply *event.ReplyEvent, output *[]string, turn *Turn, input ResumeInput) {
 	if reply == nil {
 		return
 	}
@@ -113,6 +222,9 @@ func (c *RunnerImp) RunTools(ctx context.Context, req *model.ChatRequest,
 	if len(*output) != len(reply.InterruptInfo.ToolCalls) {
 		return
 	}
+	if turn != nil {
+		turn.ToolCalls = make([]*ToolCallRecord, len(reply.InterruptInfo.ToolCalls))
+	}
 	// 处理工具调用，并行调用工具
 	wg := sync.WaitGroup{}
 	for i := range reply.InterruptInfo.ToolCalls {
@@ -122,9 +234,63 @@ func (c *RunnerImp) RunTools(ctx context.Context, req *model.ChatRequest,
 				wg.Done()
 			}()
 			toolCall := reply.InterruptInfo.ToolCalls[index]
+			if out, ok := input.Outputs[toolCallID(toolCall)]; ok && toolCall != nil {
+				// 工具在其他地方执行，直接提交输出
+				(*output)[index] = out
+				if turn != nil {
+					turn.ToolCalls[index] = &ToolCallRecord{
+						CallID:    toolCall.ID,
+						ToolName:  toolCall.Function.Name,
+						AgentName: reply.InterruptInfo.CurrentAgent,
+						Output:    out,
+						External:  true,
+						StartTime: time.Now(),
+					}
+				}
+				return
+			}
 			if toolCall != nil {
+				ctx := runlog.WithAttrs(ctx,
+					slog.String(runlog.KeyAgent, reply.InterruptInfo.CurrentAgent),
+					slog.String(runlog.KeyTool, toolCall.Function.Name),
+					slog.String(runlog.KeyCallID, toolCall.ID),
+				)
+				toolCtx, span := tracing.Tracer(ctx, c.runconf.TracerProvider).Start(ctx, tracing.SpanTool,
+					trace.WithAttributes(
+						attribute.String(tracing.AttrToolName, toolCall.Function.Name),
+						attribute.String(tracing.AttrAgentName, reply.InterruptInfo.CurrentAgent),
+						attribute.String(tracing.AttrToolCallID, toolCall.ID),
+					))
+				var toolErr error
+				begin := time.Now()
+				defer func() {
+					if toolErr != nil {
+						c.log(ctx, slog.LevelWarn, "tool call failed", slog.String("error", toolErr.Error()))
+					}
+					tracing.End(span, toolErr)
+					c.metricsRecorder(ctx).ToolCall(toolCall.Function.Name, reply.InterruptInfo.CurrentAgent,
+						toolOutcome(toolErr), time.Since(begin))
+				}()
+				var record *ToolCallRecord
+				if turn != nil {
+					record = &ToolCallRecord{
+						CallID:    toolCall.ID,
+						ToolName:  toolCall.Function.Name,
+						AgentName: reply.InterruptInfo.CurrentAgent,
+						StartTime: time.Now(),
+					}
+					turn.ToolCalls[index] = record
+					defer func() {
+						record.Output = (*output)[index]
+						record.Elapsed = time.Since(record.StartTime)
+					}()
+				}
 				defer func() {
 					if p := recover(); p != nil {
+						toolErr = fmt.Errorf("%w: %v", lkeerrors.ErrToolPanic, p)
+						if record != nil {
+							record.Error = fmt.Sprintf("panic: %v", p)
+						}
 						(*output)[index] = fmt.Sprintf("Tool %s run failed, try another tool, error: %v",
 							toolCall.Function.Name, string(debug.Stack()))
 					}
@@ -134,6 +300,7 @@ func (c *RunnerImp) RunTools(ctx context.Context, req *model.ChatRequest,
 					// agent map 未找到
 					(*output)[index] = fmt.Sprintf("The current agent %s toolset does not exist, try another tool",
 						reply.InterruptInfo.CurrentAgent)
+					toolErr = errors.New((*output)[index])
 					return
 				}
 				var f tool.Tool = nil
@@ -149,20 +316,46 @@ func (c *RunnerImp) RunTools(ctx context.Context, req *model.ChatRequest,
 					// tool name 未找到
 					(*output)[index] = fmt.Sprintf("Tool %s not found in currant agent %s's toolset, try another tool",
 						toolCall.Function.Name, reply.InterruptInfo.CurrentAgent)
+					toolErr = errors.New((*output)[index])
 					return
 				}
-				input := map[string]interface{}{}
-				err := json.Unmarshal([]byte(toolCall.Function.Arguments), &input)
+				args := map[string]interface{}{}
+				err := json.Unmarshal([]byte(toolCall.Function.Arguments), &args)
 				if err != nil {
 					// functional call 输出的函数参数有误
 					(*output)[index] = fmt.Sprintf("The parameters of the thinking process output are wrong, error: %v", err)
+					toolErr = errors.New((*output)[index])
 					return
 				}
-				// 用户自定义参数放到 tool input 中
+				var customVars map[string]string
 				if req != nil {
-					for k, v := range req.CustomVariables {
-						input[k] = v
+					customVars = req.CustomVariables
+				}
+				// schema 中必填的自定义参数不需要模型给出
+				if err := tool.ValidateCallArgs(f, args, customVars); err != nil {
+					// 参数不符合 schema，把详细的错误提交给模型修正
+					(*output)[index] = fmt.Sprintf("The arguments of tool %s are invalid, fix them and try again, "+
+						"error: %v", toolCall.Function.Name, err)
+					toolErr = fmt.Errorf("%w: %v", lkeerrors.ErrInvalidToolArgs, err)
+					if record != nil {
+						record.Input = args
+						record.Error = toolErr.Error()
 					}
+					return
+				}
+				if d := c.approve(ctx, reply.InterruptInfo.CurrentAgent, toolCall, args, input.Decisions); !d.Approved {
+					(*output)[index] = approval.RejectedOutput(toolCall.Function.Name, d.Reason)
+					toolErr = fmt.Errorf("%w: %s", lkeerrors.ErrToolRejected, d.Reason)
+					if record != nil {
+						record.Input = args
+						record.Rejected = d.Reason
+					}
+					return
+				}
+				input := args
+				// 用户自定义参数放到 tool input 中
+				for k, v := range customVars {
+					input[k] = v
 				}
 				toolCallCtx := eventhandler.ToolCallContext{
 					CallToolName: f.GetName(),
@@ -171,11 +364,46 @@ func (c *RunnerImp) RunTools(ctx context.Context, req *model.ChatRequest,
 				}
 				toolCallCtx.Extend = make(map[string]string)
 				toolCallCtx.Extend["agentname"] = reply.InterruptInfo.CurrentAgent
+				if c.logEnabled(ctx, slog.LevelDebug) {
+					c.log(ctx, slog.LevelDebug, "tool call", slog.String("input", c.redactor.JSON(input)))
+				}
 				// 调用工具前的钩子
 				c.runconf.EventHandler.BeforeToolCallHook(toolCallCtx)
-				toolout, err := c.RunWithTimeout(ctx, f, input)
+				toolCtx = tool.WithCallInfo(toolCtx, tool.CallInfo{
+					Tool:            f,
+					ToolName:        f.GetName(),
+					AgentName:       reply.InterruptInfo.CurrentAgent,
+					CallID:          toolCall.ID,
+					CustomVariables: customVars,
+				})
+				var attempts atomic.Int32
+				retryPolicy := tool.GetRetryPolicy(f)
+				execute := func(ctx context.Context, input map[string]interface{}) (interface{}, error) {
+					out, n, err := retryPolicy.Execute(ctx, f.Execute, input,
+						func(attempt int, err error, delay time.Duration) {
+							attempts.Store(int32(attempt))
+							c.log(ctx, slog.LevelWarn, "tool call failed, retrying",
+								slog.Int("attempt", attempt),
+								slog.Duration("delay", delay),
+								slog.String("error", err.Error()),
+							)
+						})
+					attempts.Store(int32(n))
+					return out, err
+				}
+				exec := c.runconf.Middlewares.Wrap(reply.InterruptInfo.CurrentAgent, f, execute)
+				toolout, err := c.runWithTimeout(toolCtx, f, exec, input)
 				toolCallCtx.Output = toolout
 				toolCallCtx.Err = err
+				toolCallCtx.Attempts = int(attempts.Load())
+				toolErr = err
+				if record != nil {
+					record.Attempts = toolCallCtx.Attempts
+					record.Input = input
+					if err != nil {
+						record.Error = err.Error()
+					}
+				}
 				c.runconf.EventHandler.AfterToolCallHook(toolCallCtx)
 				if err != nil {
 					(*output)[index] = fmt.Sprintf("Tool %s run failed, try another tool, error: %v",
@@ -212,10 +440,14 @@ func (c *RunnerImp) buildReq(query, requestID, sessionID, visitorBizID string, b
 	req.AgentConfig.Handoffs = c.handoffs
 	req.AgentConfig.DisableSystemOpt = !c.runconf.EnableSystemOpt
 	req.AgentConfig.StartAgentName = c.runconf.StartAgent
-	// 构建工具参数
-	for agentName, toolFuncMap := range c.toolsMap {
-		fmt.Printf("agentName: %s, toolFuncMap: %v\n", agentName, toolFuncMap)
-		if len(toolFuncMap) > 0 {
+	// 构建工具参数，按 agent 名字排序保证相同配置的请求内容相同
+	agentNames := make([]string, 0, len(c.toolsMap))
+	for agentName := range c.toolsMap {
+		agentNames = append(agentNames, agentName)
+	}
+	sort.Strings(agentNames)
+	for _, agentName := range agentNames {
+		if toolFuncMap := c.toolsMap[agentName]; len(toolFuncMap) > 0 {
 			agentTool := model.AgentTool{
 				AgentName: agentName,
 			}
@@ -229,11 +461,79 @@ func (c *RunnerImp) buildReq(query, requestID, sessionID, visitorBizID string, b
 	return req
 }
 
-func (c *RunnerImp) queryOnce(ctx context.Context, req *model.ChatRequest) (
+// queryOnce 调用一次云端接口，失败时按照重试策略使用同一个请求重试
+func (c *RunnerImp) queryOnce(ctx context.Context, req *model.ChatRequest, turn *Turn) (
+	finalReply *event.ReplyEvent, finalErr error) {
+	ctx, span := tracing.Tracer(ctx, c.runconf.TracerProvider).Start(ctx, tracing.SpanTurn,
+		trace.WithAttributes(attribute.Int(tracing.AttrTurnIndex, turn.Index)))
+	defer func() {
+		span.SetAttributes(attribute.Int(tracing.AttrTurnAttempts, turn.Attempts))
+		if turn.TraceID != "" {
+			span.SetAttributes(attribute.String(tracing.AttrServerTraceID, turn.TraceID))
+		}
+		if finalReply != nil && finalReply.RequestID != "" {
+			span.SetAttributes(attribute.String(tracing.AttrServerRequestID, finalReply.RequestID))
+		}
+		tracing.End(span, finalErr)
+	}()
+	policy := c.runconf.RetryPolicy
+	maxAttempts := policy.maxAttempts()
+	for attempt := 1; ; attempt++ {
+		turn.Attempts = attempt
+		finalReply, finalErr = c.queryAttempt(ctx, req, turn)
+		var streamErr *streamError
+		if errors.As(finalErr, &streamErr) {
+			// 已经下发过事件，重试会重复回调并重复记录回复和参考来源
+			return finalReply, streamErr.err
+		}
+		if finalErr == nil || attempt >= maxAttempts || ctx.Err() != nil ||
+			!policy.retryable(finalErr) {
+			return finalReply, finalErr
+		}
+		delay := policy.backoff(attempt)
+		c.reportRetry(ctx, req, attempt, maxAttempts, delay, finalErr)
+		if err := sleepWithContext(ctx, delay); err != nil {
+			return nil, finalErr
+		}
+	}
+}
+
+// reportRetry 把重试信息输出到日志和事件处理器
+func (c *RunnerImp) reportRetry(ctx context.Context, req *model.ChatRequest, attempt, maxAttempts int,
+	delay time.Duration, err error) {
+	c.log(ctx, slog.LevelWarn, "api call failed, retrying",
+		slog.Int("attempt", attempt),
+		slog.Int("max_attempts", maxAttempts),
+		slog.Duration("delay", delay),
+		slog.String("error", err.Error()),
+	)
+	handler, ok := c.runconf.EventHandler.(eventhandler.RetryHandler)
+	if !ok {
+		return
+	}
+	retry := &event.RetryEvent{
+		RequestID:   req.RequestID,
+		Attempt:     attempt,
+		MaxAttempts: maxAttempts,
+		DelayMs:     delay.Milliseconds(),
+		Err:         err,
+		ErrMsg:      err.Error(),
+	}
+	var apiErr *lkeerrors.APIError
+	if errors.As(err, &apiErr) {
+		retry.StatusCode = apiErr.HTTPStatus
+	}
+	retry.Extend.Extend = make(map[string]string)
+	retry.Extend.Extend["agentname"] = c.runconf.StartAgent
+	handler.OnRetry(retry)
+}
+
+// queryAttempt 调用一次云端接口并处理 sse 事件流
+func (c *RunnerImp) queryAttempt(ctx context.Context, req *model.ChatRequest, turn *Turn) (
 	finalReply *event.ReplyEvent, finalErr error) {
 	bs, _ := json.Marshal(req)
-	if c.runconf.Logger != nil {
-		c.runconf.Logger.Info(fmt.Sprintf("[lkesdk]api call, request: %s", string(bs)))
+	if c.logEnabled(ctx, slog.LevelDebug) {
+		c.log(ctx, slog.LevelDebug, "api call", slog.String("request", c.redactor.JSON(req)))
 	}
 	payload := bytes.NewReader(bs)
 	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, c.runconf.Endpoint, payload)
@@ -241,6 +541,7 @@ func (c *RunnerImp) queryOnce(ctx context.Context, req *model.ChatRequest) (
 		return nil, fmt.Errorf("NewRequestWithContext error: %v", err)
 	}
 	httpReq.Header.Set("Content-Type", "application/json")
+	tracing.Propagator(c.runconf.Propagator).Inject(ctx, propagation.HeaderCarrier(httpReq.Header))
 	if req.Options.EnvSet != "" {
 		httpReq.Header.Set("X-Qbot-EnvSet", req.Options.EnvSet)
 	} else {
@@ -248,27 +549,38 @@ func (c *RunnerImp) queryOnce(ctx context.Context, req *model.ChatRequest) (
 	}
 	res, err := c.runconf.HttpClient.Do(httpReq)
 	if err != nil {
-		return nil, fmt.Errorf("httpClient do request error: %v", err)
+		return nil, &transportError{msg: "httpClient do request error", err: err}
 	}
 	defer res.Body.Close() // don't forget!!
+	if res.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(res.Body, 4096))
+		return nil, &lkeerrors.APIError{
+			Message:    string(body),
+			RequestID:  req.RequestID,
+			HTTPStatus: res.StatusCode,
+		}
+	}
+	delivered := false
 	for ev, err := range sse.Read(res.Body, &sse.ReadConfig{
 		MaxEventSize: 10 * 1024 * 1024, // 10M buffer
 	}) {
-		if c.IsClosed.Load() {
-			return nil, fmt.Errorf("client has been closed")
-		}
 		if err != nil {
-			return nil, fmt.Errorf("sse.Read error: %v", err)
+			finalErr = &transportError{msg: "sse.Read error", err: err}
+			break
 		}
-		finalReply, finalErr = c.handlerEvent([]byte(ev.Data))
-	}
-	if c.runconf.Logger != nil {
+		finalReply, finalErr = c.handlerEvent([]byte(ev.Data), turn)
 		if finalErr != nil {
-			c.runconf.Logger.Error(fmt.Sprintf("[lkesdk]api final error: %v", finalErr))
-		} else {
-			bs, _ := json.Marshal(finalReply)
-			c.runconf.Logger.Info(fmt.Sprintf("[lkesdk]api final reply: %s", string(bs)))
+			break
 		}
+		delivered = true
+	}
+	if finalErr != nil {
+		c.log(ctx, slog.LevelError, "api call failed", slog.String("error", finalErr.Error()))
+	} else if c.logEnabled(ctx, slog.LevelDebug) {
+		c.log(ctx, slog.LevelDebug, "api final reply", slog.String("reply", c.redactor.JSON(finalReply)))
+	}
+	if finalErr != nil && delivered {
+		return nil, &streamError{err: finalErr}
 	}
 	return finalReply, finalErr
 }
@@ -277,24 +589,145 @@ func (c *RunnerImp) queryOnce(ctx context.Context, req *model.ChatRequest) (
 func (c *RunnerImp) RunWithContext(ctx context.Context,
 	query, requestID, sessionID, visitorBizID string,
 	options *model.Options) (finalReply *event.ReplyEvent, err error) {
+	result, err := c.RunWithResult(ctx, query, requestID, sessionID, visitorBizID, options)
+	return result.FinalReply, err
+}
+
+// RunWithResult 执行 agent，返回包括每轮请求、工具调用、回复和 token 用量的完整记录
+// 执行失败时同时返回已经完成部分的记录
+func (c *RunnerImp) RunWithResult(ctx context.Context,
+	query, requestID, sessionID, visitorBizID string,
+	options *model.Options) (result *RunResult, err error) {
 	req := c.buildReq(query, requestID, sessionID, visitorBizID, c.runconf.BotAppKey, options)
-	// c.runconf.Logger.Info(fmt.Sprintf("buildReq: %v", req))
-	for i := 0; i <= int(c.runconf.MaxToolTurns); i++ {
-		reply, err := c.queryOnce(ctx, req)
-		if err != nil {
-			return nil, err
+	var budget *model.Budget
+	if options != nil {
+		budget = options.Budget
+	}
+	return c.run(ctx, req, query, budget, func(ctx context.Context, result *RunResult) error {
+		result.suspend = options != nil && options.SuspendOnInterrupt
+		return c.loop(ctx, result, req, nil, ResumeInput{})
+	})
+}
+
+// run 创建执行记录，埋点和记录指标后执行 body，budget 不为空时覆盖配置的预算
+func (c *RunnerImp) run(ctx context.Context, req *model.ChatRequest, query string, budget *model.Budget,
+	body func(ctx context.Context, result *RunResult) error) (result *RunResult, err error) {
+	requestID, sessionID, visitorBizID := req.RequestID, req.SessionID, req.VisitorBizID
+	result = &RunResult{
+		RequestID:    requestID,
+		SessionID:    sessionID,
+		VisitorBizID: visitorBizID,
+		Query:        query,
+		StartTime:    time.Now(),
+		budget:       c.runconf.Budget,
+		sessionUsage: c.runconf.SessionUsage,
+		collector:    usage.FromContext(ctx).Child(c.runconf.SessionUsage),
+		parent:       resultFromContext(ctx),
+		prices:       c.runconf.PriceTable,
+		startAgent:   c.runconf.StartAgent,
+	}
+	// 嵌套执行的 agent 工具的用量同时累加到本次执行，并检查本次执行的预算
+	ctx = usage.WithCollector(ctx, result.collector)
+	ctx = withResult(ctx, result)
+	if budget != nil {
+		result.budget = budget
+		result.optsBudget = budget
+	}
+	recorder := c.metricsRecorder(ctx)
+	ctx = metrics.WithRecorder(ctx, recorder)
+	result.metrics = recorder
+	recorder.RunStarted(c.runconf.StartAgent)
+	ctx, span := tracing.Tracer(ctx, c.runconf.TracerProvider).Start(ctx, tracing.SpanRun,
+		trace.WithAttributes(
+			attribute.String(tracing.AttrRequestID, requestID),
+			attribute.String(tracing.AttrSessionID, sessionID),
+			attribute.String(tracing.AttrStartAgent, c.runconf.StartAgent),
+		))
+	defer func() {
+		result.finish(err)
+		span.SetAttributes(
+			attribute.Int(tracing.AttrTurns, len(result.Turns)),
+			attribute.Int64(tracing.AttrTokens, int64(result.Tokens())),
+		)
+		tracing.End(span, err)
+		recorder.RunFinished(c.runconf.StartAgent, runOutcome(ctx, err), metrics.ErrorClass(err),
+			result.toolTurns(), result.Elapsed)
+	}()
+	// 嵌套执行的 agent 工具从 ctx 中获取所属的对话
+	ctx = util.WithRunSession(ctx, util.RunSession{
+		RequestID:    requestID,
+		SessionID:    sessionID,
+		VisitorBizID: visitorBizID,
+	})
+	ctx = runlog.WithAttrs(ctx,
+		slog.String(runlog.KeyRunID, requestID),
+		slog.String(runlog.KeySessionID, sessionID),
+		slog.String(runlog.KeyAgent, c.runconf.StartAgent),
+	)
+	c.log(ctx, slog.LevelInfo, "run started", slog.String("query", query))
+	return result, body(ctx, result)
+}
+
+// loop 调用云端并执行本地工具直到收到最终回复，resume 不为空时从暂停的轮次继续，不再调用云端
+func (c *RunnerImp) loop(ctx context.Context, result *RunResult, req *model.ChatRequest,
+	resume *Pending, input ResumeInput) error {
+	first := 0
+	if resume != nil {
+		first = resume.Turn
+	}
+	for i := first; i <= int(c.runconf.MaxToolTurns); i++ {
+		turn := result.newTurn(i, req)
+		turnCtx := runlog.WithAttrs(ctx, slog.Int(runlog.KeyTurn, turn.Index))
+		var reply *event.ReplyEvent
+		turnInput := ResumeInput{}
+		if i == first && resume != nil {
+			reply, turnInput = resume.Reply, input
+		} else {
+			var err error
+			reply, err = c.queryOnce(turnCtx, req, turn)
+			if err == nil && reply == nil {
+				err = lkeerrors.ErrNoFinalReply
+			}
+			if err != nil {
+				turn.finish(err)
+				return err
+			}
+			if reply.ReplyMethod != event.ReplyMethodInterrupt {
+				turn.finish(nil)
+				result.FinalReply = reply
+				return nil
+			}
+		}
+		turn.InterruptInfo = reply.InterruptInfo
+		turn.charge()
+		// 继续执行的轮次不再因为 SuspendOnInterrupt 暂停
+		suspend := result.suspend && !(i == first && resume != nil)
+		// 作为工具的 agent 嵌套执行时不能单独暂停和继续，没有 Approver 时需要审批的调用直接拒绝
+		var approvals []approval.Request
+		if result.parent == nil {
+			approvals = c.pendingApprovals(reply, turnInput)
 		}
-		if reply == nil {
-			return nil, fmt.Errorf("no final reply from server")
+		if suspend || len(approvals) > 0 {
+			turn.finish(nil)
+			result.Pending = result.newPending(req, turn, reply, approvals)
+			c.log(turnCtx, slog.LevelInfo, "run paused", slog.Int("approvals", len(approvals)))
+			return errRunPaused(result.Pending)
 		}
-		if reply.ReplyMethod != event.ReplyMethodInterrupt {
-			return reply, err
+		// 执行本地工具之后还需要一轮提交工具输出，可能超过预算时不再执行本地工具
+		if err := result.checkNextTurn(); err != nil {
+			turn.finish(err)
+			return err
 		}
 		outputs := []string{}
 		if reply.InterruptInfo != nil {
 			outputs = make([]string, len(reply.InterruptInfo.ToolCalls))
 		}
-		c.RunTools(ctx, req, reply, &outputs)
+		c.runTools(turnCtx, req, reply, &outputs, turn, turnInput)
+		turn.finish(nil)
+		// 嵌套执行的 agent 工具和同一个对话中并发执行的用量可能已经超过预算
+		if err := result.checkNextTurn(); err != nil {
+			return err
+		}
 		req.ToolOuputs = nil
 		for i, out := range outputs {
 			req.ToolOuputs = append(req.ToolOuputs, model.ToolOuput{
@@ -303,22 +736,24 @@ func (c *RunnerImp) RunWithContext(ctx context.Context,
 			})
 		}
 	}
-	return nil, fmt.Errorf("reached maximum tool call turns")
+	return lkeerrors.ErrMaxToolTurns
 }
 
-func (c *RunnerImp) handlerEvent(data []byte) (finalReply *event.ReplyEvent, err error) {
+func (c *RunnerImp) handlerEvent(data []byte, turn *Turn) (finalReply *event.ReplyEvent, err error) {
 	defer func() {
 		if p := recover(); p != nil {
 		}
 	}()
 	ev := event.EventWrapper{}
 	_ = json.Unmarshal(data, &ev)
+	turn.result.recorder().SSEEvent(ev.Type)
 	switch ev.Type {
 	case event.EventError:
 		{
 			errEvent := event.ErrorEvent{}
 			json.Unmarshal(data, &errEvent)
-			err = fmt.Errorf("get error event: %s", string(data))
+			err = lkeerrors.NewAPIErrorFromEvent(&errEvent)
+			turn.setTraceID(errEvent.TraceId)
 			errEvent.Extend.Extend = make(map[string]string)
 			errEvent.Extend.Extend["agentname"] = c.runconf.StartAgent
 			c.runconf.EventHandler.OnError(&errEvent)
@@ -330,6 +765,7 @@ func (c *RunnerImp) handlerEvent(data []byte) (finalReply *event.ReplyEvent, err
 			json.Unmarshal(ev.Payload, &refer)
 			refer.Extend.Extend = make(map[string]string)
 			refer.Extend.Extend["agentname"] = c.runconf.StartAgent
+			turn.References = append(turn.References, &refer)
 			c.runconf.EventHandler.OnReference(&refer)
 			return nil, nil
 		}
@@ -339,6 +775,8 @@ func (c *RunnerImp) handlerEvent(data []byte) (finalReply *event.ReplyEvent, err
 			json.Unmarshal(ev.Payload, &thought)
 			thought.Extend.Extend = make(map[string]string)
 			thought.Extend.Extend["agentname"] = c.runconf.StartAgent
+			turn.Thought = &thought
+			turn.setTraceID(thought.TraceId)
 			c.runconf.EventHandler.OnThought(&thought)
 			return nil, nil
 		}
@@ -348,8 +786,13 @@ func (c *RunnerImp) handlerEvent(data []byte) (finalReply *event.ReplyEvent, err
 			json.Unmarshal(ev.Payload, &reply)
 			reply.Extend.Extend = make(map[string]string)
 			reply.Extend.Extend["agentname"] = c.runconf.StartAgent
+			turn.setTraceID(reply.TraceId)
+			if reply.ReplyMethod != event.ReplyMethodInterrupt {
+				turn.result.firstReply()
+			}
 			if reply.IsFinal {
 				finalReply = &reply
+				turn.Replies = append(turn.Replies, &reply)
 			}
 			if reply.ReplyMethod != event.ReplyMethodInterrupt {
 				c.runconf.EventHandler.OnReply(&reply)
@@ -362,7 +805,12 @@ func (c *RunnerImp) handlerEvent(data []byte) (finalReply *event.ReplyEvent, err
 			json.Unmarshal(ev.Payload, &tokenStat)
 			tokenStat.Extend.Extend = make(map[string]string)
 			tokenStat.Extend.Extend["agentname"] = c.runconf.StartAgent
+			turn.setTokenStat(&tokenStat)
+			turn.setTraceID(tokenStat.TraceId)
 			c.runconf.EventHandler.OnTokenStat(&tokenStat)
+			if err := turn.result.checkBudget(); err != nil {
+				return nil, err
+			}
 			return finalReply, nil
 		}
 	}
@@ -0,0 +1,156 @@
+package runner_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/tencent-lke/lke-sdk-go/event"
+	"github.com/tencent-lke/lke-sdk-go/eventhandler"
+	"github.com/tencent-lke/lke-sdk-go/lkeerrors"
+	"github.com/tencent-lke/lke-sdk-go/lketest"
+	"github.com/tencent-lke/lke-sdk-go/model"
+	"github.com/tencent-lke/lke-sdk-go/runner"
+	"github.com/tencent-lke/lke-sdk-go/tool"
+)
+
+type addParams struct {
+	A int `json:"a" doc:"number a"`
+	B int `json:"b" doc:"number b"`
+}
+
+// newTestRunner 创建请求 srv 的 runner，Agent-A 上注册了工具 add
+func newTestRunner(t *testing.T, srv *lketest.Server, calls *int32, conf runner.RunnerConf) *runner.RunnerImp {
+	add, err := tool.NewFunctionTool("add", "add two numbers", func(p addParams) int {
+		atomic.AddInt32(calls, 1)
+		return p.A + p.B
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conf.StartAgent = "Agent-A"
+	conf.Endpoint = srv.URL
+	conf.BotAppKey = "test-app-key"
+	conf.HttpClient = http.DefaultClient
+	conf.EventHandler = &eventhandler.DefaultEventHandler{}
+	if conf.MaxToolTurns == 0 {
+		conf.MaxToolTurns = 10
+	}
+	return runner.NewRunnerImp(
+		map[string][]tool.Tool{"Agent-A": {add}},
+		[]model.Agent{model.NewAgent("Agent-A", "agent a", "agent a", model.DefaultModel, nil, nil)},
+		nil, conf)
+}
+
+func run(r *runner.RunnerImp, query string) (*runner.RunResult, error) {
+	return r.RunWithResult(context.Background(), query, "request", "session", "visitor", nil)
+}
+
+func TestRunAPIError(t *testing.T) {
+	srv := lketest.NewServer(
+		lketest.NewTurn(lketest.Error(460004, "bot not found")),
+		lketest.StatusTurn(http.StatusTooManyRequests, "too many requests"),
+	)
+	defer srv.Close()
+	var calls int32
+	r := newTestRunner(t, srv, &calls, runner.RunnerConf{})
+
+	_, err := run(r, "hi")
+	var apiErr *lkeerrors.APIError
+	if !errors.As(err, &apiErr) || apiErr.Code != 460004 || apiErr.Message != "bot not found" {
+		t.Fatalf("except api error event, actual: %v", err)
+	}
+	if !errors.Is(err, lkeerrors.ErrInvalidParam) || errors.Is(err, lkeerrors.ErrServerUnavail) {
+		t.Fatalf("except error event classified as ErrInvalidParam, actual: %v", err)
+	}
+
+	_, err = run(r, "hi")
+	if !errors.Is(err, lkeerrors.ErrRateLimited) {
+		t.Fatalf("except ErrRateLimited, actual: %v", err)
+	}
+}
+
+func TestRunRetry(t *testing.T) {
+	srv := lketest.NewServer(
+		lketest.StatusTurn(http.StatusServiceUnavailable, "busy"),
+		lketest.NewTurn(lketest.Reply("ok")),
+	)
+	defer srv.Close()
+	var calls int32
+	r := newTestRunner(t, srv, &calls, runner.RunnerConf{
+		RetryPolicy: &runner.RetryPolicy{
+			MaxAttempts:     2,
+			InitialBackoff:  time.Millisecond,
+			RetryableStatus: []int{http.StatusServiceUnavailable},
+		},
+	})
+	result, err := run(r, "hi")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.FinalReply.Content != "ok" || result.Turns[0].Attempts != 2 || len(srv.Requests()) != 2 {
+		t.Fatalf("except retried reply ok, actual: %s, requests: %d",
+			result.FinalReply.Content, len(srv.Requests()))
+	}
+}
+
+func TestRunNoRetryAfterEvents(t *testing.T) {
+	srv := lketest.NewServer(
+		lketest.NewTurn(
+			lketest.Thought(event.AgentThoughtEvent{RecordID: "r1"}),
+			lketest.Error(500, "stream broken"),
+		),
+		lketest.NewTurn(lketest.Reply("ok")),
+	)
+	defer srv.Close()
+	var calls int32
+	r := newTestRunner(t, srv, &calls, runner.RunnerConf{
+		RetryPolicy: &runner.RetryPolicy{
+			MaxAttempts:    2,
+			InitialBackoff: time.Millisecond,
+			RetryableError: func(err error) bool { return true },
+		},
+	})
+	result, err := run(r, "hi")
+	var apiErr *lkeerrors.APIError
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("except APIError, actual: %v", err)
+	}
+	if len(srv.Requests()) != 1 || result.Turns[0].Attempts != 1 {
+		t.Fatalf("except no retry after events delivered, actual requests: %d", len(srv.Requests()))
+	}
+}
+
+type lineLogger struct {
+	mu   sync.Mutex
+	info []string
+}
+
+func (l *lineLogger) Info(message string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.info = append(l.info, message)
+}
+
+func (l *lineLogger) Error(message string) {}
+
+func TestRunDeprecatedLogger(t *testing.T) {
+	srv := lketest.NewServer(lketest.NewTurn(lketest.Reply("ok")))
+	defer srv.Close()
+	var calls int32
+	l := &lineLogger{}
+	r := newTestRunner(t, srv, &calls, runner.RunnerConf{Logger: l})
+	if _, err := run(r, "hi"); err != nil {
+		t.Fatal(err)
+	}
+	// 旧的 RunLogger 仍然输出 Debug 级别的请求日志
+	if !slices.ContainsFunc(l.info, func(line string) bool { return strings.HasPrefix(line, "[lkesdk]api call ") }) {
+		t.Fatalf("except api call logged by the deprecated logger, actual: %v", l.info)
+	}
+}
@@ -0,0 +1,419 @@
+package runner
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/tencent-lke/lke-sdk-go/lkeerrors"
+	"github.com/tencent-lke/lke-sdk-go/model"
+)
+
+// ValidationSeverity 配置问题的严重程度
+type ValidationSeverity string
+
+// 配置问题的严重程度
+const (
+	// SeverityError 一定会导致执行异常的配置
+	SeverityError ValidationSeverity = "error"
+	// SeverityWarning 可能是错误的配置，例如引用了本地不存在的 agent，该 agent 也可能是云上的 agent
+	SeverityWarning ValidationSeverity = "warning"
+)
+
+// ValidationIssue 一个配置问题
+type ValidationIssue struct {
+	Severity ValidationSeverity `json:"severity"`
+	Code     string             `json:"code"`            // 问题类型，例如 duplicate_tool
+	Agent    string             `json:"agent,omitempty"` // 相关的 agent
+	Tool     string             `json:"tool,omitempty"`  // 相关的工具
+	Message  string             `json:"message"`
+}
+
+// String 字符串化
+func (i ValidationIssue) String() string {
+	return fmt.Sprintf("[%s] %s: %s", i.Severity, i.Code, i.Message)
+}
+
+// 配置问题类型
+const (
+	IssueEmptyAgentName      = "empty_agent_name"
+	IssueDuplicateAgent      = "duplicate_agent"
+	IssueUnknownStartAgent   = "unknown_start_agent"
+	IssueInvalidHandoff      = "invalid_handoff"
+	IssueUnknownHandoffAgent = "unknown_handoff_agent"
+	IssueDuplicateHandoff    = "duplicate_handoff"
+	IssueUnknownToolAgent    = "unknown_tool_agent"
+	IssueInvalidTool         = "invalid_tool"
+	IssueDuplicateTool       = "duplicate_tool"
+	IssueInvalidToolName     = "invalid_tool_name"
+	IssueInvalidSchema       = "invalid_schema"
+	IssueUnreachableAgent    = "unreachable_agent"
+	IssueHandoffCycle        = "handoff_cycle"
+)
+
+// ValidationResult 配置校验结果
+type ValidationResult []ValidationIssue
+
+// HasErrors 是否存在 error 级别的问题
+func (r ValidationResult) HasErrors() bool {
+	for _, i := range r {
+		if i.Severity == SeverityError {
+			return true
+		}
+	}
+	return false
+}
+
+// Err 校验结果转换成 error，strict 为 true 时 warning 也视为错误，没有问题时返回 nil
+func (r ValidationResult) Err(strict bool) error {
+	if r.HasErrors() || (strict && len(r) > 0) {
+		return &ValidationError{Issues: r}
+	}
+	return nil
+}
+
+// ValidationError 配置校验失败，可以通过 errors.Is(err, lkeerrors.ErrInvalidConfig) 判断
+type ValidationError struct {
+	Issues ValidationResult
+}
+
+// Error 实现 error 接口
+func (e *ValidationError) Error() string {
+	msgs := make([]string, 0, len(e.Issues))
+	for _, i := range e.Issues {
+		msgs = append(msgs, i.String())
+	}
+	return fmt.Sprintf("%v: %s", lkeerrors.ErrInvalidConfig, strings.Join(msgs, "; "))
+}
+
+// Is 与 lkeerrors.ErrInvalidConfig 相等
+func (e *ValidationError) Is(target error) bool {
+	return target == lkeerrors.ErrInvalidConfig
+}
+
+// agentTool agent 作为工具时，可以获取对应的 agent
+type agentTool interface {
+	GetAgent() model.Agent
+}
+
+// toolNamePattern function call 工具名的合法格式
+var toolNamePattern = regexp.MustCompile(`^[a-zA-Z0-9_-]{1,64}$`)
+
+// Validate 在发送请求前校验 agent、handoff 和工具配置
+// 引用本地不存在的 agent 只会给出 warning，因为该 agent 可能是应用在云上配置的 agent
+func (c *RunnerImp) Validate() ValidationResult {
+	v := &validator{}
+	localAgents := map[string]bool{}
+	for _, a := range c.agents {
+		if a.Name == "" {
+			v.add(SeverityError, IssueEmptyAgentName, "", "", "agent name is empty")
+			continue
+		}
+		if localAgents[a.Name] {
+			v.add(SeverityError, IssueDuplicateAgent, a.Name, "",
+				fmt.Sprintf("agent %s is defined more than once", a.Name))
+		}
+		localAgents[a.Name] = true
+		v.checkSchema(a.Name, "", "input schema of agent "+a.Name, a.InputSchema)
+		v.checkSchema(a.Name, "", "output schema of agent "+a.Name, a.OutputSchema)
+	}
+
+	// 入口 agent 为空时从应用的主 agent 开始，是合法的配置
+	startAgent := c.runconf.StartAgent
+	if startAgent != "" && !localAgents[startAgent] {
+		v.add(SeverityWarning, IssueUnknownStartAgent, startAgent, "",
+			fmt.Sprintf("start agent %s is not a local agent, make sure it exists in the app", startAgent))
+	}
+
+	referenced := map[string]bool{startAgent: true}
+	graph := map[string][]string{}
+	seenHandoff := map[model.Handoff]bool{}
+	for _, h := range c.handoffs {
+		if h.SourceAgentName == "" || h.TargetAgentName == "" {
+			v.add(SeverityError, IssueInvalidHandoff, h.SourceAgentName, "",
+				fmt.Sprintf("handoff %s -> %s has an empty agent name", h.SourceAgentName, h.TargetAgentName))
+			continue
+		}
+		if h.SourceAgentName == h.TargetAgentName {
+			v.add(SeverityError, IssueInvalidHandoff, h.SourceAgentName, "",
+				fmt.Sprintf("agent %s hands off to itself", h.SourceAgentName))
+			continue
+		}
+		if seenHandoff[h] {
+			v.add(SeverityWarning, IssueDuplicateHandoff, h.SourceAgentName, "",
+				fmt.Sprintf("handoff %s -> %s is added more than once", h.SourceAgentName, h.TargetAgentName))
+			continue
+		}
+		seenHandoff[h] = true
+		for _, name := range []string{h.SourceAgentName, h.TargetAgentName} {
+			referenced[name] = true
+			if !localAgents[name] {
+				v.addOnce(SeverityWarning, IssueUnknownHandoffAgent, name, "",
+					fmt.Sprintf("handoff references agent %s which is not a local agent, "+
+						"make sure it exists in the app", name))
+			}
+		}
+		graph[h.SourceAgentName] = append(graph[h.SourceAgentName], h.TargetAgentName)
+	}
+
+	agentNames := make([]string, 0, len(c.toolsMap))
+	for agentName := range c.toolsMap {
+		agentNames = append(agentNames, agentName)
+	}
+	sort.Strings(agentNames)
+	asTools := map[string]bool{}
+	for _, agentName := range agentNames {
+		tools := c.toolsMap[agentName]
+		if !localAgents[agentName] && !referenced[agentName] {
+			v.add(SeverityWarning, IssueUnknownToolAgent, agentName, "",
+				fmt.Sprintf("tools are registered for agent %s which is neither a local agent "+
+					"nor referenced by handoffs, make sure it exists in the app", agentName))
+		}
+		names := map[string]bool{}
+		for i, t := range tools {
+			if t == nil {
+				v.add(SeverityError, IssueInvalidTool, agentName, "",
+					fmt.Sprintf("the %dth tool of agent %s is nil", i, agentName))
+				continue
+			}
+			name := t.GetName()
+			if name == "" {
+				v.add(SeverityError, IssueInvalidToolName, agentName, "",
+					fmt.Sprintf("the %dth tool of agent %s has an empty name", i, agentName))
+				continue
+			}
+			if !toolNamePattern.MatchString(name) {
+				v.add(SeverityWarning, IssueInvalidToolName, agentName, name,
+					fmt.Sprintf("tool name %s should match %s", name, toolNamePattern.String()))
+			}
+			if names[name] {
+				v.add(SeverityError, IssueDuplicateTool, agentName, name,
+					fmt.Sprintf("tool %s is registered more than once on agent %s", name, agentName))
+			}
+			names[name] = true
+			if at, ok := t.(agentTool); ok {
+				asTools[at.GetAgent().Name] = true
+			}
+			v.checkSchema(agentName, name, "parameters schema of tool "+name, t.GetParametersSchema())
+		}
+	}
+
+	if c.runconf.EnableSystemOpt {
+		if startAgent != "" {
+			// 入口 agent 为空时由应用的主 agent 转交，无法在本地判断是否可达
+			v.checkReachable(c.agents, startAgent, localAgents, asTools, graph)
+		}
+		v.checkCycles(graph)
+	}
+	return v.issues
+}
+
+// validator 收集配置问题
+type validator struct {
+	issues ValidationResult
+}
+
+func (v *validator) add(severity ValidationSeverity, code, agent, toolName, msg string) {
+	v.issues = append(v.issues, ValidationIssue{
+		Severity: severity,
+		Code:     code,
+		Agent:    agent,
+		Tool:     toolName,
+		Message:  msg,
+	})
+}
+
+// addOnce 同一个 agent 的同类问题只记录一次
+func (v *validator) addOnce(severity ValidationSeverity, code, agent, toolName, msg string) {
+	for _, i := range v.issues {
+		if i.Code == code && i.Agent == agent && i.Tool == toolName {
+			return
+		}
+	}
+	v.add(severity, code, agent, toolName, msg)
+}
+
+// checkReachable 检查本地 agent 是否能从入口 agent 或云上 agent 通过 handoff 到达
+func (v *validator) checkReachable(agents []model.Agent, startAgent string,
+	localAgents, asTools map[string]bool, graph map[string][]string) {
+	reached := map[string]bool{}
+	queue := []string{startAgent}
+	for source := range graph {
+		// 云上的 agent 可能由主 agent 转交，视为可达
+		if !localAgents[source] {
+			queue = append(queue, source)
+		}
+	}
+	for len(queue) > 0 {
+		name := queue[0]
+		queue = queue[1:]
+		if reached[name] {
+			continue
+		}
+		reached[name] = true
+		queue = append(queue, graph[name]...)
+	}
+	for _, a := range agents {
+		if a.Name == "" || reached[a.Name] || asTools[a.Name] {
+			continue
+		}
+		v.addOnce(SeverityWarning, IssueUnreachableAgent, a.Name, "",
+			fmt.Sprintf("agent %s is not reachable from the start agent through handoffs", a.Name))
+	}
+}
+
+// checkCycles 检查 handoff 中的环，开启系统优化时子 agent 会转回父 agent，环可能导致反复转交
+func (v *validator) checkCycles(graph map[string][]string) {
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	state := map[string]int{}
+	sources := make([]string, 0, len(graph))
+	for source := range graph {
+		sources = append(sources, source)
+	}
+	sort.Strings(sources)
+	var path []string
+	var visit func(name string)
+	visit = func(name string) {
+		state[name] = visiting
+		path = append(path, name)
+		for _, next := range graph[name] {
+			switch state[next] {
+			case unvisited:
+				visit(next)
+			case visiting:
+				start := 0
+				for i, n := range path {
+					if n == next {
+						start = i
+						break
+					}
+				}
+				cycle := append(append([]string{}, path[start:]...), next)
+				v.add(SeverityWarning, IssueHandoffCycle, next, "",
+					fmt.Sprintf("handoff cycle %s", strings.Join(cycle, " -> ")))
+			}
+		}
+		path = path[:len(path)-1]
+		state[name] = visited
+	}
+	for _, source := range sources {
+		if state[source] == unvisited {
+			visit(source)
+		}
+	}
+}
+
+// jsonSchemaTypes json schema 支持的类型
+var jsonSchemaTypes = map[string]bool{
+	"string": true, "number": true, "integer": true, "boolean": true,
+	"object": true, "array": true, "null": true,
+}
+
+// checkSchema 检查 json schema 的基本结构
+func (v *validator) checkSchema(agent, toolName, what string, schema map[string]interface{}) {
+	if schema == nil {
+		return
+	}
+	for _, problem := range schemaProblems("", schema) {
+		v.add(SeverityError, IssueInvalidSchema, agent, toolName, fmt.Sprintf("%s: %s", what, problem))
+	}
+}
+
+// schemaProblems 返回 schema 结构上的问题，path 为当前节点的路径
+func schemaProblems(path string, schema map[string]interface{}) []string {
+	at := func(msg string) string {
+		if path == "" {
+			return msg
+		}
+		return path + ": " + msg
+	}
+	var problems []string
+	switch t := schema["type"].(type) {
+	case nil:
+	case string:
+		if !jsonSchemaTypes[t] {
+			problems = append(problems, at(fmt.Sprintf("unknown type %q", t)))
+		}
+	case []interface{}:
+		for _, item := range t {
+			if s, ok := item.(string); !ok || !jsonSchemaTypes[s] {
+				problems = append(problems, at(fmt.Sprintf("unknown type %v", item)))
+			}
+		}
+	case []string:
+		for _, s := range t {
+			if !jsonSchemaTypes[s] {
+				problems = append(problems, at(fmt.Sprintf("unknown type %q", s)))
+			}
+		}
+	default:
+		problems = append(problems, at(fmt.Sprintf("type must be a string, got %T", t)))
+	}
+
+	var properties map[string]interface{}
+	if raw, ok := schema["properties"]; ok {
+		if properties, ok = raw.(map[string]interface{}); !ok {
+			problems = append(problems, at(fmt.Sprintf("properties must be an object, got %T", raw)))
+		}
+	}
+	names := make([]string, 0, len(properties))
+	for name := range properties {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		child, ok := properties[name].(map[string]interface{})
+		if !ok {
+			problems = append(problems, at(fmt.Sprintf("property %s must be an object", name)))
+			continue
+		}
+		problems = append(problems, schemaProblems(joinSchemaPath(path, name), child)...)
+	}
+
+	var required []string
+	switch r := schema["required"].(type) {
+	case nil:
+	case []string:
+		required = r
+	case []interface{}:
+		for _, item := range r {
+			s, ok := item.(string)
+			if !ok {
+				problems = append(problems, at(fmt.Sprintf("required must contain strings, got %T", item)))
+				continue
+			}
+			required = append(required, s)
+		}
+	default:
+		problems = append(problems, at(fmt.Sprintf("required must be an array, got %T", r)))
+	}
+	for _, name := range required {
+		if _, ok := properties[name]; !ok && properties != nil {
+			problems = append(problems, at(fmt.Sprintf("required property %s is not defined in properties", name)))
+		}
+	}
+
+	if raw, ok := schema["items"]; ok {
+		if items, ok := raw.(map[string]interface{}); ok {
+			problems = append(problems, schemaProblems(joinSchemaPath(path, "[]"), items)...)
+		} else {
+			problems = append(problems, at(fmt.Sprintf("items must be an object, got %T", raw)))
+		}
+	}
+	if items, ok := schema["additionalProperties"].(map[string]interface{}); ok {
+		problems = append(problems, schemaProblems(joinSchemaPath(path, "*"), items)...)
+	}
+	return problems
+}
+
+func joinSchemaPath(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
@@ -0,0 +1,132 @@
+package runner_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/tencent-lke/lke-sdk-go/lkeerrors"
+	"github.com/tencent-lke/lke-sdk-go/model"
+	"github.com/tencent-lke/lke-sdk-go/runner"
+	"github.com/tencent-lke/lke-sdk-go/tool"
+)
+
+func newTestTool(t *testing.T, name string) tool.Tool {
+	type Params struct {
+		A int `json:"a" doc:"number a"`
+	}
+	to, err := tool.NewFunctionTool(name, "test tool", func(p Params) int { return p.A }, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return to
+}
+
+func issueCodes(result runner.ValidationResult) map[string]runner.ValidationSeverity {
+	codes := map[string]runner.ValidationSeverity{}
+	for _, i := range result {
+		codes[i.Code] = i.Severity
+	}
+	return codes
+}
+
+func TestValidateOK(t *testing.T) {
+	agents := []model.Agent{
+		model.NewAgent("A", "agent a", "agent a", model.DefaultModel, nil, nil),
+		model.NewAgent("B", "agent b", "agent b", model.DefaultModel, nil, nil),
+	}
+	handoffs := []model.Handoff{{SourceAgentName: "A", TargetAgentName: "B"}}
+	toolsMap := map[string][]tool.Tool{"B": {newTestTool(t, "add")}}
+	r := runner.NewRunnerImp(toolsMap, agents, handoffs, runner.RunnerConf{
+		StartAgent:      "A",
+		EnableSystemOpt: true,
+	})
+	result := r.Validate()
+	if len(result) != 0 {
+		t.Fatalf("except no issues, actual: %v", result)
+	}
+	if err := result.Err(true); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestValidateEmptyStartAgent(t *testing.T) {
+	agents := []model.Agent{
+		model.NewAgent("A", "agent a", "agent a", model.DefaultModel, nil, nil),
+		model.NewAgent("B", "agent b", "agent b", model.DefaultModel, nil, nil),
+	}
+	toolsMap := map[string][]tool.Tool{"B": {newTestTool(t, "add")}}
+	// 入口 agent 为空时从应用的主 agent 开始，严格模式下也不应该报错
+	r := runner.NewRunnerImp(toolsMap, agents, nil, runner.RunnerConf{EnableSystemOpt: true})
+	result := r.Validate()
+	if len(result) != 0 {
+		t.Fatalf("except no issues, actual: %v", result)
+	}
+	if err := result.Err(true); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestValidateIssues(t *testing.T) {
+	agents := []model.Agent{
+		model.NewAgent("A", "agent a", "agent a", model.DefaultModel, nil, nil),
+		model.NewAgent("B", "agent b", "agent b", model.DefaultModel, nil,
+			map[string]interface{}{"type": "obj"}),
+		model.NewAgent("C", "agent c", "agent c", model.DefaultModel, nil, nil),
+	}
+	handoffs := []model.Handoff{
+		{SourceAgentName: "A", TargetAgentName: "B"},
+		{SourceAgentName: "B", TargetAgentName: "A"},
+		{SourceAgentName: "B", TargetAgentName: "Cloud"},
+	}
+	add := newTestTool(t, "add")
+	toolsMap := map[string][]tool.Tool{
+		"A":       {add, add},
+		"Unknown": {newTestTool(t, "sub")},
+	}
+	r := runner.NewRunnerImp(toolsMap, agents, handoffs, runner.RunnerConf{
+		StartAgent:      "A",
+		EnableSystemOpt: true,
+	})
+	result := r.Validate()
+	codes := issueCodes(result)
+	except := map[string]runner.ValidationSeverity{
+		runner.IssueInvalidSchema:       runner.SeverityError,
+		runner.IssueDuplicateTool:       runner.SeverityError,
+		runner.IssueUnknownHandoffAgent: runner.SeverityWarning,
+		runner.IssueUnknownToolAgent:    runner.SeverityWarning,
+		runner.IssueUnreachableAgent:    runner.SeverityWarning,
+		runner.IssueHandoffCycle:        runner.SeverityWarning,
+	}
+	for code, severity := range except {
+		if codes[code] != severity {
+			t.Errorf("except issue %s with severity %s, actual: %v", code, severity, result)
+		}
+	}
+	if len(codes) != len(except) {
+		t.Errorf("except %d kinds of issues, actual: %v", len(except), result)
+	}
+	err := result.Err(false)
+	if !errors.Is(err, lkeerrors.ErrInvalidConfig) {
+		t.Fatalf("except ErrInvalidConfig, actual: %v", err)
+	}
+	var validationErr *runner.ValidationError
+	if !errors.As(err, &validationErr) || len(validationErr.Issues) != len(result) {
+		t.Fatalf("except *runner.ValidationError with all issues, actual: %v", err)
+	}
+}
+
+func TestValidateStrict(t *testing.T) {
+	// 引用云上的 agent 只是 warning，严格模式下才会返回错误
+	handoffs := []model.Handoff{{SourceAgentName: "Cloud", TargetAgentName: "Cloud2"}}
+	r := runner.NewRunnerImp(map[string][]tool.Tool{}, nil, handoffs, runner.RunnerConf{})
+	result := r.Validate()
+	if len(result) == 0 || result.HasErrors() {
+		t.Fatalf("except only warnings, actual: %v", result)
+	}
+	if err := result.Err(false); err != nil {
+		t.Fatalf("except nil error when not strict, actual: %v", err)
+	}
+	if err := result.Err(true); !errors.Is(err, lkeerrors.ErrInvalidConfig) {
+		t.Fatalf("except ErrInvalidConfig when strict, actual: %v", err)
+	}
+}
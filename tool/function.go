@@ -18,6 +18,8 @@ type FunctionTool struct {
 	function    interface{}
 	schema      map[string]interface{}
 	timeout     time.Duration
+	retryPolicy *RetryPolicy
+	noValidate  bool
 }
 
 // NewFunctionTool creates a new function tool
@@ -89,6 +91,26 @@ func (m *FunctionTool) SetTimeout(t time.Duration) {
 	m.timeout = t
 }
 
+// GetRetryPolicy 获取重试策略
+func (m *FunctionTool) GetRetryPolicy() *RetryPolicy {
+	return m.retryPolicy
+}
+
+// SetRetryPolicy 设置重试策略，为空不重试
+func (m *FunctionTool) SetRetryPolicy(p *RetryPolicy) {
+	m.retryPolicy = p
+}
+
+// GetValidateArgs 执行前是否校验参数，默认校验
+func (m *FunctionTool) GetValidateArgs() bool {
+	return !m.noValidate
+}
+
+// SetValidateArgs 设置执行前是否校验参数
+func (m *FunctionTool) SetValidateArgs(validate bool) {
+	m.noValidate = !validate
+}
+
 // Execute executes the tool with the given parameters
 func (t *FunctionTool) Execute(ctx context.Context, params map[string]interface{}) (interface{}, error) {
 	fnType := reflect.TypeOf(t.function)
@@ -198,6 +220,24 @@ func convertToStruct(structValue reflect.Value, value map[string]interface{},
 		for j := 0; j < paramType.NumField(); j++ {
 			field := paramType.Field(j)
 
+			// 嵌入结构体的字段在 json 中展开到外层，嵌入的结构体指针为空时先分配
+			if embedded := embeddedStruct(field); embedded != nil {
+				fieldValue := structValue.Field(j)
+				if field.Type.Kind() == reflect.Ptr {
+					if !fieldValue.CanSet() {
+						continue
+					}
+					if fieldValue.IsNil() {
+						fieldValue.Set(reflect.New(embedded))
+					}
+					fieldValue = fieldValue.Elem()
+				}
+				if err := convertToStruct(fieldValue, value, embedded); err != nil {
+					return err
+				}
+				continue
+			}
+
 			// Get the JSON tag if available
 			jsonTag := field.Tag.Get("json")
 			if jsonTag == "" {
@@ -213,7 +253,7 @@ func convertToStruct(structValue reflect.Value, value map[string]interface{},
 				// Try to set the field
 				fieldValue := structValue.Field(j)
 				if fieldValue.CanSet() {
-					if field.Type.Kind() != reflect.Struct {
+					if field.Type.Kind() != reflect.Struct || isJSONUnmarshaler(field.Type) {
 						// Convert the parameter value to the field type
 						convertedValue, err := convertToType(paramValue, field.Type)
 						if err != nil {
@@ -231,6 +271,11 @@ func convertToStruct(structValue reflect.Value, value map[string]interface{},
 	return fmt.Errorf("paramType not struct")
 }
 
+// isJSONUnmarshaler 类型是否实现了 json.Unmarshaler
+func isJSONUnmarshaler(t reflect.Type) bool {
+	return reflect.PointerTo(t).Implements(reflect.TypeOf((*json.Unmarshaler)(nil)).Elem())
+}
+
 // convertToType attempts to convert a value to the specified type
 func convertToType(value interface{}, targetType reflect.Type) (interface{}, error) {
 	// Handle nil special case
@@ -238,6 +283,19 @@ func convertToType(value interface{}, targetType reflect.Type) (interface{}, err
 		return reflect.Zero(targetType).Interface(), nil
 	}
 
+	// time.Time、json.RawMessage 等自定义 json 解析的类型
+	if isJSONUnmarshaler(targetType) {
+		bs, err := json.Marshal(value)
+		if err != nil {
+			return nil, err
+		}
+		target := reflect.New(targetType)
+		if err := json.Unmarshal(bs, target.Interface()); err != nil {
+			return nil, fmt.Errorf("cannot convert %v to %v: %w", value, targetType, err)
+		}
+		return target.Elem().Interface(), nil
+	}
+
 	// Get the value's type
 	valueType := reflect.TypeOf(value)
 
@@ -398,172 +456,11 @@ func generateSchemaFromFunction(fnType reflect.Type) (map[string]interface{}, er
 	}
 
 	// If the parameter is a struct, create a schema from its fields
-	if paramType.Kind() == reflect.Struct {
-		for i := 0; i < paramType.NumField(); i++ {
-			field := paramType.Field(i)
-
-			// Skip unexported fields
-			if field.PkgPath != "" {
-				continue
-			}
-
-			// Get the field name from JSON tag or fallback to field name
-			fieldName := field.Name
-			jsonTag := field.Tag.Get("json")
-			if jsonTag != "" {
-				// Handle json tag options like `json:"name,omitempty"`
-				parts := strings.Split(jsonTag, ",")
-				fieldName = parts[0]
-
-				// Skip if the field is explicitly omitted with "-"
-				if fieldName == "-" {
-					continue
-				}
-
-				// Check if the field is required (not marked as omitempty)
-				isRequired := true
-				for _, part := range parts[1:] {
-					if part == "omitempty" {
-						isRequired = false
-						break
-					}
-				}
-
-				if isRequired {
-					schema["required"] = append(schema["required"].([]string), fieldName)
-				}
-			} else {
-				// If no JSON tag, assume it's required
-				schema["required"] = append(schema["required"].([]string), fieldName)
-			}
-
-			// Get the field schema
-			fieldSchema := getTypeSchema(field.Type)
-
-			// Add description from doc tag if available
-			if docTag := field.Tag.Get("doc"); docTag != "" {
-				fieldSchema["description"] = docTag
-			}
-
-			// Add the field to properties
-			schema["properties"].(map[string]interface{})[fieldName] = fieldSchema
-		}
-	} else {
+	if paramType.Kind() != reflect.Struct {
 		// For other parameter types, not supported
 		return nil, fmt.Errorf("unsupported function definition, please refer to the sdk documentation")
 	}
-
-	return schema, nil
-}
-
-// getTypeSchema returns the JSON schema for a Go type
-func getTypeSchema(t reflect.Type) map[string]interface{} {
-	schema := make(map[string]interface{})
-
-	// Handle pointers
-	if t.Kind() == reflect.Ptr {
-		elemSchema := getTypeSchema(t.Elem())
-
-		// For pointers, the field is nullable
-		if enum, ok := elemSchema["enum"]; ok {
-			// If the schema has enum values, add null to the enum
-			enumValues := enum.([]interface{})
-			enumValues = append(enumValues, nil)
-			elemSchema["enum"] = enumValues
-		}
-
-		return elemSchema
-	}
-
-	// Handle different types
-	switch t.Kind() {
-	case reflect.Bool:
-		schema["type"] = "boolean"
-
-	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
-		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
-		schema["type"] = "integer"
-
-	case reflect.Float32, reflect.Float64:
-		schema["type"] = "number"
-
-	case reflect.String:
-		schema["type"] = "string"
-
-	case reflect.Slice, reflect.Array:
-		schema["type"] = "array"
-		schema["items"] = getTypeSchema(t.Elem())
-
-	case reflect.Map:
-		schema["type"] = "object"
-		if t.Key().Kind() == reflect.String {
-			schema["additionalProperties"] = getTypeSchema(t.Elem())
-		} else {
-			// Non-string keyed maps are not well represented in JSON Schema
-			schema["additionalProperties"] = true
-		}
-
-	case reflect.Struct:
-		schema["type"] = "object"
-		schema["properties"] = make(map[string]interface{})
-		schema["required"] = []string{}
-
-		for i := 0; i < t.NumField(); i++ {
-			field := t.Field(i)
-
-			// Skip unexported fields
-			if field.PkgPath != "" {
-				continue
-			}
-
-			// Get the field name from JSON tag or fallback to field name
-			fieldName := field.Name
-			jsonTag := field.Tag.Get("json")
-			if jsonTag != "" {
-				// Handle json tag options like `json:"name,omitempty"`
-				parts := strings.Split(jsonTag, ",")
-				fieldName = parts[0]
-
-				// Skip if the field is explicitly omitted with "-"
-				if fieldName == "-" {
-					continue
-				}
-
-				// Check if the field is required (not marked as omitempty)
-				isRequired := true
-				for _, part := range parts[1:] {
-					if part == "omitempty" {
-						isRequired = false
-						break
-					}
-				}
-
-				if isRequired {
-					schema["required"] = append(schema["required"].([]string), fieldName)
-				}
-			} else {
-				// If no JSON tag, assume it's required
-				schema["required"] = append(schema["required"].([]string), fieldName)
-			}
-
-			// Get the field schema
-			fieldSchema := getTypeSchema(field.Type)
-
-			// Add description from doc tag if available
-			if docTag := field.Tag.Get("doc"); docTag != "" {
-				fieldSchema["description"] = docTag
-			}
-
-			// Add the field to properties
-			schema["properties"].(map[string]interface{})[fieldName] = fieldSchema
-		}
-
-	default:
-		// For unknown types, fallback to string
-		schema["type"] = "string"
-	}
-
-	return schema
+	return SchemaOf(paramType)
 }
 
 // WithSchema sets a custom schema for the tool parameters
@@ -1,12 +1,18 @@
 package tool_test
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
+	"flag"
 	"fmt"
+	"os"
+	"path/filepath"
 	"reflect"
 	"testing"
+	"time"
 
+	"github.com/tencent-lke/lke-sdk-go/jsonschema"
 	"github.com/tencent-lke/lke-sdk-go/tool"
 )
 
@@ -982,3 +988,198 @@ func TestFunctionExec11(t *testing.T) {
 		}
 	}
 }
+
+var update = flag.Bool("update", false, "update golden files")
+
+// assertGolden 比较 schema 和 testdata 中的 golden 文件，-update 时重新生成 golden 文件
+func assertGolden(t *testing.T, schema map[string]interface{}) {
+	t.Helper()
+	actual, err := json.MarshalIndent(schema, "", "  ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	golden := filepath.Join("testdata", t.Name()+".golden")
+	if *update {
+		if err := os.WriteFile(golden, append(actual, '\n'), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	except, err := os.ReadFile(golden)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(bytes.TrimSpace(except)) != string(actual) {
+		t.Fatalf("except schema: %s\n, actual schema:%s", except, actual)
+	}
+}
+
+func TestFunctionSchema12(t *testing.T) {
+	// jsonschema tag 中的约束
+	type Order struct {
+		Status   string   `json:"status" doc:"order status" jsonschema:"enum=paid,enum=shipped,default=paid"`
+		Count    int      `json:"count" jsonschema:"minimum=1,maximum=99,example=1,example=2"`
+		Price    float64  `json:"price" jsonschema:"exclusiveMinimum=0,multipleOf=0.01"`
+		Code     string   `json:"code" jsonschema:"minLength=3,maxLength=8,pattern=^[A-Z]{3}\\,?[0-9]*$"`
+		Email    string   `json:"email,omitempty" jsonschema:"format=email,deprecated"`
+		Homepage string   `json:"homepage,omitempty" jsonschema:"format=uri,title=Home page"`
+		Tags     []string `json:"tags,omitempty" jsonschema:"enum=red,enum=blue,minItems=1,uniqueItems"`
+		Level    *int     `json:"level,omitempty" jsonschema:"enum=1,enum=2"`
+	}
+	to, err := tool.NewFunctionTool("order", "create order", func(o Order) string { return o.Status }, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertGolden(t, to.GetParametersSchema())
+
+	type Bad struct {
+		Count int `json:"count" jsonschema:"minimum=one"`
+	}
+	if _, err := tool.NewFunctionTool("bad", "bad", func(b Bad) int { return b.Count }, nil); err == nil {
+		t.Fatal("except error for invalid jsonschema tag")
+	}
+}
+
+func TestFunctionSchema13(t *testing.T) {
+	// time.Time、json.RawMessage 和 value 有类型的 map
+	type Event struct {
+		At      time.Time               `json:"at" doc:"event time"`
+		Payload json.RawMessage         `json:"payload"`
+		Scores  map[string]float64      `json:"scores"`
+		ByID    map[int]string          `json:"by_id"`
+		Nested  map[string][]time.Time  `json:"nested,omitempty"`
+		Groups  map[string]*eventLabels `json:"groups,omitempty"`
+	}
+	to, err := tool.NewFunctionTool("event", "record event", func(e Event) string {
+		return e.At.Format(time.RFC3339) + string(e.Payload)
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertGolden(t, to.GetParametersSchema())
+
+	output, err := to.Execute(context.Background(), map[string]interface{}{
+		"at":      "2024-01-02T03:04:05Z",
+		"payload": map[string]interface{}{"a": float64(1)},
+	})
+	if err != nil || output != `2024-01-02T03:04:05Z{"a":1}` {
+		t.Fatalf("unexpected output: %v, err: %v", output, err)
+	}
+}
+
+type eventLabels struct {
+	Labels []string `json:"labels"`
+}
+
+type auditInfo struct {
+	Operator string `json:"operator" doc:"who made the change"`
+	Reason   string `json:"reason,omitempty"`
+}
+
+func TestFunctionSchema14(t *testing.T) {
+	// 嵌入结构体的字段展开到外层，外层的同名字段优先
+	type Update struct {
+		auditInfo
+		ID     int    `json:"id"`
+		Reason string `json:"reason" doc:"required reason"`
+	}
+	to, err := tool.NewFunctionTool("update", "update record", func(u Update) string {
+		return u.Operator + ":" + u.Reason
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertGolden(t, to.GetParametersSchema())
+
+	output, err := to.Execute(context.Background(), map[string]interface{}{
+		"id": float64(1), "operator": "admin", "reason": "fix",
+	})
+	if err != nil || output != "admin:fix" {
+		t.Fatalf("unexpected output: %v, err: %v", output, err)
+	}
+}
+
+func TestFunctionExecEmbeddedPointer(t *testing.T) {
+	// 嵌入的结构体指针在执行前分配，字段从外层的参数中读取
+	type Audit struct {
+		Operator string `json:"operator"`
+	}
+	type Update struct {
+		*Audit
+		ID int `json:"id"`
+	}
+	to, err := tool.NewFunctionTool("update", "update record", func(u Update) string {
+		if u.Audit == nil {
+			return "nil audit"
+		}
+		return fmt.Sprintf("%s:%d", u.Operator, u.ID)
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	output, err := to.Execute(context.Background(), map[string]interface{}{"id": float64(1), "operator": "admin"})
+	if err != nil || output != "admin:1" {
+		t.Fatalf("unexpected output: %v, err: %v", output, err)
+	}
+}
+
+type treeNode struct {
+	Name     string      `json:"name"`
+	Children []*treeNode `json:"children,omitempty"`
+	Owner    *person     `json:"owner,omitempty"`
+}
+
+type person struct {
+	Name    string    `json:"name"`
+	Manager *person   `json:"manager,omitempty"`
+	Team    *treeNode `json:"team,omitempty"`
+}
+
+func TestFunctionSchema15(t *testing.T) {
+	// 递归类型通过 $defs/$ref 引用
+	type Query struct {
+		Root  treeNode `json:"root"`
+		Depth int      `json:"depth,omitempty"`
+	}
+	to, err := tool.NewFunctionTool("tree", "walk tree", func(q Query) string { return q.Root.Name }, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	schema := to.GetParametersSchema()
+	assertGolden(t, schema)
+
+	args := map[string]interface{}{}
+	_ = json.Unmarshal([]byte(`{"root": {"name": "a", "children": [{"name": "b", "children": [{}]}]}}`), &args)
+	err = jsonschema.Validate(schema, args)
+	if err == nil || err.Error() != "$.root.children[0].children[0].name: required property is missing" {
+		t.Fatalf("unexpected validation error: %v", err)
+	}
+
+	// 参数本身是递归类型时根节点展开一层
+	root, err := tool.SchemaOf(reflect.TypeOf(treeNode{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if root["type"] != "object" || root["$defs"] == nil {
+		t.Fatalf("unexpected root schema: %v", root)
+	}
+}
+
+func TestRegisterFieldDescriptions(t *testing.T) {
+	type Weather struct {
+		City string `json:"city"`
+		Days int    `json:"days" doc:"天数"`
+	}
+	tool.RegisterFieldDescriptions[Weather](map[string]string{"City": "城市名", "Days": "查询天数"})
+	schema, err := tool.SchemaOf(reflect.TypeOf(Weather{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	props := schema["properties"].(map[string]interface{})
+	if d := props["city"].(map[string]interface{})["description"]; d != "城市名" {
+		t.Fatalf("unexpected city description: %v", d)
+	}
+	// doc tag 优先
+	if d := props["days"].(map[string]interface{})["description"]; d != "天数" {
+		t.Fatalf("unexpected days description: %v", d)
+	}
+}
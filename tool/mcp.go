@@ -4,17 +4,50 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"reflect"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/mark3labs/mcp-go/mcp"
 	"github.com/tencent-lke/lke-sdk-go/mcpserversse"
+	"github.com/tencent-lke/lke-sdk-go/metrics"
+	"github.com/tencent-lke/lke-sdk-go/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
-type mcpClientCache struct {
-	McpServerSse  *mcpserversse.McpServerSse
-	Data          map[string]mcp.Tool
-	OrderedName   []string
-	LastFetchTime time.Time
+// DefaultMcpToolsTTL 默认的 mcp 工具列表过期时间，收到 notifications/tools/list_changed 通知时立即刷新，
+// 过期刷新只是 mcp server 不支持通知或者通知丢失时的兜底
+const DefaultMcpToolsTTL = 30 * time.Second
+
+// McpToolsChange mcp server 工具列表的变化
+type McpToolsChange struct {
+	Added   []mcp.Tool // 新增的工具
+	Removed []string   // 删除的工具名
+	Updated []mcp.Tool // 描述或者参数发生变化的工具
+}
+
+// Empty 工具列表没有变化
+func (c McpToolsChange) Empty() bool {
+	return len(c.Added) == 0 && len(c.Removed) == 0 && len(c.Updated) == 0
+}
+
+// McpClientCache 一个 mcp server 的工具列表缓存，可以并发读取
+type McpClientCache struct {
+	Server mcpserversse.McpServer
+
+	mu             sync.RWMutex // 保护 data、orderedName、lastFetchTime、ttl、listeners 和 errorListeners
+	data           map[string]mcp.Tool
+	orderedName    []string
+	lastFetchTime  time.Time // 最近一次获取工具列表的时间，获取失败时也更新，过期后再重试
+	ttl            time.Duration
+	listeners      []func(change McpToolsChange)
+	errorListeners []func(err error)
+
+	refreshMu  sync.Mutex  // 串行执行刷新，保证变化按顺序计算
+	refreshing atomic.Bool // 是否有后台刷新在执行
+	pending    atomic.Bool // 是否有待执行的后台刷新
 }
 
 func replaceDefaultWithJson(m map[string]interface{}) error {
@@ -36,61 +69,181 @@ func replaceDefaultWithJson(m map[string]interface{}) error {
 	return nil
 }
 
-func (cache *mcpClientCache) GetParametersSchema(name string) map[string]interface{} {
-	cache.fetch()
-	schema := map[string]interface{}{}
-	if info, ok := cache.Data[name]; ok {
-		bs, _ := json.Marshal(info.InputSchema)
-		_ = json.Unmarshal(bs, &schema)
-		replaceDefaultWithJson(schema)
-		return schema
+// NewMcpClientCache 构建一个新的 mcp client cache，server 支持通知时订阅 notifications/tools/list_changed
+func NewMcpClientCache(server mcpserversse.McpServer) (*McpClientCache, error) {
+	cache := &McpClientCache{
+		Server:        server,
+		lastFetchTime: time.Now(),
+		data:          map[string]mcp.Tool{},
+		orderedName:   []string{},
+		ttl:           DefaultMcpToolsTTL,
 	}
-	return schema
+	rsp, err := ListMcpTools(server)
+	if err != nil {
+		return nil, fmt.Errorf("mcp client is list tools error: %v", err)
+	}
+	for _, tool := range rsp.Tools {
+		cache.data[tool.Name] = tool
+		cache.orderedName = append(cache.orderedName, tool.GetName())
+	}
+	if n, ok := server.(mcpserversse.NotifiableMcpServer); ok {
+		n.OnNotification(func(notification mcp.JSONRPCNotification) {
+			if notification.Method == mcp.MethodNotificationToolsListChanged {
+				// 回调在接收消息的协程中执行，同步获取工具列表会阻塞接收响应
+				cache.refreshAsync()
+			}
+		})
+	}
+	return cache, nil
+}
+
+// SetTTL 设置工具列表的过期时间，小于等于 0 时不按时间刷新，只在收到通知时刷新
+func (cache *McpClientCache) SetTTL(ttl time.Duration) {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+	cache.ttl = ttl
+}
+
+// OnChange 注册工具列表变化的回调，回调在刷新的协程中串行执行
+func (cache *McpClientCache) OnChange(f func(change McpToolsChange)) {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+	cache.listeners = append(cache.listeners, f)
+}
+
+// OnError 注册后台刷新失败的回调，回调在刷新的协程中串行执行
+func (cache *McpClientCache) OnError(f func(err error)) {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+	cache.errorListeners = append(cache.errorListeners, f)
+}
+
+// Tools 按 mcp server 返回的顺序获取当前的工具列表
+func (cache *McpClientCache) Tools() []mcp.Tool {
+	cache.mu.RLock()
+	defer cache.mu.RUnlock()
+	tools := make([]mcp.Tool, 0, len(cache.orderedName))
+	for _, name := range cache.orderedName {
+		tools = append(tools, cache.data[name])
+	}
+	return tools
 }
 
-// 构建一个新的 mcp client cache
-func NewMcpClientCache(mcpServerSse *mcpserversse.McpServerSse) (*mcpClientCache, error) {
-	cache := &mcpClientCache{
-		McpServerSse:  mcpServerSse,
-		LastFetchTime: time.Now(),
-		Data:          map[string]mcp.Tool{},
-		OrderedName:   []string{},
+// Tool 获取指定的工具，过期时在后台刷新，返回当前缓存的值
+func (cache *McpClientCache) Tool(name string) (mcp.Tool, bool) {
+	cache.mu.RLock()
+	info, ok := cache.data[name]
+	expired := cache.ttl > 0 && time.Since(cache.lastFetchTime) > cache.ttl
+	cache.mu.RUnlock()
+	if expired {
+		cache.refreshAsync()
 	}
-	rsp, err := ListMcpTools(mcpServerSse)
+	return info, ok
+}
+
+// Refresh 重新获取工具列表，有变化时回调 OnChange 注册的函数
+// 获取失败时保留当前的工具列表，过期时间重新计算，避免 server 不可用时每次读取都触发刷新
+func (cache *McpClientCache) Refresh() error {
+	cache.refreshMu.Lock()
+	defer cache.refreshMu.Unlock()
+	rsp, err := ListMcpTools(cache.Server)
 	if err != nil {
-		return nil, fmt.Errorf("mcp client is list tools error: %v", err)
+		cache.mu.Lock()
+		cache.lastFetchTime = time.Now()
+		cache.mu.Unlock()
+		return err
 	}
+	data := make(map[string]mcp.Tool, len(rsp.Tools))
+	orderedName := make([]string, 0, len(rsp.Tools))
+	var change McpToolsChange
+	cache.mu.Lock()
 	for _, tool := range rsp.Tools {
-		cache.Data[tool.Name] = tool
-		cache.OrderedName = append(cache.OrderedName, tool.GetName())
+		if _, ok := data[tool.Name]; ok {
+			continue
+		}
+		data[tool.Name] = tool
+		orderedName = append(orderedName, tool.Name)
+		old, ok := cache.data[tool.Name]
+		if !ok {
+			change.Added = append(change.Added, tool)
+		} else if !reflect.DeepEqual(old, tool) {
+			change.Updated = append(change.Updated, tool)
+		}
 	}
-	return cache, nil
+	for _, name := range cache.orderedName {
+		if _, ok := data[name]; !ok {
+			change.Removed = append(change.Removed, name)
+		}
+	}
+	cache.data = data
+	cache.orderedName = orderedName
+	cache.lastFetchTime = time.Now()
+	listeners := append([]func(McpToolsChange){}, cache.listeners...)
+	cache.mu.Unlock()
+	if !change.Empty() {
+		for _, f := range listeners {
+			f(change)
+		}
+	}
+	return nil
 }
 
-func (cache *mcpClientCache) fetch() {
-	defer func() {
-		if p := recover(); p != nil {
-			return
+// refreshAsync 在后台刷新，正在刷新时合并成刷新结束后再执行一次
+func (cache *McpClientCache) refreshAsync() {
+	cache.pending.Store(true)
+	if !cache.refreshing.CompareAndSwap(false, true) {
+		return
+	}
+	go func() {
+		defer func() {
+			cache.refreshing.Store(false)
+			// 检查 pending 和结束刷新之间可能有新的请求
+			if cache.pending.Load() {
+				cache.refreshAsync()
+			}
+		}()
+		for cache.pending.Swap(false) {
+			if err := cache.safeRefresh(); err != nil {
+				cache.mu.RLock()
+				listeners := append([]func(error){}, cache.errorListeners...)
+				cache.mu.RUnlock()
+				for _, f := range listeners {
+					f(err)
+				}
+			}
 		}
 	}()
-	// 2s 刷新一次
-	if time.Now().After(cache.LastFetchTime.Add(2 * time.Second)) {
-		rsp, err := ListMcpTools(cache.McpServerSse)
-		if err != nil {
-			return
-		}
-		cache.LastFetchTime = time.Now()
-		for _, tool := range rsp.Tools {
-			cache.Data[tool.Name] = tool
+}
+
+// safeRefresh 执行 Refresh，OnChange 回调 panic 时转换为错误
+func (cache *McpClientCache) safeRefresh() (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("mcp tools refresh panic: %v", r)
 		}
+	}()
+	return cache.Refresh()
+}
+
+func (cache *McpClientCache) GetParametersSchema(name string) map[string]interface{} {
+	schema := map[string]interface{}{}
+	if info, ok := cache.Tool(name); ok {
+		bs, _ := json.Marshal(info.InputSchema)
+		_ = json.Unmarshal(bs, &schema)
+		replaceDefaultWithJson(schema)
+		return schema
 	}
+	return schema
 }
 
 // McpTool ...
 type McpTool struct {
-	Name    string
-	Cache   *mcpClientCache
-	Timeout time.Duration
+	Name        string
+	Cache       *McpClientCache
+	Timeout     time.Duration
+	RetryPolicy *RetryPolicy // 执行失败时的重试策略，为空不重试
+	// SkipValidation 执行前不校验参数
+	SkipValidation bool
 }
 
 // GetName returns the name of the tool
@@ -98,9 +251,8 @@ func (m *McpTool) GetName() string {
 	return m.Name
 }
 
-func (cache *mcpClientCache) GetDescription(name string) string {
-	cache.fetch()
-	if info, ok := cache.Data[name]; ok {
+func (cache *McpClientCache) GetDescription(name string) string {
+	if info, ok := cache.Tool(name); ok {
 		return info.Description
 	}
 	return ""
@@ -123,54 +275,57 @@ func (m *McpTool) GetParametersSchema() map[string]interface{} {
 }
 
 // Execute executes the tool with the given parameter
-func (m *McpTool) Execute(ctx context.Context, params map[string]interface{}) (interface{}, error) {
+func (m *McpTool) Execute(ctx context.Context, params map[string]interface{}) (result interface{}, err error) {
+	ctx, span := tracing.Tracer(ctx, nil).Start(ctx, tracing.SpanMcpCallTool,
+		trace.WithAttributes(attribute.String(tracing.AttrToolName, m.Name)))
+	defer func() {
+		tracing.End(span, err)
+	}()
 	req := mcp.CallToolRequest{}
 	req.Params.Name = m.Name
 	req.Params.Arguments = params
 	toolCtx, toolCancel := context.WithTimeout(context.Background(), 2*time.Second)
 	defer toolCancel()
-	errp := m.Cache.McpServerSse.Ping(toolCtx)
+	errp := m.Cache.Server.Ping(toolCtx)
 	if errp != nil {
-		if errr := m.Cache.McpServerSse.ReConnect(); errr != nil {
+		errr := m.Cache.Server.ReConnect()
+		metrics.FromContext(ctx).McpReconnect(m.Name, errr)
+		if errr != nil {
 			return nil, fmt.Errorf("mcp client ping error: %v, reconnect error: %v", errp, errr)
 		}
 	}
-	result, err := m.Cache.McpServerSse.CallTool(ctx, req)
+	res, err := m.Cache.Server.CallTool(ctx, req)
 	if err != nil {
 		return nil, err
 	}
-	return result, nil
+	return res, nil
 }
 
-// ListMcpTools 获取 mcp 工具列表
-func ListMcpTools(mcpserver *mcpserversse.McpServerSse) (res *mcp.ListToolsResult, err error) {
-	ctx := context.Background()
-	runCtx, cancel := context.WithCancel(ctx)
+// ListMcpTools 获取 mcp 工具列表，5s 超时
+func ListMcpTools(mcpserver mcpserversse.McpServer) (*mcp.ListToolsResult, error) {
+	type listResult struct {
+		res *mcp.ListToolsResult
+		err error
+	}
+	runCtx, cancel := context.WithCancel(context.Background())
 	t := time.NewTimer(5 * time.Second)
+	defer t.Stop()
 	defer cancel()
-	signal := make(chan struct{})
+	// 超时返回后协程仍然可以写入，不会阻塞
+	done := make(chan listResult, 1)
 	go func() {
-		defer func() {
-			select {
-			case <-runCtx.Done():
-				return
-			case signal <- struct{}{}:
-			}
-		}()
-		if err = mcpserver.Ping(ctx); err != nil {
+		if err := mcpserver.Ping(runCtx); err != nil {
+			done <- listResult{err: err}
 			return
 		}
-		toolsRequest := mcp.ListToolsRequest{}
-		res, err = mcpserver.ListTools(runCtx, toolsRequest)
+		res, err := mcpserver.ListTools(runCtx, mcp.ListToolsRequest{})
+		done <- listResult{res: res, err: err}
 	}()
-	for {
-		select {
-		case <-t.C:
-			err = fmt.Errorf("ListMcpTools timeout")
-			return nil, err
-		case <-signal:
-			return res, err
-		}
+	select {
+	case <-t.C:
+		return nil, fmt.Errorf("ListMcpTools timeout")
+	case r := <-done:
+		return r.res, r.err
 	}
 }
 
@@ -178,7 +333,12 @@ func ListMcpTools(mcpserver *mcpserversse.McpServerSse) (res *mcp.ListToolsResul
 func (m *McpTool) ResultToString(output interface{}) string {
 	result, ok := output.(*mcp.CallToolResult)
 	if !ok {
-		return ""
+		// 中间件替换的输出
+		if output == nil {
+			return ""
+		}
+		str, _ := InterfaceToString(output)
+		return str
 	}
 	totalResult := []string{}
 	for _, content := range result.Content {
@@ -206,3 +366,23 @@ func (m *McpTool) GetTimeout() time.Duration {
 func (m *McpTool) SetTimeout(t time.Duration) {
 	m.Timeout = t
 }
+
+// GetRetryPolicy 获取重试策略
+func (m *McpTool) GetRetryPolicy() *RetryPolicy {
+	return m.RetryPolicy
+}
+
+// SetRetryPolicy 设置重试策略，为空不重试
+func (m *McpTool) SetRetryPolicy(p *RetryPolicy) {
+	m.RetryPolicy = p
+}
+
+// GetValidateArgs 执行前是否校验参数，默认校验
+func (m *McpTool) GetValidateArgs() bool {
+	return !m.SkipValidation
+}
+
+// SetValidateArgs 设置执行前是否校验参数
+func (m *McpTool) SetValidateArgs(validate bool) {
+	m.SkipValidation = !validate
+}
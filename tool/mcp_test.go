@@ -0,0 +1,207 @@
+package tool_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/tencent-lke/lke-sdk-go/mcpserversse"
+	"github.com/tencent-lke/lke-sdk-go/tool"
+)
+
+func echoHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	return mcp.NewToolResultText("ok"), nil
+}
+
+// waitChange 等待工具列表变化
+func waitChange(t *testing.T, changes <-chan tool.McpToolsChange) tool.McpToolsChange {
+	t.Helper()
+	select {
+	case change := <-changes:
+		return change
+	case <-time.After(5 * time.Second):
+		t.Fatal("wait tools change timeout")
+	}
+	return tool.McpToolsChange{}
+}
+
+func TestMcpClientCacheListChanged(t *testing.T) {
+	s := server.NewMCPServer("test-server", "1.0.0", server.WithToolCapabilities(true))
+	s.AddTool(mcp.NewTool("echo", mcp.WithDescription("echo")), echoHandler)
+	sseServer := server.NewTestServer(s)
+	defer sseServer.Close()
+	conn, err := mcpserversse.NewMcpServer(mcpserversse.SSETransport(sseServer.URL+"/sse"), mcp.InitializeRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	cache, err := tool.NewMcpClientCache(conn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cache.SetTTL(0) // 只通过通知刷新
+	changes := make(chan tool.McpToolsChange, 10)
+	cache.OnChange(func(change tool.McpToolsChange) { changes <- change })
+
+	s.AddTool(mcp.NewTool("time", mcp.WithDescription("current time")), echoHandler)
+	change := waitChange(t, changes)
+	if len(change.Added) != 1 || change.Added[0].Name != "time" || len(change.Removed) != 0 {
+		t.Fatalf("unexpected change: %+v", change)
+	}
+	if d := cache.GetDescription("time"); d != "current time" {
+		t.Fatalf("unexpected description: %s", d)
+	}
+
+	s.DeleteTools("echo")
+	change = waitChange(t, changes)
+	if len(change.Removed) != 1 || change.Removed[0] != "echo" || len(change.Added) != 0 {
+		t.Fatalf("unexpected change: %+v", change)
+	}
+	if tools := cache.Tools(); len(tools) != 1 || tools[0].Name != "time" {
+		t.Fatalf("unexpected tools: %+v", tools)
+	}
+}
+
+func TestMcpClientCacheTTL(t *testing.T) {
+	s := server.NewMCPServer("test-server", "1.0.0")
+	s.AddTool(mcp.NewTool("echo", mcp.WithDescription("echo")), echoHandler)
+	conn, err := mcpserversse.NewMcpServer(mcpserversse.InProcessTransport(s), mcp.InitializeRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cache, err := tool.NewMcpClientCache(conn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	changes := make(chan tool.McpToolsChange, 10)
+	cache.OnChange(func(change tool.McpToolsChange) { changes <- change })
+	cache.SetTTL(time.Millisecond)
+	// 进程内的 server 不发送通知，过期后读取时在后台刷新
+	s.AddTool(mcp.NewTool("echo", mcp.WithDescription("echo the text")), echoHandler)
+	time.Sleep(2 * time.Millisecond)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_ = cache.GetDescription("echo")
+			_ = cache.GetParametersSchema("echo")
+		}()
+	}
+	wg.Wait()
+	change := waitChange(t, changes)
+	if len(change.Updated) != 1 || change.Updated[0].Description != "echo the text" {
+		t.Fatalf("unexpected change: %+v", change)
+	}
+	if d := cache.GetDescription("echo"); d != "echo the text" {
+		t.Fatalf("unexpected description: %s", d)
+	}
+}
+
+// flakyMcpServer ListTools 可以失败的 mcp server
+type flakyMcpServer struct {
+	mcpserversse.McpServer
+	fail  atomic.Bool
+	lists atomic.Int32
+}
+
+func (s *flakyMcpServer) ListTools(ctx context.Context,
+	request mcp.ListToolsRequest) (*mcp.ListToolsResult, error) {
+	s.lists.Add(1)
+	if s.fail.Load() {
+		return nil, errors.New("server down")
+	}
+	return s.McpServer.ListTools(ctx, request)
+}
+
+// waitError 等待后台刷新失败
+func waitError(t *testing.T, errs <-chan error) error {
+	t.Helper()
+	select {
+	case err := <-errs:
+		return err
+	case <-time.After(5 * time.Second):
+		t.Fatal("wait refresh error timeout")
+	}
+	return nil
+}
+
+func TestMcpClientCacheRefreshError(t *testing.T) {
+	s := server.NewMCPServer("test-server", "1.0.0")
+	s.AddTool(mcp.NewTool("echo", mcp.WithDescription("echo")), echoHandler)
+	conn, err := mcpserversse.NewMcpServer(mcpserversse.InProcessTransport(s), mcp.InitializeRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	flaky := &flakyMcpServer{McpServer: conn}
+	cache, err := tool.NewMcpClientCache(flaky)
+	if err != nil {
+		t.Fatal(err)
+	}
+	errs := make(chan error, 10)
+	cache.OnError(func(err error) { errs <- err })
+	cache.OnChange(func(change tool.McpToolsChange) { panic("listener failed") })
+	ttl := 200 * time.Millisecond
+	cache.SetTTL(ttl)
+
+	// 刷新失败时报告错误，保留当前的工具列表，过期前不再刷新
+	flaky.fail.Store(true)
+	time.Sleep(ttl + 10*time.Millisecond)
+	if d := cache.GetDescription("echo"); d != "echo" {
+		t.Fatalf("unexpected description: %s", d)
+	}
+	if err := waitError(t, errs); !strings.Contains(err.Error(), "server down") {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for i := 0; i < 10; i++ {
+		_ = cache.GetDescription("echo")
+	}
+	time.Sleep(20 * time.Millisecond)
+	if n := flaky.lists.Load(); n != 2 {
+		t.Fatalf("except no refresh before ttl after failure, actual list calls: %d", n)
+	}
+
+	// 回调 panic 时作为错误报告
+	flaky.fail.Store(false)
+	s.AddTool(mcp.NewTool("time", mcp.WithDescription("current time")), echoHandler)
+	time.Sleep(ttl + 10*time.Millisecond)
+	_ = cache.GetDescription("echo")
+	if err := waitError(t, errs); !strings.Contains(err.Error(), "listener failed") {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if d := cache.GetDescription("time"); d != "current time" {
+		t.Fatalf("unexpected description: %s", d)
+	}
+}
+
+// slowMcpServer ListTools 直到 ctx 取消才返回
+type slowMcpServer struct {
+	mcpserversse.McpServer
+}
+
+func (slowMcpServer) Ping(ctx context.Context) error {
+	return nil
+}
+
+func (slowMcpServer) ListTools(ctx context.Context, request mcp.ListToolsRequest) (*mcp.ListToolsResult, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func TestListMcpToolsTimeout(t *testing.T) {
+	if testing.Short() {
+		t.Skip("waits for the list timeout")
+	}
+	// 超时返回后协程仍然会写入结果，使用 -race 检查
+	if _, err := tool.ListMcpTools(slowMcpServer{}); err == nil || !strings.Contains(err.Error(), "timeout") {
+		t.Fatalf("except timeout, actual: %v", err)
+	}
+	time.Sleep(10 * time.Millisecond)
+}
@@ -0,0 +1,101 @@
+package tool
+
+import "context"
+
+// ToolFunc 执行工具的函数，和 Tool.Execute 的签名相同
+type ToolFunc func(ctx context.Context, input map[string]interface{}) (interface{}, error)
+
+// Middleware 工具中间件，包装 next 实现鉴权、参数处理、缓存、输出处理等通用逻辑
+// 中间件可以修改 input 后调用 next，替换 next 的输出，也可以不调用 next 直接返回
+// 替换的输出需要能被工具的 ResultToString 转换，mcp 工具的输出可以是 *mcp.CallToolResult 或者其他类型
+type Middleware func(next ToolFunc) ToolFunc
+
+// Chain 把中间件按顺序包装到 f 上，第一个中间件在最外层
+func Chain(f ToolFunc, middlewares ...Middleware) ToolFunc {
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		if middlewares[i] != nil {
+			f = middlewares[i](f)
+		}
+	}
+	return f
+}
+
+// Middlewares 按作用范围注册的中间件
+type Middlewares struct {
+	Global []Middleware            // 所有工具
+	Agents map[string][]Middleware // agent 名称 -> 该 agent 的所有工具
+	Tools  map[string][]Middleware // 工具名称 -> 该工具
+}
+
+// Use 注册所有工具的中间件
+func (m *Middlewares) Use(middlewares ...Middleware) {
+	m.Global = append(m.Global, middlewares...)
+}
+
+// UseForAgent 注册 agent 的所有工具的中间件
+func (m *Middlewares) UseForAgent(agent string, middlewares ...Middleware) {
+	if m.Agents == nil {
+		m.Agents = map[string][]Middleware{}
+	}
+	m.Agents[agent] = append(m.Agents[agent], middlewares...)
+}
+
+// UseForTool 注册指定工具的中间件
+func (m *Middlewares) UseForTool(tool string, middlewares ...Middleware) {
+	if m.Tools == nil {
+		m.Tools = map[string][]Middleware{}
+	}
+	m.Tools[tool] = append(m.Tools[tool], middlewares...)
+}
+
+// Clone 复制中间件，复制后注册的中间件互不影响
+func (m *Middlewares) Clone() *Middlewares {
+	c := &Middlewares{}
+	if m == nil {
+		return c
+	}
+	c.Global = append(c.Global, m.Global...)
+	for agent, mws := range m.Agents {
+		c.UseForAgent(agent, mws...)
+	}
+	for tool, mws := range m.Tools {
+		c.UseForTool(tool, mws...)
+	}
+	return c
+}
+
+// Wrap 把作用于 agent 的工具 t 的中间件包装到 f 上，顺序为全局、agent、工具，全局的在最外层
+func (m *Middlewares) Wrap(agent string, t Tool, f ToolFunc) ToolFunc {
+	if m == nil {
+		return f
+	}
+	mws := make([]Middleware, 0, len(m.Global)+len(m.Agents[agent])+len(m.Tools[t.GetName()]))
+	mws = append(mws, m.Global...)
+	mws = append(mws, m.Agents[agent]...)
+	mws = append(mws, m.Tools[t.GetName()]...)
+	return Chain(f, mws...)
+}
+
+// CallInfo 当前工具调用的信息，中间件可以通过 CallInfoFromContext 获取
+type CallInfo struct {
+	Tool      Tool
+	ToolName  string
+	AgentName string
+	CallID    string
+	// CustomVariables 合并到工具输入中的自定义参数
+	CustomVariables map[string]string
+}
+
+// callInfoKey context 中保存 CallInfo 的 key
+type callInfoKey struct{}
+
+// WithCallInfo 把工具调用的信息放到 context 中
+func WithCallInfo(ctx context.Context, info CallInfo) context.Context {
+	return context.WithValue(ctx, callInfoKey{}, info)
+}
+
+// CallInfoFromContext 获取 context 中的工具调用信息
+func CallInfoFromContext(ctx context.Context) (CallInfo, bool) {
+	info, ok := ctx.Value(callInfoKey{}).(CallInfo)
+	return info, ok
+}
@@ -0,0 +1,62 @@
+package tool_test
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/tencent-lke/lke-sdk-go/tool"
+)
+
+func TestMiddlewaresWrap(t *testing.T) {
+	add, err := tool.NewFunctionTool("add", "add two numbers", func(p struct {
+		A int `json:"a"`
+		B int `json:"b"`
+	}) int {
+		return p.A + p.B
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	order := []string{}
+	trace := func(name string) tool.Middleware {
+		return func(next tool.ToolFunc) tool.ToolFunc {
+			return func(ctx context.Context, input map[string]interface{}) (interface{}, error) {
+				order = append(order, name)
+				return next(ctx, input)
+			}
+		}
+	}
+	m := &tool.Middlewares{}
+	m.UseForTool("add", trace("tool"), func(next tool.ToolFunc) tool.ToolFunc {
+		return func(ctx context.Context, input map[string]interface{}) (interface{}, error) {
+			input["b"] = 10
+			return next(ctx, input)
+		}
+	})
+	m.UseForAgent("Agent-A", trace("agent"))
+	m.Use(trace("global"))
+	m.UseForTool("other", trace("other"))
+
+	clone := m.Clone()
+	clone.Use(trace("clone"))
+	out, err := m.Wrap("Agent-A", add, add.Execute)(context.Background(), map[string]interface{}{"a": 1, "b": 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out != 11 {
+		t.Fatalf("except input rewritten to 1+10, actual: %v", out)
+	}
+	if !reflect.DeepEqual(order, []string{"global", "agent", "tool"}) {
+		t.Fatalf("unexpected middleware order: %v", order)
+	}
+
+	order = nil
+	if _, err := m.Wrap("Agent-B", add, add.Execute)(context.Background(),
+		map[string]interface{}{"a": 1, "b": 2}); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(order, []string{"global", "tool"}) {
+		t.Fatalf("unexpected middleware order: %v", order)
+	}
+}
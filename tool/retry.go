@@ -0,0 +1,78 @@
+package tool
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/tencent-lke/lke-sdk-go/util"
+)
+
+// RetryPolicy 工具执行失败时的重试策略
+// 重试在工具的超时时间内进行，超时或者执行被取消时不再重试
+type RetryPolicy struct {
+	MaxAttempts    int           // 最大尝试次数，包括第一次执行，小于等于 1 时不重试
+	InitialBackoff time.Duration // 第一次重试前的等待时间
+	MaxBackoff     time.Duration // 最大等待时间，0 表示不限制
+	Multiplier     float64       // 每次重试等待时间的增长倍数，小于 1 时按 1 处理
+	Jitter         float64       // 随机抖动比例，取值 [0, 1]，实际等待时间在 backoff*(1±Jitter) 之间
+	// Retryable 错误是否可以重试，为空时除了 ctx 取消之外的错误都可以重试
+	// 工具 panic 时不会重试，panic 由外层恢复后作为 lkeerrors.ErrToolPanic 返回
+	Retryable func(err error) bool
+}
+
+// RetryableTool 支持重试策略的工具，FunctionTool、McpTool 和 AgentAsTool 都实现了该接口
+type RetryableTool interface {
+	// GetRetryPolicy 获取重试策略，为空不重试
+	GetRetryPolicy() *RetryPolicy
+
+	// SetRetryPolicy 设置重试策略，为空不重试
+	SetRetryPolicy(p *RetryPolicy)
+}
+
+// GetRetryPolicy 获取工具的重试策略，工具没有实现 RetryableTool 时返回空
+func GetRetryPolicy(t Tool) *RetryPolicy {
+	if r, ok := t.(RetryableTool); ok {
+		return r.GetRetryPolicy()
+	}
+	return nil
+}
+
+// retryable 判断错误是否可以重试
+func (p *RetryPolicy) retryable(ctx context.Context, err error) bool {
+	if ctx.Err() != nil || errors.Is(err, context.Canceled) {
+		return false
+	}
+	if p.Retryable != nil {
+		return p.Retryable(err)
+	}
+	return true
+}
+
+// Execute 按重试策略执行 f，返回最后一次执行的结果和执行次数，p 为空时只执行一次
+// onRetry 在每次重试等待之前回调，attempt 为已经执行的次数，可以为空
+// f panic 时不恢复也不重试，panic 直接传给调用方
+func (p *RetryPolicy) Execute(ctx context.Context, f ToolFunc, input map[string]interface{},
+	onRetry func(attempt int, err error, delay time.Duration)) (output interface{}, attempts int, err error) {
+	maxAttempts := 1
+	if p != nil && p.MaxAttempts > 1 {
+		maxAttempts = p.MaxAttempts
+	}
+	for attempts = 1; ; attempts++ {
+		output, err = f(ctx, input)
+		if err == nil || attempts >= maxAttempts || !p.retryable(ctx, err) {
+			return output, attempts, err
+		}
+		delay := util.Backoff(p.InitialBackoff, p.MaxBackoff, p.Multiplier, p.Jitter, attempts)
+		if onRetry != nil {
+			onRetry(attempts, err, delay)
+		}
+		t := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return output, attempts, err
+		case <-t.C:
+		}
+	}
+}
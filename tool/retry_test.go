@@ -0,0 +1,65 @@
+package tool_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/tencent-lke/lke-sdk-go/tool"
+)
+
+func TestRetryPolicyExecute(t *testing.T) {
+	errFlaky := errors.New("flaky")
+	errFatal := errors.New("fatal")
+	flaky := func(failures int, err error) (tool.ToolFunc, *int) {
+		calls := 0
+		return func(ctx context.Context, input map[string]interface{}) (interface{}, error) {
+			calls++
+			if calls <= failures {
+				return nil, err
+			}
+			return "ok", nil
+		}, &calls
+	}
+	policy := &tool.RetryPolicy{
+		MaxAttempts:    3,
+		InitialBackoff: time.Millisecond,
+		Retryable:      func(err error) bool { return errors.Is(err, errFlaky) },
+	}
+
+	f, calls := flaky(2, errFlaky)
+	retries := 0
+	out, attempts, err := policy.Execute(context.Background(), f, nil, func(int, error, time.Duration) { retries++ })
+	if err != nil || out != "ok" || attempts != 3 || *calls != 3 || retries != 2 {
+		t.Fatalf("unexpected result: %v, %v, attempts: %d", out, err, attempts)
+	}
+
+	f, calls = flaky(3, errFlaky)
+	if _, attempts, err = policy.Execute(context.Background(), f, nil, nil); !errors.Is(err, errFlaky) || attempts != 3 {
+		t.Fatalf("except give up after 3 attempts, actual: %v, %d", err, attempts)
+	}
+
+	f, calls = flaky(1, errFatal)
+	if _, attempts, err = policy.Execute(context.Background(), f, nil, nil); !errors.Is(err, errFatal) || attempts != 1 {
+		t.Fatalf("except no retry for fatal error, actual: %v, %d", err, attempts)
+	}
+
+	var nilPolicy *tool.RetryPolicy
+	f, calls = flaky(1, errFlaky)
+	if _, attempts, _ = nilPolicy.Execute(context.Background(), f, nil, nil); attempts != 1 || *calls != 1 {
+		t.Fatalf("except single attempt without policy, actual: %d", attempts)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	slow := &tool.RetryPolicy{MaxAttempts: 5, InitialBackoff: time.Second}
+	f, calls = flaky(5, errFlaky)
+	begin := time.Now()
+	if _, attempts, err = slow.Execute(ctx, f, nil, nil); !errors.Is(err, errFlaky) || attempts != 1 {
+		t.Fatalf("except stop retrying when ctx done, actual: %v, %d", err, attempts)
+	}
+	if time.Since(begin) > 500*time.Millisecond {
+		t.Fatalf("except return when ctx done, actual: %v", time.Since(begin))
+	}
+}
@@ -0,0 +1,445 @@
+package tool
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SchemaTag 结构体字段上描述参数约束的 tag，多个约束用逗号分隔，值中的逗号用 \, 转义，例如
+//
+//	Status string `json:"status" jsonschema:"enum=paid,enum=shipped,default=paid"`
+//	Age    int    `json:"age" jsonschema:"minimum=0,maximum=150,example=18"`
+//	Email  string `json:"email,omitempty" jsonschema:"format=email,deprecated"`
+//
+// 支持 enum、minimum、maximum、exclusiveMinimum、exclusiveMaximum、multipleOf、minLength、maxLength、pattern、
+// format、minItems、maxItems、uniqueItems、default、example、title、deprecated。
+// 数组字段上的 enum、数值、字符串约束作用于数组元素
+const SchemaTag = "jsonschema"
+
+var (
+	timeType       = reflect.TypeOf(time.Time{})
+	rawMessageType = reflect.TypeOf(json.RawMessage{})
+)
+
+// itemKeywords 数组字段上作用于元素的约束
+var itemKeywords = map[string]bool{
+	"enum": true, "minimum": true, "maximum": true, "exclusiveMinimum": true, "exclusiveMaximum": true,
+	"multipleOf": true, "minLength": true, "maxLength": true, "pattern": true, "format": true,
+}
+
+// fieldDescriptions 注册的字段描述，结构体类型 -> 字段名 -> 描述
+var fieldDescriptions sync.Map
+
+// RegisterFieldDescriptions 注册结构体 T 的字段描述，key 为 go 字段名，生成 schema 时字段没有 doc tag 时使用
+// 一般由 lketoolgen 按字段注释生成的代码在 init 中调用
+func RegisterFieldDescriptions[T any](descriptions map[string]string) {
+	fieldDescriptions.Store(reflect.TypeOf((*T)(nil)).Elem(), descriptions)
+}
+
+// fieldDescription 注册的字段描述
+func fieldDescription(t reflect.Type, field string) string {
+	if descs, ok := fieldDescriptions.Load(t); ok {
+		return descs.(map[string]string)[field]
+	}
+	return ""
+}
+
+// SchemaOf 按 go 类型生成 JSON schema，规则和函数工具的参数相同
+// 递归的结构体类型放到 $defs 中，通过 $ref 引用，tag 中的约束无法解析时返回错误
+func SchemaOf(t reflect.Type) (map[string]interface{}, error) {
+	g := &schemaGenerator{
+		defs:       map[string]interface{}{},
+		names:      map[reflect.Type]string{},
+		inProgress: map[reflect.Type]bool{},
+		recursive:  map[reflect.Type]bool{},
+	}
+	schema := g.typeSchema(t)
+	if g.err != nil {
+		return nil, g.err
+	}
+	if ref, ok := schema["$ref"].(string); ok {
+		// 根类型是递归类型时展开一层，保证参数的根节点是 object
+		root := map[string]interface{}{}
+		for k, v := range g.defs[strings.TrimPrefix(ref, "#/$defs/")].(map[string]interface{}) {
+			root[k] = v
+		}
+		schema = root
+	}
+	if len(g.defs) > 0 {
+		schema["$defs"] = g.defs
+	}
+	return schema, nil
+}
+
+// schemaGenerator 一次 schema 生成的状态
+type schemaGenerator struct {
+	defs       map[string]interface{}  // 递归类型的定义
+	names      map[reflect.Type]string // 递归类型在 $defs 中的名字
+	inProgress map[reflect.Type]bool   // 正在生成的结构体，再次遇到时说明是递归类型
+	recursive  map[reflect.Type]bool   // 递归的结构体类型
+	err        error                   // 第一个 tag 解析错误
+}
+
+// typeSchema returns the JSON schema for a Go type
+func (g *schemaGenerator) typeSchema(t reflect.Type) map[string]interface{} {
+	schema := make(map[string]interface{})
+
+	// Handle pointers
+	if t.Kind() == reflect.Ptr {
+		elemSchema := g.typeSchema(t.Elem())
+
+		// For pointers, the field is nullable
+		if enum, ok := elemSchema["enum"]; ok {
+			// If the schema has enum values, add null to the enum
+			enumValues := enum.([]interface{})
+			enumValues = append(enumValues, nil)
+			elemSchema["enum"] = enumValues
+		}
+
+		return elemSchema
+	}
+
+	switch t {
+	case timeType:
+		schema["type"] = "string"
+		schema["format"] = "date-time"
+		return schema
+	case rawMessageType:
+		// 任意 json
+		return schema
+	}
+
+	// Handle different types
+	switch t.Kind() {
+	case reflect.Bool:
+		schema["type"] = "boolean"
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		schema["type"] = "integer"
+
+	case reflect.Float32, reflect.Float64:
+		schema["type"] = "number"
+
+	case reflect.String:
+		schema["type"] = "string"
+
+	case reflect.Slice, reflect.Array:
+		schema["type"] = "array"
+		schema["items"] = g.typeSchema(t.Elem())
+
+	case reflect.Map:
+		schema["type"] = "object"
+		switch t.Key().Kind() {
+		case reflect.String:
+			schema["additionalProperties"] = g.typeSchema(t.Elem())
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+			reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			// 整数 key 在 json 中是数字字符串
+			schema["additionalProperties"] = g.typeSchema(t.Elem())
+			schema["propertyNames"] = map[string]interface{}{"pattern": "^-?[0-9]+$"}
+		default:
+			// Non-string keyed maps are not well represented in JSON Schema
+			schema["additionalProperties"] = true
+		}
+
+	case reflect.Struct:
+		return g.structSchema(t)
+
+	default:
+		// For unknown types, fallback to string
+		schema["type"] = "string"
+	}
+
+	return schema
+}
+
+// structSchema 结构体的 schema，递归类型返回 $ref
+func (g *schemaGenerator) structSchema(t reflect.Type) map[string]interface{} {
+	if g.recursive[t] || g.inProgress[t] {
+		g.recursive[t] = true
+		return g.ref(t)
+	}
+	g.inProgress[t] = true
+	schema := map[string]interface{}{
+		"type":       "object",
+		"properties": make(map[string]interface{}),
+		"required":   []string{},
+	}
+	g.addFields(schema, t)
+	delete(g.inProgress, t)
+	if g.recursive[t] {
+		g.defs[g.name(t)] = schema
+		return g.ref(t)
+	}
+	return schema
+}
+
+// addFields 把结构体的字段加到 schema 中，没有 json tag 的嵌入结构体的字段展开到外层
+func (g *schemaGenerator) addFields(schema map[string]interface{}, t reflect.Type) {
+	properties := schema["properties"].(map[string]interface{})
+	// 外层的字段优先于嵌入结构体中的同名字段
+	direct := map[string]bool{}
+	for i := 0; i < t.NumField(); i++ {
+		if name, _, ok := fieldName(t.Field(i)); ok {
+			direct[name] = true
+		}
+	}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if embedded := embeddedStruct(field); embedded != nil {
+			if g.inProgress[embedded] {
+				continue
+			}
+			sub := map[string]interface{}{"properties": map[string]interface{}{}, "required": []string{}}
+			g.inProgress[embedded] = true
+			g.addFields(sub, embedded)
+			delete(g.inProgress, embedded)
+			for name, prop := range sub["properties"].(map[string]interface{}) {
+				if !direct[name] {
+					properties[name] = prop
+				}
+			}
+			for _, name := range sub["required"].([]string) {
+				if !direct[name] {
+					schema["required"] = append(schema["required"].([]string), name)
+				}
+			}
+			continue
+		}
+		name, required, ok := fieldName(field)
+		if !ok {
+			continue
+		}
+		if required {
+			schema["required"] = append(schema["required"].([]string), name)
+		}
+
+		// Get the field schema
+		fieldSchema := g.typeSchema(field.Type)
+
+		// Add description from doc tag if available
+		if docTag := field.Tag.Get("doc"); docTag != "" {
+			fieldSchema["description"] = docTag
+		} else if desc := fieldDescription(t, field.Name); desc != "" {
+			fieldSchema["description"] = desc
+		}
+		if tag := field.Tag.Get(SchemaTag); tag != "" {
+			if err := applySchemaTag(fieldSchema, field.Type, tag); err != nil && g.err == nil {
+				g.err = fmt.Errorf("field %s.%s: %w", t.Name(), field.Name, err)
+			}
+		}
+
+		// Add the field to properties
+		properties[name] = fieldSchema
+	}
+}
+
+// fieldName 字段在 json 中的名字，以及是否必填，没有 omitempty 的字段都是必填的
+func fieldName(field reflect.StructField) (name string, required bool, ok bool) {
+	// Skip unexported fields
+	if field.PkgPath != "" || embeddedStruct(field) != nil {
+		return "", false, false
+	}
+
+	// Get the field name from JSON tag or fallback to field name
+	jsonTag := field.Tag.Get("json")
+	if jsonTag == "" {
+		// If no JSON tag, assume it's required
+		return field.Name, true, true
+	}
+	// Handle json tag options like `json:"name,omitempty"`
+	parts := strings.Split(jsonTag, ",")
+	name = parts[0]
+	// Skip if the field is explicitly omitted with "-"
+	if name == "-" {
+		return "", false, false
+	}
+	if name == "" {
+		name = field.Name
+	}
+	// Check if the field is required (not marked as omitempty)
+	for _, part := range parts[1:] {
+		if part == "omitempty" {
+			return name, false, true
+		}
+	}
+	return name, true, true
+}
+
+// embeddedStruct 字段是没有 json 名字的嵌入结构体时返回结构体类型，字段在 json 中展开到外层
+func embeddedStruct(field reflect.StructField) reflect.Type {
+	if !field.Anonymous {
+		return nil
+	}
+	if name := strings.Split(field.Tag.Get("json"), ",")[0]; name != "" {
+		return nil
+	}
+	t := field.Type
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return nil
+	}
+	return t
+}
+
+// name 递归类型在 $defs 中的名字，不同包的同名类型加上序号区分
+func (g *schemaGenerator) name(t reflect.Type) string {
+	if name, ok := g.names[t]; ok {
+		return name
+	}
+	base := strings.NewReplacer("[", "_", "]", "", ",", "_", "/", "_", "*", "").Replace(t.Name())
+	name := base
+	for i := 2; ; i++ {
+		if _, ok := g.defs[name]; !ok && !g.nameUsed(name) {
+			break
+		}
+		name = base + strconv.Itoa(i)
+	}
+	g.names[t] = name
+	return name
+}
+
+// nameUsed 名字是否已经分配给其他类型
+func (g *schemaGenerator) nameUsed(name string) bool {
+	for _, n := range g.names {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+// ref 引用 $defs 中的递归类型
+func (g *schemaGenerator) ref(t reflect.Type) map[string]interface{} {
+	return map[string]interface{}{"$ref": "#/$defs/" + g.name(t)}
+}
+
+// applySchemaTag 把 jsonschema tag 中的约束加到字段的 schema 上
+func applySchemaTag(schema map[string]interface{}, t reflect.Type, tag string) error {
+	for _, item := range splitTag(tag) {
+		key, value, hasValue := strings.Cut(item, "=")
+		key = strings.TrimSpace(key)
+		target, targetType := schema, t
+		if items, ok := schema["items"].(map[string]interface{}); ok && itemKeywords[key] {
+			target, targetType = items, elemType(t)
+		}
+		switch key {
+		case "deprecated", "uniqueItems":
+			target[key] = !hasValue || value == "true"
+		case "enum":
+			v, err := parseTagValue(value, targetType)
+			if err != nil {
+				return fmt.Errorf("invalid enum %q: %w", value, err)
+			}
+			enum, _ := target["enum"].([]interface{})
+			target["enum"] = append(enum, v)
+		case "example":
+			v, err := parseTagValue(value, targetType)
+			if err != nil {
+				return fmt.Errorf("invalid example %q: %w", value, err)
+			}
+			examples, _ := target["examples"].([]interface{})
+			target["examples"] = append(examples, v)
+		case "default":
+			v, err := parseTagValue(value, targetType)
+			if err != nil {
+				return fmt.Errorf("invalid default %q: %w", value, err)
+			}
+			target["default"] = v
+		case "minimum", "maximum", "exclusiveMinimum", "exclusiveMaximum", "multipleOf":
+			n, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				return fmt.Errorf("invalid %s %q: %w", key, value, err)
+			}
+			target[key] = n
+		case "minLength", "maxLength", "minItems", "maxItems":
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 0 {
+				return fmt.Errorf("invalid %s %q", key, value)
+			}
+			target[key] = n
+		case "pattern", "format", "title":
+			target[key] = value
+		case "":
+		default:
+			return fmt.Errorf("unknown %s tag %q", SchemaTag, key)
+		}
+	}
+	return nil
+}
+
+// splitTag 按逗号分隔 tag，\, 表示值中的逗号
+func splitTag(tag string) []string {
+	var items []string
+	var cur strings.Builder
+	for i := 0; i < len(tag); i++ {
+		switch {
+		case tag[i] == '\\' && i+1 < len(tag) && tag[i+1] == ',':
+			cur.WriteByte(',')
+			i++
+		case tag[i] == ',':
+			items = append(items, cur.String())
+			cur.Reset()
+		default:
+			cur.WriteByte(tag[i])
+		}
+	}
+	return append(items, cur.String())
+}
+
+// elemType 数组、切片和指针的元素类型
+func elemType(t reflect.Type) reflect.Type {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() == reflect.Slice || t.Kind() == reflect.Array {
+		t = t.Elem()
+	}
+	return t
+}
+
+// parseTagValue 按字段类型解析 tag 中的值，复杂类型的值按 json 解析
+func parseTagValue(value string, t reflect.Type) (interface{}, error) {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == timeType {
+		return value, nil
+	}
+	switch t.Kind() {
+	case reflect.String:
+		return value, nil
+	case reflect.Bool:
+		return strconv.ParseBool(value)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.ParseInt(value, 10, 64)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.ParseUint(value, 10, 64)
+	case reflect.Float32, reflect.Float64:
+		return strconv.ParseFloat(value, 64)
+	}
+	var v interface{}
+	if err := json.Unmarshal([]byte(value), &v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// OutputInstruction 要求模型按 schema 输出 json 的提示，RunTyped 和设置了 OutputSchema 的 agent 工具共用
+func OutputInstruction(schema map[string]interface{}) string {
+	schemaStr := fmt.Sprintf("%v", schema)
+	if bs, err := json.MarshalIndent(schema, "", "  "); err == nil {
+		schemaStr = string(bs)
+	}
+	return fmt.Sprintf("IMPORTANT: You must respond with valid JSON in the following format:\n%s\n\n"+
+		"Your response must be valid JSON that matches this schema exactly. "+
+		"Do not include ```json or ``` in the beginning or end of the response.", schemaStr)
+}
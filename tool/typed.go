@@ -0,0 +1,208 @@
+package tool
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/tencent-lke/lke-sdk-go/lkeerrors"
+)
+
+// TypedTool 类型安全的函数工具，参数 schema 在创建时按 In 生成一次，
+// 执行时把参数严格解码成 In，未知字段和缺少的必填字段都会报错，不再按反射猜测参数
+// Execute 返回的输出就是 Out 类型的值，可以在钩子和中间件中直接断言成 Out
+type TypedTool[In any, Out any] struct {
+	name        string
+	description string
+	fn          func(ctx context.Context, in In) (Out, error)
+	schema      map[string]interface{}
+	required    []string
+	properties  map[string]struct{}
+	strict      bool // In 为结构体时检查未知字段和必填字段
+	timeout     time.Duration
+	retryPolicy *RetryPolicy
+	noValidate  bool
+	formatter   func(out Out) string
+	hook        func(ctx context.Context, in In, out Out, err error)
+}
+
+// NewTypedTool 创建类型安全的函数工具，In 必须是结构体、结构体指针或者 key 为 string 的 map
+func NewTypedTool[In any, Out any](name, description string,
+	fn func(ctx context.Context, in In) (Out, error)) (*TypedTool[In, Out], error) {
+	if fn == nil {
+		return nil, fmt.Errorf("typed tool %s requires a function", name)
+	}
+	inType := reflect.TypeOf((*In)(nil)).Elem()
+	structType := inType
+	if structType.Kind() == reflect.Ptr {
+		structType = structType.Elem()
+	}
+	t := &TypedTool[In, Out]{
+		name:        name,
+		description: description,
+		fn:          fn,
+		properties:  map[string]struct{}{},
+	}
+	var err error
+	switch {
+	case structType.Kind() == reflect.Struct:
+		if t.schema, err = SchemaOf(structType); err != nil {
+			return nil, fmt.Errorf("typed tool %s: %w", name, err)
+		}
+		t.schema["additionalProperties"] = false
+		t.strict = true
+		t.required, _ = t.schema["required"].([]string)
+		props, _ := t.schema["properties"].(map[string]interface{})
+		for p := range props {
+			t.properties[p] = struct{}{}
+		}
+	case inType.Kind() == reflect.Map && inType.Key().Kind() == reflect.String:
+		if t.schema, err = SchemaOf(inType); err != nil {
+			return nil, fmt.Errorf("typed tool %s: %w", name, err)
+		}
+	default:
+		return nil, fmt.Errorf("typed tool %s: unsupported input type %v, must be struct or map", name, inType)
+	}
+	return t, nil
+}
+
+// GetName returns the name of the tool
+func (t *TypedTool[In, Out]) GetName() string {
+	return t.name
+}
+
+// GetDescription returns the description of the tool
+func (t *TypedTool[In, Out]) GetDescription() string {
+	return t.description
+}
+
+// GetParametersSchema returns the JSON schema for the tool parameters
+func (t *TypedTool[In, Out]) GetParametersSchema() map[string]interface{} {
+	return t.schema
+}
+
+// Execute 把参数严格解码成 In 后执行，输出为 Out 类型
+func (t *TypedTool[In, Out]) Execute(ctx context.Context, params map[string]interface{}) (interface{}, error) {
+	in, err := t.decode(ctx, params)
+	if err != nil {
+		return nil, err
+	}
+	return t.Call(ctx, in)
+}
+
+// Call 直接用类型化的参数执行工具，执行后回调 WithHook 设置的钩子
+func (t *TypedTool[In, Out]) Call(ctx context.Context, in In) (Out, error) {
+	out, err := t.fn(ctx, in)
+	if t.hook != nil {
+		t.hook(ctx, in, out, err)
+	}
+	return out, err
+}
+
+// decode 把参数解码成 In，忽略 runner 合并进来的、In 中没有定义的自定义参数
+func (t *TypedTool[In, Out]) decode(ctx context.Context, params map[string]interface{}) (in In, err error) {
+	if t.strict {
+		info, _ := CallInfoFromContext(ctx)
+		var unknown, missing []string
+		args := make(map[string]interface{}, len(params))
+		for k, v := range params {
+			if _, ok := t.properties[k]; ok {
+				args[k] = v
+				continue
+			}
+			if _, ok := info.CustomVariables[k]; !ok {
+				unknown = append(unknown, k)
+			}
+		}
+		for _, r := range t.required {
+			if _, ok := params[r]; !ok {
+				missing = append(missing, r)
+			}
+		}
+		if len(unknown) > 0 || len(missing) > 0 {
+			return in, fmt.Errorf("%w: %s", lkeerrors.ErrInvalidToolArgs, fieldsError(unknown, missing))
+		}
+		params = args
+	}
+	bs, err := json.Marshal(params)
+	if err != nil {
+		return in, fmt.Errorf("%w: %v", lkeerrors.ErrInvalidToolArgs, err)
+	}
+	dec := json.NewDecoder(bytes.NewReader(bs))
+	if t.strict {
+		dec.DisallowUnknownFields()
+	}
+	if err := dec.Decode(&in); err != nil {
+		return in, fmt.Errorf("%w: %v", lkeerrors.ErrInvalidToolArgs, err)
+	}
+	return in, nil
+}
+
+// fieldsError 未知字段和缺少必填字段的错误信息
+func fieldsError(unknown, missing []string) string {
+	var msgs []string
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		msgs = append(msgs, "unknown fields: "+strings.Join(unknown, ", "))
+	}
+	if len(missing) > 0 {
+		msgs = append(msgs, "missing required fields: "+strings.Join(missing, ", "))
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// ResultToString 输出转换成 string，设置了 WithResultFormatter 时使用自定义的格式
+func (t *TypedTool[In, Out]) ResultToString(output interface{}) string {
+	if out, ok := output.(Out); ok && t.formatter != nil {
+		return t.formatter(out)
+	}
+	str, _ := InterfaceToString(output)
+	return str
+}
+
+// WithResultFormatter 设置输出转换成 string 的方式
+func (t *TypedTool[In, Out]) WithResultFormatter(f func(out Out) string) *TypedTool[In, Out] {
+	t.formatter = f
+	return t
+}
+
+// WithHook 设置每次执行后的钩子，可以拿到类型化的输入和输出
+func (t *TypedTool[In, Out]) WithHook(hook func(ctx context.Context, in In, out Out, err error)) *TypedTool[In, Out] {
+	t.hook = hook
+	return t
+}
+
+// GetTimeout 获取超时时间
+func (t *TypedTool[In, Out]) GetTimeout() time.Duration {
+	return t.timeout
+}
+
+// SetTimeout 配置工具超时时间
+func (t *TypedTool[In, Out]) SetTimeout(timeout time.Duration) {
+	t.timeout = timeout
+}
+
+// GetRetryPolicy 获取重试策略
+func (t *TypedTool[In, Out]) GetRetryPolicy() *RetryPolicy {
+	return t.retryPolicy
+}
+
+// SetRetryPolicy 设置重试策略，为空不重试
+func (t *TypedTool[In, Out]) SetRetryPolicy(p *RetryPolicy) {
+	t.retryPolicy = p
+}
+
+// GetValidateArgs 执行前是否校验参数，默认校验
+func (t *TypedTool[In, Out]) GetValidateArgs() bool {
+	return !t.noValidate
+}
+
+// SetValidateArgs 设置执行前是否校验参数
+func (t *TypedTool[In, Out]) SetValidateArgs(validate bool) {
+	t.noValidate = !validate
+}
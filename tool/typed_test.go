@@ -0,0 +1,83 @@
+package tool_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/tencent-lke/lke-sdk-go/lkeerrors"
+	"github.com/tencent-lke/lke-sdk-go/tool"
+)
+
+type weatherIn struct {
+	City string `json:"city" doc:"city name"`
+	Days int    `json:"days,omitempty" doc:"forecast days"`
+}
+
+type weatherOut struct {
+	City  string
+	Temps []int
+}
+
+func TestTypedTool(t *testing.T) {
+	var hooked weatherOut
+	weather, err := tool.NewTypedTool("weather", "get weather",
+		func(ctx context.Context, in weatherIn) (weatherOut, error) {
+			return weatherOut{City: in.City, Temps: make([]int, in.Days)}, nil
+		})
+	if err != nil {
+		t.Fatal(err)
+	}
+	weather.WithHook(func(ctx context.Context, in weatherIn, out weatherOut, err error) {
+		hooked = out
+	}).WithResultFormatter(func(out weatherOut) string {
+		return fmt.Sprintf("%s: %d days", out.City, len(out.Temps))
+	})
+	excepetSchema := map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"city": map[string]interface{}{"type": "string", "description": "city name"},
+			"days": map[string]interface{}{"type": "integer", "description": "forecast days"},
+		},
+		"required":             []string{"city"},
+		"additionalProperties": false,
+	}
+	assertMap(t, excepetSchema, weather.GetParametersSchema())
+
+	output, err := weather.Execute(context.Background(), map[string]interface{}{"city": "Shenzhen", "days": float64(3)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out, ok := output.(weatherOut); !ok || out.City != "Shenzhen" || len(hooked.Temps) != 3 {
+		t.Fatalf("unexpected output: %#v", output)
+	}
+	if str := weather.ResultToString(output); str != "Shenzhen: 3 days" {
+		t.Fatalf("unexpected result string: %s", str)
+	}
+
+	_, err = weather.Execute(context.Background(), map[string]interface{}{"town": "Shenzhen", "day": 3})
+	if !errors.Is(err, lkeerrors.ErrInvalidToolArgs) ||
+		!strings.Contains(err.Error(), "unknown fields: day, town; missing required fields: city") {
+		t.Fatalf("except invalid args error, actual: %v", err)
+	}
+	_, err = weather.Execute(context.Background(), map[string]interface{}{"city": 1})
+	if !errors.Is(err, lkeerrors.ErrInvalidToolArgs) {
+		t.Fatalf("except invalid args error, actual: %v", err)
+	}
+
+	// runner 合并到输入中的自定义参数不算未知字段
+	ctx := tool.WithCallInfo(context.Background(), tool.CallInfo{
+		CustomVariables: map[string]string{"_user_guid": "u1"},
+	})
+	if _, err = weather.Execute(ctx, map[string]interface{}{"city": "Shenzhen", "_user_guid": "u1"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = tool.NewTypedTool("bad", "bad", func(ctx context.Context, in int) (int, error) {
+		return in, nil
+	}); err == nil {
+		t.Fatal("except error for unsupported input type")
+	}
+}
@@ -0,0 +1,73 @@
+package tool
+
+import (
+	"strconv"
+
+	"github.com/tencent-lke/lke-sdk-go/jsonschema"
+)
+
+// ValidatableTool 可以关闭参数校验的工具，FunctionTool、McpTool 和 AgentAsTool 都实现了该接口
+// 默认在执行前按 GetParametersSchema 校验模型给出的参数，校验失败时不执行工具，把错误信息作为输出提交给模型
+type ValidatableTool interface {
+	// GetValidateArgs 执行前是否校验参数
+	GetValidateArgs() bool
+
+	// SetValidateArgs 设置执行前是否校验参数
+	SetValidateArgs(validate bool)
+}
+
+// ValidateArgs 按工具的参数 schema 校验模型给出的参数，工具关闭了参数校验时不校验
+// 校验失败时返回 *jsonschema.ValidationError
+func ValidateArgs(t Tool, args map[string]interface{}) error {
+	if v, ok := t.(ValidatableTool); ok && !v.GetValidateArgs() {
+		return nil
+	}
+	return jsonschema.Validate(t.GetParametersSchema(), args)
+}
+
+// ValidateCallArgs 校验模型给出的参数和 schema 中声明的自定义参数，必填的自定义参数不需要模型给出
+// schema 中没有声明的自定义参数不参与校验，避免 additionalProperties 为 false 时校验失败
+// 自定义参数都是字符串，声明为 integer、number、boolean 时按声明的类型转换后校验，和执行时的转换一致
+func ValidateCallArgs(t Tool, args map[string]interface{}, customVars map[string]string) error {
+	if len(customVars) == 0 {
+		return ValidateArgs(t, args)
+	}
+	properties, _ := t.GetParametersSchema()["properties"].(map[string]interface{})
+	merged := make(map[string]interface{}, len(args))
+	for k, v := range args {
+		merged[k] = v
+	}
+	for k, v := range customVars {
+		if prop, ok := properties[k]; ok {
+			merged[k] = coerceCustomVar(prop, v)
+		}
+	}
+	return ValidateArgs(t, merged)
+}
+
+// coerceCustomVar 按 schema 声明的类型转换字符串形式的自定义参数，无法转换时返回原字符串
+func coerceCustomVar(prop interface{}, v string) interface{} {
+	s, _ := prop.(map[string]interface{})
+	var types []interface{}
+	switch t := s["type"].(type) {
+	case string:
+		types = []interface{}{t}
+	case []interface{}:
+		types = t
+	}
+	for _, t := range types {
+		switch t {
+		case "string":
+			return v
+		case "integer", "number":
+			if n, err := strconv.ParseFloat(v, 64); err == nil {
+				return n
+			}
+		case "boolean":
+			if b, err := strconv.ParseBool(v); err == nil {
+				return b
+			}
+		}
+	}
+	return v
+}
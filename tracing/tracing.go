@@ -0,0 +1,73 @@
+// Package tracing sdk 的 OpenTelemetry 埋点
+//
+// 默认使用全局的 TracerProvider 和 TextMapPropagator，没有设置时不产生任何 span。
+// 每次执行创建 lke.run span，每轮云端调用创建 lke.turn span，每次本地工具调用创建 lke.tool span，
+// mcp 工具和 agent 工具在 lke.tool 下分别创建 lke.mcp.call_tool 和 lke.agent_as_tool span。
+// 云端的 trace_id 和 request_id 记录在 lke.turn span 的属性中，用于关联知识引擎的调用链。
+package tracing
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// ScopeName 埋点的 instrumentation scope
+const ScopeName = "github.com/tencent-lke/lke-sdk-go"
+
+// span 名称
+const (
+	SpanRun         = "lke.run"
+	SpanTurn        = "lke.turn"
+	SpanTool        = "lke.tool"
+	SpanMcpCallTool = "lke.mcp.call_tool"
+	SpanAgentAsTool = "lke.agent_as_tool"
+)
+
+// span 属性
+const (
+	AttrRequestID       = "lke.request_id"        // sdk 生成的请求 ID
+	AttrSessionID       = "lke.session_id"        // 对话 ID
+	AttrStartAgent      = "lke.start_agent"       // 入口 agent
+	AttrTurnIndex       = "lke.turn.index"        // 轮次，从 0 开始
+	AttrTurnAttempts    = "lke.turn.attempts"     // 调用云端接口的次数，包括重试
+	AttrTurns           = "lke.turns"             // 执行的轮数
+	AttrServerTraceID   = "lke.server.trace_id"   // 云端返回的 trace_id
+	AttrServerRequestID = "lke.server.request_id" // 云端返回的 request_id
+	AttrToolName        = "lke.tool.name"         // 工具名称
+	AttrToolCallID      = "lke.tool.call_id"      // 工具调用 ID
+	AttrAgentName       = "lke.agent.name"        // agent 名称
+	AttrTokens          = "lke.tokens"            // 消耗的 token 数
+)
+
+// Tracer 获取 sdk 的 tracer，tp 为空时优先使用 ctx 中 span 所属的 TracerProvider，其次使用全局的
+func Tracer(ctx context.Context, tp trace.TracerProvider) trace.Tracer {
+	if tp == nil {
+		if span := trace.SpanFromContext(ctx); span.SpanContext().IsValid() {
+			tp = span.TracerProvider()
+		} else {
+			tp = otel.GetTracerProvider()
+		}
+	}
+	return tp.Tracer(ScopeName)
+}
+
+// Propagator 获取 propagator，p 为空时使用全局的 TextMapPropagator
+func Propagator(p propagation.TextMapPropagator) propagation.TextMapPropagator {
+	if p == nil {
+		return otel.GetTextMapPropagator()
+	}
+	return p
+}
+
+// End 结束 span，err 不为空时记录错误
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
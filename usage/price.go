@@ -0,0 +1,97 @@
+package usage
+
+// Price 一个模型的价格，单位由使用方决定，例如元
+type Price struct {
+	InputPer1K  float64 `json:"input_per_1k"`  // 每千输入 token 的价格
+	OutputPer1K float64 `json:"output_per_1k"` // 每千输出 token 的价格
+	TotalPer1K  float64 `json:"total_per_1k"`  // 没有区分输入输出时每千 token 的价格
+}
+
+// Cost 计算用量的费用，区分了输入输出时按输入输出计价，否则按总 token 数计价
+func (p Price) Cost(u Usage) float64 {
+	if u.InputTokens == 0 && u.OutputTokens == 0 {
+		return float64(u.TotalTokens) / 1000 * p.TotalPer1K
+	}
+	return float64(u.InputTokens)/1000*p.InputPer1K + float64(u.OutputTokens)/1000*p.OutputPer1K
+}
+
+// PriceTable 模型名到价格的映射，key 为空字符串的价格用于没有配置的模型
+type PriceTable map[string]Price
+
+// price 获取模型的价格
+func (t PriceTable) price(model string) Price {
+	if p, ok := t[model]; ok {
+		return p
+	}
+	return t[""]
+}
+
+// Line 一个维度的用量和费用
+type Line struct {
+	Usage
+	Cost float64 `json:"cost"`
+}
+
+// Summary 用量和费用的汇总，可以序列化成 json 保存
+type Summary struct {
+	Total       Line            `json:"total"`
+	ByAgent     map[string]Line `json:"by_agent"`
+	ByModel     map[string]Line `json:"by_model"`
+	ByProcedure map[string]Line `json:"by_procedure"`
+	Records     []Record        `json:"records"` // 按 agent、模型、过程汇总的明细
+}
+
+// Summary 按价格表汇总用量和费用，prices 为空时费用都为 0
+func (c *Collector) Summary(prices PriceTable) *Summary {
+	s := &Summary{
+		ByAgent:     map[string]Line{},
+		ByModel:     map[string]Line{},
+		ByProcedure: map[string]Line{},
+		Records:     c.Records(),
+	}
+	add := func(lines map[string]Line, name string, u Usage, cost float64) {
+		line := lines[name]
+		line.add(u)
+		line.Cost += cost
+		lines[name] = line
+	}
+	for _, r := range s.Records {
+		cost := prices.price(r.Model).Cost(r.Usage)
+		s.Total.add(r.Usage)
+		s.Total.Cost += cost
+		add(s.ByAgent, r.Agent, r.Usage, cost)
+		add(s.ByModel, r.Model, r.Usage, cost)
+		add(s.ByProcedure, r.Procedure, r.Usage, cost)
+	}
+	return s
+}
+
+// Add 累加另一个汇总的用量和费用，例如合并多次执行的用量，o 为空时不变
+func (s *Summary) Add(o *Summary) {
+	if o == nil {
+		return
+	}
+	s.Total.add(o.Total.Usage)
+	s.Total.Cost += o.Total.Cost
+	s.ByAgent = addLines(s.ByAgent, o.ByAgent)
+	s.ByModel = addLines(s.ByModel, o.ByModel)
+	s.ByProcedure = addLines(s.ByProcedure, o.ByProcedure)
+	merged := NewCollector()
+	merged.Seed(s.Records...)
+	merged.Seed(o.Records...)
+	s.Records = merged.Records()
+}
+
+// addLines 按名称累加用量和费用
+func addLines(lines, others map[string]Line) map[string]Line {
+	if lines == nil {
+		lines = map[string]Line{}
+	}
+	for name, o := range others {
+		line := lines[name]
+		line.add(o.Usage)
+		line.Cost += o.Cost
+		lines[name] = line
+	}
+	return lines
+}
@@ -0,0 +1,210 @@
+// Package usage 汇总 token 用量和费用
+//
+// 每次执行都会创建一个 Collector，按 agent、模型和过程汇总本地工具调用的各轮次以及嵌套的 agent 工具的用量，
+// 嵌套执行的用量同时累加到上层执行的 Collector。通过 WithCollector 传入自定义的 Collector，
+// 可以汇总多次执行的用量，例如按团队统计费用。
+package usage
+
+import (
+	"context"
+	"sort"
+	"sync"
+
+	"github.com/tencent-lke/lke-sdk-go/event"
+)
+
+// Usage token 用量
+type Usage struct {
+	InputTokens  uint64 `json:"input_tokens"`  // 输入 token 数
+	OutputTokens uint64 `json:"output_tokens"` // 输出 token 数
+	TotalTokens  uint64 `json:"total_tokens"`  // 总 token 数
+}
+
+// add 累加用量
+func (u *Usage) add(o Usage) {
+	u.InputTokens += o.InputTokens
+	u.OutputTokens += o.OutputTokens
+	u.TotalTokens += o.TotalTokens
+}
+
+// Record 一个 agent 使用一个模型在一个过程中的用量
+type Record struct {
+	Agent     string `json:"agent"`     // agent 名称
+	Model     string `json:"model"`     // 模型名称，云端没有返回模型时为空
+	Procedure string `json:"procedure"` // 过程名称，参考 event.Procedure* 常量
+	Usage
+}
+
+// key 汇总的维度
+type key struct {
+	agent, model, procedure string
+}
+
+// Collector 并发安全的 token 用量收集器
+type Collector struct {
+	parents []*Collector
+
+	mu      sync.Mutex
+	records map[key]*Usage
+}
+
+// NewCollector 创建 Collector
+func NewCollector() *Collector {
+	return &Collector{records: map[key]*Usage{}}
+}
+
+// Child 创建子 Collector，子 Collector 收集的用量同时累加到 c 和 others，例如同时累加到调用方和所属的对话
+// c 和 others 为空时等价于 NewCollector
+func (c *Collector) Child(others ...*Collector) *Collector {
+	child := NewCollector()
+	for _, p := range append([]*Collector{c}, others...) {
+		if p != nil {
+			child.parents = append(child.parents, p)
+		}
+	}
+	return child
+}
+
+// Add 累加一条用量
+func (c *Collector) Add(r Record) {
+	c.mu.Lock()
+	k := key{agent: r.Agent, model: r.Model, procedure: r.Procedure}
+	u, ok := c.records[k]
+	if !ok {
+		u = &Usage{}
+		c.records[k] = u
+	}
+	u.add(r.Usage)
+	c.mu.Unlock()
+	for _, p := range c.parents {
+		p.Add(r)
+	}
+}
+
+// Seed 累加之前已经统计过的用量，不累加到上层 Collector，例如继续暂停的执行时恢复暂停前的用量
+func (c *Collector) Seed(records ...Record) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, r := range records {
+		k := key{agent: r.Agent, model: r.Model, procedure: r.Procedure}
+		u, ok := c.records[k]
+		if !ok {
+			u = &Usage{}
+			c.records[k] = u
+		}
+		u.add(r.Usage)
+	}
+}
+
+// AddTokenStat 累加一轮对话最终的 token 统计事件，agent 为产生这一轮对话的 agent
+// token 统计事件在一轮对话中会多次下发，只应该累加最后一次
+func (c *Collector) AddTokenStat(agent string, stat *event.TokenStatEvent) {
+	for _, r := range TokenStatRecords(agent, stat) {
+		c.Add(r)
+	}
+}
+
+// TokenStatRecords 把 token 统计事件拆成按模型、过程的用量，优先使用各过程的 TokenUsageDetails
+// 各过程都没有用量时使用 TokenCount，记为没有模型和过程的用量
+func TokenStatRecords(agent string, stat *event.TokenStatEvent) []Record {
+	if stat == nil {
+		return nil
+	}
+	var records []Record
+	for _, p := range stat.Procedures {
+		if len(p.TokenUsageDetails) == 0 {
+			if p.Count == 0 && p.InputCount == 0 && p.OutputCount == 0 {
+				continue
+			}
+			records = append(records, Record{Agent: agent, Procedure: p.Name, Usage: Usage{
+				InputTokens:  uint64(p.InputCount),
+				OutputTokens: uint64(p.OutputCount),
+				TotalTokens:  uint64(p.Count),
+			}})
+			continue
+		}
+		for _, d := range p.TokenUsageDetails {
+			if d == nil {
+				continue
+			}
+			records = append(records, Record{Agent: agent, Model: d.ModelName, Procedure: p.Name, Usage: Usage{
+				InputTokens:  uint64(d.InputTokens),
+				OutputTokens: uint64(d.OutputTokens),
+				TotalTokens:  uint64(d.TotalTokens),
+			}})
+		}
+	}
+	if len(records) == 0 && stat.TokenCount > 0 {
+		records = append(records, Record{Agent: agent, Usage: Usage{TotalTokens: uint64(stat.TokenCount)}})
+	}
+	return records
+}
+
+// Records 按 agent、模型、过程汇总的用量，按 agent、模型、过程排序
+func (c *Collector) Records() []Record {
+	c.mu.Lock()
+	records := make([]Record, 0, len(c.records))
+	for k, u := range c.records {
+		records = append(records, Record{Agent: k.agent, Model: k.model, Procedure: k.procedure, Usage: *u})
+	}
+	c.mu.Unlock()
+	sort.Slice(records, func(i, j int) bool {
+		a, b := records[i], records[j]
+		if a.Agent != b.Agent {
+			return a.Agent < b.Agent
+		}
+		if a.Model != b.Model {
+			return a.Model < b.Model
+		}
+		return a.Procedure < b.Procedure
+	})
+	return records
+}
+
+// Total 总用量
+func (c *Collector) Total() Usage {
+	total := Usage{}
+	for _, r := range c.Records() {
+		total.add(r.Usage)
+	}
+	return total
+}
+
+// ByAgent 按 agent 汇总的用量
+func (c *Collector) ByAgent() map[string]Usage {
+	return c.group(func(r Record) string { return r.Agent })
+}
+
+// ByModel 按模型汇总的用量
+func (c *Collector) ByModel() map[string]Usage {
+	return c.group(func(r Record) string { return r.Model })
+}
+
+// ByProcedure 按过程汇总的用量
+func (c *Collector) ByProcedure() map[string]Usage {
+	return c.group(func(r Record) string { return r.Procedure })
+}
+
+func (c *Collector) group(by func(r Record) string) map[string]Usage {
+	groups := map[string]Usage{}
+	for _, r := range c.Records() {
+		u := groups[by(r)]
+		u.add(r.Usage)
+		groups[by(r)] = u
+	}
+	return groups
+}
+
+// collectorKey context 中保存 Collector 的 key
+type collectorKey struct{}
+
+// WithCollector 把 Collector 放到 context 中，执行时的用量会累加到该 Collector
+func WithCollector(ctx context.Context, c *Collector) context.Context {
+	return context.WithValue(ctx, collectorKey{}, c)
+}
+
+// FromContext 获取 context 中的 Collector，没有时返回空
+func FromContext(ctx context.Context) *Collector {
+	c, _ := ctx.Value(collectorKey{}).(*Collector)
+	return c
+}
@@ -0,0 +1,131 @@
+package usage_test
+
+import (
+	"context"
+	"math"
+	"testing"
+
+	"github.com/tencent-lke/lke-sdk-go/event"
+	"github.com/tencent-lke/lke-sdk-go/usage"
+)
+
+func tokenStat(model string, input, output uint32) *event.TokenStatEvent {
+	return &event.TokenStatEvent{
+		Procedures: []event.Procedure{
+			{
+				Name: event.ProcedureLLM,
+				TokenUsageDetails: []*event.TokenUsage{{
+					ModelName:    model,
+					InputTokens:  input,
+					OutputTokens: output,
+					TotalTokens:  input + output,
+				}},
+			},
+			{Name: event.ProcedureKnowledge, Count: 10},
+		},
+	}
+}
+
+func TestCollector(t *testing.T) {
+	parent := usage.NewCollector()
+	ctx := usage.WithCollector(context.Background(), parent)
+	run := usage.FromContext(ctx).Child()
+	run.AddTokenStat("A", tokenStat("pro", 100, 10))
+	sub := run.Child()
+	sub.AddTokenStat("B", tokenStat("r1", 200, 20))
+
+	except := usage.Usage{InputTokens: 300, OutputTokens: 30, TotalTokens: 350}
+	if run.Total() != except || parent.Total() != except {
+		t.Fatalf("except total %+v, actual: %+v, %+v", except, run.Total(), parent.Total())
+	}
+	if sub.Total().TotalTokens != 230 {
+		t.Fatalf("except sub total 230, actual: %+v", sub.Total())
+	}
+	if byAgent := run.ByAgent(); byAgent["A"].TotalTokens != 120 || byAgent["B"].TotalTokens != 230 {
+		t.Fatalf("unexpected usage by agent: %+v", byAgent)
+	}
+	if byModel := run.ByModel(); byModel["pro"].TotalTokens != 110 || byModel[""].TotalTokens != 20 {
+		t.Fatalf("unexpected usage by model: %+v", byModel)
+	}
+	byProcedure := run.ByProcedure()
+	if byProcedure[event.ProcedureLLM].TotalTokens != 330 || byProcedure[event.ProcedureKnowledge].TotalTokens != 20 {
+		t.Fatalf("unexpected usage by procedure: %+v", byProcedure)
+	}
+	if records := run.Records(); len(records) != 4 || records[0].Agent != "A" || records[0].Model != "" {
+		t.Fatalf("unexpected records: %+v", records)
+	}
+}
+
+func TestCollectorMultipleParents(t *testing.T) {
+	team, session := usage.NewCollector(), usage.NewCollector()
+	run := team.Child(session, nil)
+	run.Child().AddTokenStat("B", tokenStat("r1", 200, 20))
+	if team.Total().TotalTokens != 230 || session.Total().TotalTokens != 230 || run.Total().TotalTokens != 230 {
+		t.Fatalf("except usage added to all parents, actual: %+v, %+v, %+v", team.Total(), session.Total(), run.Total())
+	}
+	var nilCollector *usage.Collector
+	if c := nilCollector.Child(); c == nil {
+		t.Fatal("except a new collector from nil parent")
+	}
+}
+
+func TestCollectorSeed(t *testing.T) {
+	parent := usage.NewCollector()
+	run := parent.Child()
+	run.Seed(usage.TokenStatRecords("A", tokenStat("pro", 100, 10))...)
+	run.AddTokenStat("A", tokenStat("pro", 10, 0))
+	if run.Total().TotalTokens != 140 || parent.Total().TotalTokens != 20 {
+		t.Fatalf("except seeded usage only in the run, actual: %+v, %+v", run.Total(), parent.Total())
+	}
+}
+
+func TestTokenStatRecords(t *testing.T) {
+	records := usage.TokenStatRecords("A", tokenStat("pro", 100, 10))
+	if len(records) != 2 || records[0].Model != "pro" || records[0].TotalTokens != 110 ||
+		records[1].Procedure != event.ProcedureKnowledge || records[1].TotalTokens != 10 {
+		t.Fatalf("unexpected records: %+v", records)
+	}
+	// 没有各过程的用量时使用 TokenCount
+	records = usage.TokenStatRecords("A", &event.TokenStatEvent{TokenCount: 42})
+	if len(records) != 1 || records[0].Agent != "A" || records[0].TotalTokens != 42 {
+		t.Fatalf("except TokenCount as fallback, actual: %+v", records)
+	}
+	if records := usage.TokenStatRecords("A", nil); len(records) != 0 {
+		t.Fatalf("except no records for nil stat, actual: %+v", records)
+	}
+}
+
+func TestSummary(t *testing.T) {
+	c := usage.NewCollector()
+	c.AddTokenStat("A", tokenStat("pro", 1000, 2000))
+	prices := usage.PriceTable{
+		"pro": {InputPer1K: 0.5, OutputPer1K: 1},
+		"":    {TotalPer1K: 0.1},
+	}
+	s := c.Summary(prices)
+	if math.Abs(s.ByModel["pro"].Cost-2.5) > 1e-9 || math.Abs(s.ByModel[""].Cost-0.001) > 1e-9 {
+		t.Fatalf("unexpected cost by model: %+v", s.ByModel)
+	}
+	if math.Abs(s.Total.Cost-2.501) > 1e-9 || s.Total.TotalTokens != 3010 || s.ByAgent["A"].Cost != s.Total.Cost {
+		t.Fatalf("unexpected total: %+v", s.Total)
+	}
+	if s := c.Summary(nil); s.Total.Cost != 0 || s.Total.TotalTokens != 3010 {
+		t.Fatalf("except zero cost without prices, actual: %+v", s.Total)
+	}
+}
+
+func TestSummaryAdd(t *testing.T) {
+	c := usage.NewCollector()
+	c.AddTokenStat("A", tokenStat("pro", 1000, 2000))
+	prices := usage.PriceTable{"pro": {InputPer1K: 0.5, OutputPer1K: 1}}
+	s := &usage.Summary{}
+	s.Add(c.Summary(prices))
+	s.Add(c.Summary(prices))
+	s.Add(nil)
+	if s.Total.TotalTokens != 6020 || math.Abs(s.ByModel["pro"].Cost-5) > 1e-9 || s.ByAgent["A"].TotalTokens != 6020 {
+		t.Fatalf("unexpected merged summary: %+v", s)
+	}
+	if len(s.Records) != 2 || s.Records[0].TotalTokens+s.Records[1].TotalTokens != 6020 {
+		t.Fatalf("except records merged by key, actual: %+v", s.Records)
+	}
+}
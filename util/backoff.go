@@ -0,0 +1,31 @@
+package util
+
+import (
+	"math/rand"
+	"time"
+)
+
+// Backoff 指数退避的等待时间，attempt 为失败的次数，从 1 开始
+// multiplier 小于 1 时按 1 处理，maxBackoff 为 0 表示不限制，jitter 为随机抖动比例，取值 [0, 1]
+func Backoff(initial, maxBackoff time.Duration, multiplier, jitter float64, attempt int) time.Duration {
+	if multiplier < 1 {
+		multiplier = 1
+	}
+	backoff := float64(initial)
+	for i := 1; i < attempt; i++ {
+		backoff *= multiplier
+		if maxBackoff > 0 && backoff > float64(maxBackoff) {
+			break
+		}
+	}
+	if maxBackoff > 0 && backoff > float64(maxBackoff) {
+		backoff = float64(maxBackoff)
+	}
+	if jitter > 0 {
+		if jitter > 1 {
+			jitter = 1
+		}
+		backoff = backoff * (1 + jitter*(2*rand.Float64()-1))
+	}
+	return time.Duration(backoff)
+}
@@ -0,0 +1,29 @@
+package util
+
+import "context"
+
+const runSessionContextKey contextKey = "RunSession"
+
+// RunSession 一次执行所属的会话信息
+type RunSession struct {
+	RequestID    string // 本次执行的请求 ID
+	SessionID    string // 对话唯一标识
+	VisitorBizID string // 访客唯一标识
+}
+
+// WithRunSession stores the run session in ctx for nested agent runs.
+func WithRunSession(ctx context.Context, session RunSession) context.Context {
+	if ctx == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, runSessionContextKey, session)
+}
+
+// GetRunSessionFromContext extracts the run session from ctx if present.
+func GetRunSessionFromContext(ctx context.Context) (RunSession, bool) {
+	if ctx == nil {
+		return RunSession{}, false
+	}
+	session, ok := ctx.Value(runSessionContextKey).(RunSession)
+	return session, ok
+}